@@ -6,7 +6,7 @@ from typing import Dict, Any
 import uvicorn
 from fastapi import FastAPI, HTTPException
 from fastapi.middleware.cors import CORSMiddleware
-from pydantic import BaseModel, Field
+from pydantic import BaseModel, ConfigDict, Field
 
 from models.anomaly_detector import AnomalyDetector
 from utils.data_generator import generate_mock_dataset
@@ -49,15 +49,27 @@ class DailyRoutineData(BaseModel):
     stress_level: int = Field(..., ge=1, le=10, description="Stress level (1-10 scale)")
 
 class PredictionResponse(BaseModel):
+    # Allow the model_* provenance fields
+    model_config = ConfigDict(protected_namespaces=())
+
     is_anomaly: bool
     confidence_score: float
     anomaly_type: str
     recommendations: list[str]
     timestamp: str
+    # Provenance of the model that made the prediction
+    model_name: str
+    model_version: str
+    model_accuracy: float
+    feature_names: list[str]
 
 class HealthResponse(BaseModel):
+    model_config = ConfigDict(protected_namespaces=())
+
     status: str
     model_loaded: bool
+    model_name: str
+    model_version: str
     model_accuracy: float
     timestamp: str
 
@@ -90,6 +102,8 @@ async def health_check():
         return HealthResponse(
             status="healthy",
             model_loaded=anomaly_detector.is_trained,
+            model_name=anomaly_detector.model_name,
+            model_version=anomaly_detector.model_version,
             model_accuracy=accuracy,
             timestamp=datetime.now().isoformat()
         )
@@ -137,7 +151,8 @@ async def predict_anomaly(data: DailyRoutineData):
             confidence_score=confidence_score,
             anomaly_type=anomaly_type,
             recommendations=recommendations,
-            timestamp=datetime.now().isoformat()
+            timestamp=datetime.now().isoformat(),
+            **anomaly_detector.get_model_info()
         )
         
         logger.info(f"Prediction completed: {response}")
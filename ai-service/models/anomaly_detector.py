@@ -2,7 +2,7 @@ import logging
 import numpy as np
 import pandas as pd
 from datetime import datetime
-from typing import Tuple, List, Any
+from typing import Tuple, List, Any, Optional
 from sklearn.ensemble import RandomForestClassifier
 from sklearn.preprocessing import StandardScaler
 from sklearn.metrics import accuracy_score, classification_report
@@ -11,12 +11,16 @@ import os
 
 logger = logging.getLogger(__name__)
 
+# Name of the model family reported with every prediction
+MODEL_NAME = "routine_random_forest"
+
 class AnomalyDetector:
     """
     Anomaly detection model for daily routine data using RandomForestClassifier
     """
     
-    def __init__(self, model_path: str = "models/anomaly_model.joblib"):
+    def __init__(self, model_path: str = "models/anomaly_model.joblib",
+                 model_version: Optional[str] = None):
         self.model = RandomForestClassifier(
             n_estimators=100,
             max_depth=10,
@@ -27,6 +31,9 @@ class AnomalyDetector:
         self.is_trained = False
         self.model_path = model_path
         self.accuracy = 0.0
+        self.model_name = MODEL_NAME
+        # The configured version wins over the one stored with a loaded model
+        self.model_version = model_version or os.getenv("MODEL_VERSION", "1.0.0")
         self.feature_names = [
             'sleep_hours', 'screen_time', 'exercise_duration', 'water_intake',
             'stress_level', 'meal_count', 'wake_up_hour', 'bed_time_hour',
@@ -201,6 +208,15 @@ class AnomalyDetector:
         """Get the model's accuracy score"""
         return self.accuracy
     
+    def get_model_info(self) -> dict:
+        """Get the provenance reported with every prediction"""
+        return {
+            'model_name': self.model_name,
+            'model_version': self.model_version,
+            'model_accuracy': self.accuracy,
+            'feature_names': list(self.feature_names)
+        }
+    
     def save_model(self) -> None:
         """Save the trained model to disk"""
         try:
@@ -209,7 +225,9 @@ class AnomalyDetector:
                 'model': self.model,
                 'scaler': self.scaler,
                 'accuracy': self.accuracy,
-                'feature_names': self.feature_names
+                'feature_names': self.feature_names,
+                'model_name': self.model_name,
+                'model_version': self.model_version
             }
             joblib.dump(model_data, self.model_path)
             logger.info(f"Model saved to {self.model_path}")
@@ -226,6 +244,12 @@ class AnomalyDetector:
                 self.scaler = model_data['scaler']
                 self.accuracy = model_data['accuracy']
                 self.feature_names = model_data['feature_names']
+                # Files saved before versioning keep the configured name
+                self.model_name = model_data.get('model_name', self.model_name)
+                saved_version = model_data.get('model_version')
+                if saved_version is not None and saved_version != self.model_version:
+                    logger.warning(f"Model at {self.model_path} was saved as version {saved_version}, "
+                                   f"reporting configured version {self.model_version}")
                 self.is_trained = True
                 logger.info(f"Model loaded from {self.model_path}")
             else:
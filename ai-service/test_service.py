@@ -21,6 +21,7 @@ def test_health_endpoint():
             print(f"✅ Health check passed")
             print(f"   Status: {data['status']}")
             print(f"   Model loaded: {data['model_loaded']}")
+            print(f"   Model: {data['model_name']} {data['model_version']}")
             print(f"   Model accuracy: {data['model_accuracy']:.3f}")
             return True
         else:
@@ -50,6 +51,7 @@ def test_prediction_endpoint(data, expected_anomaly=False):
             print(f"   Confidence: {result['confidence_score']:.3f}")
             print(f"   Anomaly type: {result['anomaly_type']}")
             print(f"   Recommendations: {result['recommendations']}")
+            print(f"   Model: {result['model_name']} {result['model_version']}")
             
             # Check if prediction matches expectation
             if result['is_anomaly'] == expected_anomaly:
@@ -1,75 +1,274 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 
+	"lifepattern-api/internal/apperror"
+	"lifepattern-api/internal/auth"
 	"lifepattern-api/internal/config"
 	"lifepattern-api/internal/database"
 	"lifepattern-api/internal/handlers"
+	"lifepattern-api/internal/logging"
+	"lifepattern-api/internal/metrics"
 	"lifepattern-api/internal/middleware"
 	"lifepattern-api/internal/services"
 )
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found, using default values")
-	}
+	envErr := godotenv.Load()
 
 	// Load configuration
 	cfg := config.Load()
 
+	// Initialize the structured logger; the standard log package writes through it too
+	level, err := logging.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger := logging.New(os.Stdout, level, cfg.Log.Format)
+	slog.SetDefault(logger)
+
+	if envErr != nil {
+		logger.Warn(".env file not found, using default values")
+	}
+
+	// `server migrate up|down|status` manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:], logger); err != nil {
+			fatal(logger, "migration failed", err)
+		}
+		return
+	}
+
 	// Initialize database repository
-	repo, err := database.NewRepository(cfg.Database.URL)
+	repo, err := database.NewRepository(cfg.Database.URL, logger)
 	if err != nil {
-		log.Fatal("Failed to initialize database:", err)
+		fatal(logger, "failed to initialize database", err)
 	}
-	defer repo.Close()
 
-	// Initialize AI service
-	aiService := services.NewAIService(cfg.AIService.URL)
+	// Apply pending schema migrations
+	if cfg.Database.AutoMigrate {
+		applied, err := repo.Migrator().Up()
+		if err != nil {
+			fatal(logger, "failed to apply migrations", err)
+		}
+		logger.Info("applied pending migrations", "count", len(applied))
+	}
+
+	// Collect metrics for /metrics
+	registry := metrics.NewRegistry()
+	serviceMetrics := services.NewMetrics(registry)
+	repo.RegisterMetrics(registry)
+
+	// Initialize AI service behind retries and a circuit breaker
+	resilience := services.AIResilienceConfig{
+		MaxRetries:     cfg.AIService.MaxRetries,
+		RetryBaseDelay: cfg.AIService.RetryBaseDelay,
+		RetryMaxDelay:  cfg.AIService.RetryMaxDelay,
+		Breaker: services.CircuitBreakerConfig{
+			FailureThreshold: cfg.AIService.BreakerFailureThreshold,
+			OpenTimeout:      cfg.AIService.BreakerOpenTimeout,
+		},
+	}
+	aiService := services.NewResilientAIService(
+		services.NewAIService(cfg.AIService.URL, cfg.AIService.Timeout, logger),
+		resilience,
+		logger,
+		serviceMetrics,
+	)
+	services.RegisterCircuitMetrics(registry, aiService)
+
+	// Register every analyzer ANALYZERS can name; extra backends are not counted in the AI service metrics
+	analyzers := services.NewAnalyzerRegistry()
+	register := func(name string, analyzer services.AIServiceInterface) {
+		if err := analyzers.Register(name, analyzer); err != nil {
+			fatal(logger, "invalid analyzer configuration", err)
+		}
+	}
+	register(services.SourceAIService, aiService)
+	for name, url := range cfg.AIService.Backends {
+		register(name, services.NewResilientAIService(
+			services.NewAIService(url, cfg.AIService.Timeout, logger), resilience, logger, nil))
+	}
+	register(services.SourceLocalRules, services.NewLocalRulesAnalyzer())
+	register(services.SourceBaseline, services.NewBaselineDetector(repo, services.BaselineConfig{
+		WindowDays: cfg.Baseline.WindowDays,
+		MinSamples: cfg.Baseline.MinSamples,
+		ZThreshold: cfg.Baseline.ZThreshold,
+	}))
+
+	// Requests get a fallback analysis while the primary analyzer is unavailable;
+	// the worker below re-analyzes those logs once it is back
+	analyzer, err := analyzers.Chain(cfg.AIService.Analyzers, logger)
+	if err != nil {
+		fatal(logger, "invalid analyzer chain", err)
+	}
 
 	// Initialize business services
-	routineService := services.NewRoutineService(repo, aiService)
+	routineService := services.NewRoutineService(repo, analyzer, logger, serviceMetrics)
+	userService := services.NewUserService(repo, services.UserServiceConfig{
+		AllowLegacyClaims: cfg.Auth.AllowLegacyClaims,
+	}, logger)
+
+	// Start the background worker that retries analyses the AI service could not complete
+	analysisWorker := services.NewAnalysisWorker(repo, analyzer, services.AnalysisWorkerConfig{
+		Workers:       cfg.Analysis.Workers,
+		PollInterval:  cfg.Analysis.PollInterval,
+		BaseBackoff:   cfg.Analysis.BaseBackoff,
+		MaxBackoff:    cfg.Analysis.MaxBackoff,
+		SweepInterval: cfg.Analysis.SweepInterval,
+		MaxAttempts:   cfg.Analysis.MaxAttempts,
+	}, logger, serviceMetrics)
+	analysisWorker.Start()
+	services.RegisterQueueMetrics(registry, analysisWorker, logger)
+
+	// Check the database and AI service in the background for the health probes
+	healthMonitor := services.NewHealthMonitor(repo, aiService, services.HealthCheckConfig{
+		Interval: cfg.Health.CheckInterval,
+		Timeout:  cfg.Health.CheckTimeout,
+	}, logger)
+	healthMonitor.Start()
+
+	// Initialize token manager
+	// Production refuses a missing or placeholder secret; development falls back to a random one
+	if err := auth.ValidateSecret(cfg.Auth.Secret); err != nil && cfg.Server.IsProduction() {
+		fatal(logger, "invalid AUTH_SECRET", err)
+	} else if err != nil && cfg.Auth.Secret != "" {
+		logger.Warn("weak AUTH_SECRET, do not use it in production", "error", err)
+	}
+	if cfg.Auth.Secret == "" {
+		logger.Warn("AUTH_SECRET not set, using a random secret (tokens will not survive a restart)")
+		secret, err := auth.RandomSecret()
+		if err != nil {
+			fatal(logger, "failed to generate auth secret", err)
+		}
+		cfg.Auth.Secret = secret
+	}
+	tokenManager, err := auth.NewTokenManager(cfg.Auth.Secret, cfg.Auth.TokenTTL)
+	if err != nil {
+		fatal(logger, "failed to initialize token manager", err)
+	}
 
 	// Initialize handlers
 	logHandler := handlers.NewLogHandler(routineService)
 	insightHandler := handlers.NewInsightHandler(routineService)
-	healthHandler := handlers.NewHealthHandler(repo, aiService)
+	healthHandler := handlers.NewHealthHandler(healthMonitor, aiService)
+	userHandler := handlers.NewUserHandler(userService)
+	authHandler := handlers.NewAuthHandler(userService, tokenManager)
+	queueHandler := handlers.NewQueueHandler(analysisWorker)
 
-	// Create router
+	// Create router; unknown routes and methods get the JSON error envelope too
 	r := mux.NewRouter()
+	r.NotFoundHandler = apperror.Handler(apperror.ErrRouteNotFound)
+	r.MethodNotAllowedHandler = apperror.Handler(apperror.ErrMethodNotAllowed)
+
+	// Metrics wraps the router from outside; this passes it the matched route
+	r.Use(middleware.RecordRoute)
 
-	// Apply CORS middleware
-	r.Use(middleware.CORS)
+	// Limit requests per user, or per client IP before authentication
+	limit := func(next http.Handler) http.Handler { return next }
+	if cfg.RateLimit.Enabled {
+		limiter, err := middleware.NewRateLimiter(middleware.RateLimitConfig{
+			Read:           middleware.RateLimit{Rate: float64(cfg.RateLimit.ReadPerMinute) / 60, Burst: cfg.RateLimit.ReadBurst},
+			Write:          middleware.RateLimit{Rate: float64(cfg.RateLimit.WritePerMinute) / 60, Burst: cfg.RateLimit.WriteBurst},
+			TrustedProxies: cfg.RateLimit.TrustedProxies,
+		}, middleware.NewMemoryRateLimitStore(), logger)
+		if err != nil {
+			fatal(logger, "invalid rate limit configuration", err)
+		}
+		limit = limiter.Handler
+	}
 
-	// Define routes
+	// Probes and metrics are never rate limited
+	r.HandleFunc("/livez", healthHandler.Livez).Methods("GET")
+	r.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET")
 	r.HandleFunc("/health", healthHandler.HealthCheck).Methods("GET")
-	r.HandleFunc("/log", logHandler.CreateRoutineLog).Methods("POST")
-	r.HandleFunc("/logs", logHandler.GetUserRoutineLogs).Methods("GET")
-	r.HandleFunc("/insights", insightHandler.GetInsight).Methods("GET")
-	r.HandleFunc("/user-insights", insightHandler.GetUserInsights).Methods("GET")
+	r.Handle("/metrics", registry.Handler()).Methods("GET")
+
+	// Public routes, limited per client IP
+	public := r.NewRoute().Subrouter()
+	public.Use(limit)
+	public.HandleFunc("/users", userHandler.RegisterUser).Methods("POST")
+	public.HandleFunc("/auth/token", authHandler.IssueToken).Methods("POST")
+
+	// Routes that require a bearer token, limited per user
+	api := r.NewRoute().Subrouter()
+	api.Use(middleware.Authenticate(tokenManager))
+	api.Use(limit)
+	api.HandleFunc("/log", logHandler.CreateRoutineLog).Methods("POST")
+	api.HandleFunc("/logs", logHandler.GetUserRoutineLogs).Methods("GET")
+	api.HandleFunc("/logs/{id:[0-9]+}", logHandler.GetRoutineLog).Methods("GET")
+	api.HandleFunc("/logs/{id:[0-9]+}", logHandler.UpdateRoutineLog).Methods("PUT", "PATCH")
+	api.HandleFunc("/logs/{id:[0-9]+}", logHandler.DeleteRoutineLog).Methods("DELETE")
+	api.HandleFunc("/insights", insightHandler.GetInsight).Methods("GET")
+	api.HandleFunc("/user-insights", insightHandler.GetUserInsights).Methods("GET")
+	api.HandleFunc("/users/{id}", userHandler.GetUser).Methods("GET")
+	api.HandleFunc("/users/{id}", userHandler.RenameUser).Methods("PATCH")
+	api.HandleFunc("/users/{id}/trends", insightHandler.GetTrends).Methods("GET")
+	api.HandleFunc("/analysis-queue", queueHandler.GetQueueStats).Methods("GET")
+
+	// Apply the CORS policy in front of the router so preflights for unknown routes are rejected
+	cors, err := middleware.NewCORS(middleware.CORSConfig{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}, r)
+	if err != nil {
+		fatal(logger, "invalid CORS configuration", err)
+	}
+
+	// Tag every request with an ID, log and measure it in front of CORS and the router,
+	// so unknown routes and rejected preflights are logged and counted too
+	handler := middleware.RequestID(middleware.AccessLog(logger)(middleware.Metrics(registry)(cors.Handler(r))))
 
 	// Start server
 	serverAddr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
-	fmt.Printf("🚀 LifePattern Backend Server Starting...\n")
-	fmt.Printf("📍 Server Address: http://%s\n", serverAddr)
-	fmt.Printf("🤖 AI Service URL: %s\n", cfg.AIService.URL)
-	fmt.Printf("🗄️  Database URL: %s\n", cfg.Database.URL)
-	fmt.Printf("🌐 CORS Enabled: All origins allowed\n")
-	fmt.Printf("📊 API Endpoints:\n")
-	fmt.Printf("   GET  /health         - Service health check\n")
-	fmt.Printf("   POST /log            - Create routine log with AI analysis\n")
-	fmt.Printf("   GET  /logs           - Get user routine logs\n")
-	fmt.Printf("   GET  /insights       - Get specific insight\n")
-	fmt.Printf("   GET  /user-insights  - Get all insights for user\n")
-	fmt.Printf("✅ Server ready to handle requests!\n")
-	fmt.Printf("🔄 Communication Flow: Frontend ↔ Backend ↔ AI Service ↔ Database\n")
-
-	log.Fatal(http.ListenAndServe(serverAddr, r))
+	logger.Info("server starting",
+		"addr", serverAddr,
+		"ai_service_url", cfg.AIService.URL,
+		"analyzers", cfg.AIService.Analyzers,
+		"cors_allowed_origins", cfg.CORS.AllowedOrigins,
+		"log_level", level.String())
+
+	srv := &http.Server{
+		Addr:              serverAddr,
+		Handler:           handler,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	// Drain requests, stop the workers and close the database pool on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = serve(ctx, srv, cfg.Server.ShutdownTimeout, logger,
+		shutdownStep{name: "analysis workers", run: analysisWorker.Stop},
+		shutdownStep{name: "health checks", run: healthMonitor.Stop},
+		shutdownStep{name: "database pool", run: func(context.Context) error { return repo.Close() }},
+	)
+	if err != nil {
+		fatal(logger, "server stopped with errors", err)
+	}
+}
+
+// fatal logs err and exits with status 1
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
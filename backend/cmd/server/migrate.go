@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"lifepattern-api/internal/config"
+	"lifepattern-api/internal/database"
+)
+
+const migrateUsage = "usage: server migrate <up|down|status>"
+
+// runMigrate implements the `server migrate up|down|status` subcommand
+func runMigrate(cfg *config.Config, args []string, logger *slog.Logger) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	repo, err := database.NewRepository(cfg.Database.URL, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer repo.Close()
+
+	migrator := repo.Migrator()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+		for _, m := range applied {
+			fmt.Printf("Applied %03d_%s\n", m.Version, m.Name)
+		}
+
+	case "down":
+		reverted, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			fmt.Println("No migrations to revert")
+		} else {
+			fmt.Printf("Reverted %03d_%s\n", reverted.Version, reverted.Name)
+		}
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%03d_%-30s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// shutdownStep releases one resource during shutdown; steps run in order after the
+// HTTP server has drained and share the remaining shutdown budget
+type shutdownStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// serve runs srv until it fails or ctx is cancelled (SIGINT/SIGTERM)
+// On cancellation it stops accepting connections, waits up to shutdownTimeout for
+// in-flight requests such as POST /log to finish their analysis, then runs steps in order
+func serve(ctx context.Context, srv *http.Server, shutdownTimeout time.Duration, logger *slog.Logger, steps ...shutdownStep) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutdown signal received, draining in-flight requests", "timeout", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("HTTP server did not drain in time", "error", err)
+		errs = append(errs, fmt.Errorf("failed to drain HTTP server: %w", err))
+	} else {
+		logger.Info("HTTP server drained")
+	}
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, fmt.Errorf("server failed: %w", err))
+	}
+
+	for _, step := range steps {
+		if err := step.run(shutdownCtx); err != nil {
+			logger.Warn("failed to stop component", "component", step.name, "error", err)
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", step.name, err))
+		}
+	}
+
+	logger.Info("server stopped")
+	return errors.Join(errs...)
+}
@@ -0,0 +1,197 @@
+// Package apperror defines the errors the API reports to clients and writes them as one JSON envelope
+// so clients can branch on a stable code instead of parsing messages
+package apperror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"lifepattern-api/internal/logging"
+)
+
+// Code is a machine-readable error category
+type Code string
+
+const (
+	CodeValidation       Code = "validation"
+	CodeUnauthorized     Code = "unauthorized"
+	CodeForbidden        Code = "forbidden"
+	CodeNotFound         Code = "not_found"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeConflict         Code = "conflict"
+	CodeTooLarge         Code = "too_large"
+	CodeRateLimited      Code = "rate_limited"
+	CodeUnavailable      Code = "unavailable"
+	CodeInternal         Code = "internal"
+)
+
+// Status returns the HTTP status for code
+func (c Code) Status() int {
+	switch c {
+	case CodeValidation:
+		return http.StatusBadRequest
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeForbidden:
+		return http.StatusForbidden
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case CodeConflict:
+		return http.StatusConflict
+	case CodeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case CodeRateLimited:
+		return http.StatusTooManyRequests
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error is an error whose code and message are safe to show to clients
+// Wrapping it with fmt.Errorf keeps it visible to errors.As, so services can return
+// Error sentinels through any number of layers
+type Error struct {
+	Code    Code
+	Message string
+	// Field names the request field at fault, for validation errors
+	Field string
+	// Fields lists every invalid field when a request is validated as a whole
+	Fields []FieldError
+}
+
+// FieldError describes one invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// New returns an error with code and message
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Validation returns an error for an invalid request field; field may be empty
+func Validation(field, message string) *Error {
+	return &Error{Code: CodeValidation, Field: field, Message: message}
+}
+
+// Invalid returns a validation error listing every invalid field
+// A single field is also reported as the error's Field and Message
+func Invalid(fields []FieldError) *Error {
+	err := &Error{Code: CodeValidation, Fields: fields}
+	if len(fields) == 1 {
+		err.Field = fields[0].Field
+		err.Message = fields[0].Message
+	} else {
+		err.Message = fmt.Sprintf("%d fields are invalid", len(fields))
+	}
+	return err
+}
+
+// NotFound returns an error for a missing resource
+func NotFound(message string) *Error {
+	return New(CodeNotFound, message)
+}
+
+// Conflict returns an error for a request that clashes with existing data
+func Conflict(message string) *Error {
+	return New(CodeConflict, message)
+}
+
+// Unavailable returns an error for a dependency that cannot serve the request right now
+func Unavailable(message string) *Error {
+	return New(CodeUnavailable, message)
+}
+
+var (
+	// ErrMethodNotAllowed is returned for an HTTP method the endpoint does not serve
+	ErrMethodNotAllowed = New(CodeMethodNotAllowed, "method not allowed")
+
+	// ErrRouteNotFound is returned for a path no endpoint serves
+	ErrRouteNotFound = NotFound("route not found")
+
+	// ErrInvalidJSON is returned when a request body cannot be decoded
+	ErrInvalidJSON = Validation("", "invalid JSON")
+
+	// ErrBodyTooLarge is returned when a request body exceeds the endpoint's size limit
+	ErrBodyTooLarge = New(CodeTooLarge, "request body too large")
+
+	// errInternal replaces errors that must not reach clients
+	errInternal = New(CodeInternal, "internal server error")
+
+	// errTimeout is reported when the request ran out of time waiting on a dependency
+	errTimeout = Unavailable("request timed out, try again later")
+)
+
+// From returns the client-facing error in err's chain
+// Errors without one become a generic internal error so internal details are never exposed
+func From(err error) *Error {
+	var appErr *Error
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return errTimeout
+	default:
+		return errInternal
+	}
+}
+
+// Response is the JSON body of every error response
+type Response struct {
+	Code      Code         `json:"code"`
+	Message   string       `json:"message"`
+	Field     string       `json:"field,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// Write responds to r with err as a JSON envelope and the status of its code
+// Server-side failures are logged with the full error chain, which is never sent to the client
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	appErr := From(err)
+	status := appErr.Code.Status()
+
+	if status >= http.StatusInternalServerError {
+		slog.Default().ErrorContext(r.Context(), "request failed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"code", appErr.Code,
+			"error", err,
+		)
+	}
+
+	response := Response{
+		Code:    appErr.Code,
+		Message: appErr.Message,
+		Field:   appErr.Field,
+		Errors:  appErr.Fields,
+	}
+	if requestID, ok := logging.RequestIDFromContext(r.Context()); ok {
+		response.RequestID = requestID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Handler responds to every request with err, e.g. as the router's NotFoundHandler
+func Handler(err error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, err)
+	})
+}
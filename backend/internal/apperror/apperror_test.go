@@ -0,0 +1,130 @@
+package apperror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"lifepattern-api/internal/logging"
+)
+
+func TestCodeStatus(t *testing.T) {
+	statuses := map[Code]int{
+		CodeValidation:       http.StatusBadRequest,
+		CodeUnauthorized:     http.StatusUnauthorized,
+		CodeForbidden:        http.StatusForbidden,
+		CodeNotFound:         http.StatusNotFound,
+		CodeMethodNotAllowed: http.StatusMethodNotAllowed,
+		CodeConflict:         http.StatusConflict,
+		CodeTooLarge:         http.StatusRequestEntityTooLarge,
+		CodeRateLimited:      http.StatusTooManyRequests,
+		CodeUnavailable:      http.StatusServiceUnavailable,
+		CodeInternal:         http.StatusInternalServerError,
+		Code("unknown"):      http.StatusInternalServerError,
+	}
+
+	for code, expected := range statuses {
+		if status := code.Status(); status != expected {
+			t.Fatalf("Expected status %d for %s, got %d", expected, code, status)
+		}
+	}
+}
+
+func TestFrom(t *testing.T) {
+	notFound := NotFound("routine log not found")
+
+	// Wrapped errors keep their code
+	wrapped := fmt.Errorf("failed to get insight: %w", notFound)
+	if From(wrapped) != notFound {
+		t.Fatalf("Expected the wrapped error, got %+v", From(wrapped))
+	}
+	if !errors.Is(wrapped, notFound) {
+		t.Fatal("Expected errors.Is to match the sentinel")
+	}
+
+	timeout := fmt.Errorf("failed to query: %w", context.DeadlineExceeded)
+	if From(timeout).Code != CodeUnavailable {
+		t.Fatalf("Expected code unavailable for a timeout, got %s", From(timeout).Code)
+	}
+
+	internal := From(errors.New("pq: password authentication failed"))
+	if internal.Code != CodeInternal || strings.Contains(internal.Message, "pq") {
+		t.Fatalf("Expected a generic internal error, got %+v", internal)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	req := httptest.NewRequest("POST", "/log", nil)
+	req = req.WithContext(logging.WithRequestID(req.Context(), "req-123"))
+	w := httptest.NewRecorder()
+
+	Write(w, req, fmt.Errorf("invalid log: %w", Validation("sleep_hours", "sleep_hours must be between 0 and 24")))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected Content-Type application/json, got %s", w.Header().Get("Content-Type"))
+	}
+
+	var response Response
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	expected := Response{
+		Code:      CodeValidation,
+		Message:   "sleep_hours must be between 0 and 24",
+		Field:     "sleep_hours",
+		RequestID: "req-123",
+	}
+	if !reflect.DeepEqual(response, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, response)
+	}
+}
+
+func TestWriteOmitsEmptyFields(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	Write(w, httptest.NewRequest("GET", "/unknown", nil), ErrRouteNotFound)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+
+	body := strings.TrimSpace(w.Body.String())
+	if body != `{"code":"not_found","message":"route not found"}` {
+		t.Fatalf("Expected field and request_id to be omitted, got %s", body)
+	}
+}
+
+func TestWriteInvalidFields(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	Write(w, httptest.NewRequest("POST", "/log", nil), Invalid([]FieldError{
+		{Field: "wake_up_time", Message: "wake_up_time must be HH:MM"},
+		{Field: "log_date", Message: "log_date cannot be in the future"},
+	}))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+
+	var response Response
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.Message != "2 fields are invalid" || response.Field != "" {
+		t.Fatalf("Expected a summary without a single field, got %+v", response)
+	}
+	if len(response.Errors) != 2 || response.Errors[1].Field != "log_date" {
+		t.Fatalf("Expected both field errors, got %+v", response.Errors)
+	}
+}
@@ -0,0 +1,16 @@
+package auth
+
+import "context"
+
+type contextKey struct{}
+
+// WithUserID returns a copy of ctx carrying the authenticated user ID
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, userID)
+}
+
+// UserIDFromContext returns the authenticated user ID, if any
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(contextKey{}).(string)
+	return userID, ok && userID != ""
+}
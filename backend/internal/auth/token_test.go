@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueAndVerify(t *testing.T) {
+	tokens, err := NewTokenManager("secret", time.Hour)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	token, expiresAt, err := tokens.Issue("user_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if time.Until(expiresAt) <= 0 {
+		t.Fatal("Expected expiry in the future")
+	}
+
+	claims, err := tokens.Verify(token)
+	if err != nil {
+		t.Fatalf("Expected token to verify, got %v", err)
+	}
+
+	if claims.UserID != "user_1" {
+		t.Fatalf("Expected user_1, got %s", claims.UserID)
+	}
+}
+
+func TestVerifyRejectsTamperedToken(t *testing.T) {
+	tokens, _ := NewTokenManager("secret", time.Hour)
+	other, _ := NewTokenManager("other-secret", time.Hour)
+
+	token, _, _ := other.Issue("user_1")
+	if _, err := tokens.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Expected ErrInvalidToken for foreign signature, got %v", err)
+	}
+
+	token, _, _ = tokens.Issue("user_1")
+	payload, signature, _ := strings.Cut(token, ".")
+	forged, _, _ := tokens.Issue("user_2")
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	if _, err := tokens.Verify(forgedPayload + "." + signature); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Expected ErrInvalidToken for swapped payload, got %v", err)
+	}
+
+	for _, malformed := range []string{"", "abc", payload + ".", "." + signature} {
+		if _, err := tokens.Verify(malformed); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("Expected ErrInvalidToken for %q, got %v", malformed, err)
+		}
+	}
+}
+
+func TestVerifyRejectsExpiredToken(t *testing.T) {
+	tokens, _ := NewTokenManager("secret", time.Hour)
+	issuedAt := time.Now().Add(-2 * time.Hour)
+	tokens.now = func() time.Time { return issuedAt }
+
+	token, _, _ := tokens.Issue("user_1")
+
+	tokens.now = time.Now
+	if _, err := tokens.Verify(token); !errors.Is(err, ErrExpiredToken) {
+		t.Fatalf("Expected ErrExpiredToken, got %v", err)
+	}
+}
+
+func TestNewTokenManagerRequiresSecret(t *testing.T) {
+	if _, err := NewTokenManager("", time.Hour); err == nil {
+		t.Fatal("Expected error for empty secret")
+	}
+}
+
+func TestValidateSecret(t *testing.T) {
+	for _, secret := range []string{"", "change-me", "CHANGE-ME", "short-secret"} {
+		if err := ValidateSecret(secret); err == nil {
+			t.Fatalf("Expected secret %q to be rejected", secret)
+		}
+	}
+
+	secret, err := RandomSecret()
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+	if err := ValidateSecret(secret); err != nil {
+		t.Fatalf("Expected a random secret to be accepted, got %v", err)
+	}
+}
+
+func TestUserIDContext(t *testing.T) {
+	if _, ok := UserIDFromContext(context.Background()); ok {
+		t.Fatal("Expected no user in empty context")
+	}
+
+	userID, ok := UserIDFromContext(WithUserID(context.Background(), "user_1"))
+	if !ok || userID != "user_1" {
+		t.Fatalf("Expected user_1, got %q", userID)
+	}
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned when a requested record does not exist
+	ErrNotFound = errors.New("record not found")
+
+	// ErrConflict is returned when a record violates a uniqueness constraint
+	ErrConflict = errors.New("record already exists")
+)
+
+// uniqueViolation is the PostgreSQL error code for unique_violation
+const uniqueViolation = "23505"
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// EnqueueAnalysis queues a routine log for AI analysis
+// A log that is already queued keeps its job, which moves to a new version and is due again
+// right away: a worker still processing the old version will not complete it, and a job that
+// had failed is retried, since earlier failures say nothing about the log's new data
+func (r *Repository) EnqueueAnalysis(ctx context.Context, logID int) error {
+	query := `
+		INSERT INTO analysis_jobs (routine_log_id)
+		VALUES ($1)
+		ON CONFLICT (routine_log_id) DO UPDATE
+		SET version = analysis_jobs.version + 1, status = 'pending', attempts = 0,
+		    last_error = NULL, run_at = CURRENT_TIMESTAMP`
+
+	if _, err := r.db.ExecContext(ctx, query, logID); err != nil {
+		return fmt.Errorf("failed to enqueue analysis: %w", err)
+	}
+
+	return nil
+}
+
+// EnqueueUnanalyzedLogs queues every routine log older than minAge that has no AI report
+// It returns the number of newly queued logs; logs whose job failed are left alone
+// Durations here and below are applied with make_interval so only the database clock is involved
+func (r *Repository) EnqueueUnanalyzedLogs(ctx context.Context, minAge time.Duration) (int, error) {
+	query := `
+		INSERT INTO analysis_jobs (routine_log_id)
+		SELECT rl.id
+		FROM routine_logs rl
+		WHERE rl.created_at < CURRENT_TIMESTAMP - make_interval(secs => $1)
+		  AND NOT EXISTS (SELECT 1 FROM ai_reports ar WHERE ar.routine_log_id = rl.id)
+		ON CONFLICT (routine_log_id) DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, minAge.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue unanalyzed logs: %w", err)
+	}
+
+	queued, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue unanalyzed logs: %w", err)
+	}
+
+	return int(queued), nil
+}
+
+// ClaimAnalysisJobs locks up to limit due pending jobs for lease and counts the attempt
+// SKIP LOCKED lets several workers and server replicas poll the queue concurrently;
+// a job whose worker dies becomes due again once its lease expires
+func (r *Repository) ClaimAnalysisJobs(ctx context.Context, limit int, lease time.Duration) ([]AnalysisJob, error) {
+	query := `
+		UPDATE analysis_jobs
+		SET locked_until = CURRENT_TIMESTAMP + make_interval(secs => $2), attempts = attempts + 1
+		WHERE id IN (
+		    SELECT id FROM analysis_jobs
+		    WHERE status = 'pending'
+		      AND run_at <= CURRENT_TIMESTAMP
+		      AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+		    ORDER BY run_at, id
+		    LIMIT $1
+		    FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, routine_log_id, attempts, version, last_error, created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim analysis jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []AnalysisJob
+	for rows.Next() {
+		var job AnalysisJob
+		var lastError sql.NullString
+		if err := rows.Scan(&job.ID, &job.RoutineLogID, &job.Attempts, &job.Version, &lastError, &job.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan analysis job: %w", err)
+		}
+		job.LastError = lastError.String
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// CompleteAnalysisJob removes a finished job from the queue if it still has the claimed version
+// A job queued again while it was processed is released instead, so the log's latest data is
+// analyzed too; completed reports whether the job was removed
+func (r *Repository) CompleteAnalysisJob(ctx context.Context, jobID int, version int) (completed bool, err error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM analysis_jobs WHERE id = $1 AND version = $2`, jobID, version)
+	if err != nil {
+		return false, fmt.Errorf("failed to complete analysis job: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to complete analysis job: %w", err)
+	}
+	if affected > 0 {
+		return true, nil
+	}
+
+	if _, err := r.db.ExecContext(ctx, `UPDATE analysis_jobs SET locked_until = NULL WHERE id = $1`, jobID); err != nil {
+		return false, fmt.Errorf("failed to release analysis job: %w", err)
+	}
+
+	return false, nil
+}
+
+// RetryAnalysisJob releases a failed job and schedules its next attempt after delay
+func (r *Repository) RetryAnalysisJob(ctx context.Context, jobID int, delay time.Duration, lastError string) error {
+	query := `
+		UPDATE analysis_jobs
+		SET run_at = CURRENT_TIMESTAMP + make_interval(secs => $2), last_error = $3, locked_until = NULL
+		WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, jobID, delay.Seconds(), lastError); err != nil {
+		return fmt.Errorf("failed to reschedule analysis job: %w", err)
+	}
+
+	return nil
+}
+
+// FailAnalysisJob stops retrying a job that used up its attempts, keeping it as failed
+// A job queued again while it was processed is released for another attempt instead
+func (r *Repository) FailAnalysisJob(ctx context.Context, jobID int, version int, lastError string) error {
+	query := `
+		UPDATE analysis_jobs
+		SET status = CASE WHEN version = $2 THEN 'failed' ELSE status END,
+		    last_error = $3, locked_until = NULL
+		WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, jobID, version, lastError); err != nil {
+		return fmt.Errorf("failed to fail analysis job: %w", err)
+	}
+
+	return nil
+}
+
+// analysisQueueStatsQuery summarizes the jobs in FROM, pending and failed
+const analysisQueueStatsQuery = `
+		SELECT COUNT(*) FILTER (WHERE status = 'pending'),
+		       COUNT(*) FILTER (WHERE status = 'pending' AND run_at <= CURRENT_TIMESTAMP
+		                          AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)),
+		       COUNT(*) FILTER (WHERE status = 'pending' AND locked_until >= CURRENT_TIMESTAMP),
+		       COUNT(*) FILTER (WHERE status = 'failed'),
+		       COALESCE(MAX(attempts) FILTER (WHERE status = 'pending'), 0),
+		       MIN(created_at) FILTER (WHERE status = 'pending')
+		FROM `
+
+// GetAnalysisQueueStats summarizes the pending analysis queue and counts failed jobs
+func (r *Repository) GetAnalysisQueueStats(ctx context.Context) (*AnalysisQueueStats, error) {
+	return r.analysisQueueStats(ctx, analysisQueueStatsQuery+"analysis_jobs")
+}
+
+// GetUserAnalysisQueueStats is GetAnalysisQueueStats limited to the jobs of userID's logs
+func (r *Repository) GetUserAnalysisQueueStats(ctx context.Context, userID string) (*AnalysisQueueStats, error) {
+	query := analysisQueueStatsQuery + `analysis_jobs
+		WHERE routine_log_id IN (SELECT id FROM routine_logs WHERE user_id = $1)`
+
+	return r.analysisQueueStats(ctx, query, userID)
+}
+
+func (r *Repository) analysisQueueStats(ctx context.Context, query string, args ...interface{}) (*AnalysisQueueStats, error) {
+	var stats AnalysisQueueStats
+	var oldest sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&stats.Depth, &stats.Ready, &stats.InProgress, &stats.Failed, &stats.MaxAttempts, &oldest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analysis queue stats: %w", err)
+	}
+
+	if oldest.Valid {
+		stats.OldestEnqueuedAt = &oldest.Time
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAnalysisJobLifecycle(t *testing.T) {
+	logID, _, _, err := testRepo.SaveRoutineLog(context.Background(), RoutineLog{
+		UserID:      "1",
+		SleepHours:  8.0,
+		MealTimes:   []string{"08:00"},
+		WakeUpTime:  "07:00",
+		BedTime:     "23:00",
+		StressLevel: 4,
+		LogDate:     "2024-05-01",
+	}, true)
+	if err != nil {
+		t.Fatalf("Failed to save routine log: %v", err)
+	}
+
+	// Enqueueing twice keeps a single job, at its second version
+	for i := 0; i < 2; i++ {
+		if err := testRepo.EnqueueAnalysis(context.Background(), logID); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	claimed := claimJobFor(t, logID)
+	if claimed.Attempts != 1 || claimed.Version < 2 {
+		t.Fatalf("Expected the first attempt of a re-queued job, got %+v", claimed)
+	}
+
+	// A claimed job is hidden from other workers until it is released
+	if other := findJob(t, logID); other != nil {
+		t.Fatalf("Expected claimed job to be locked, got %+v", other)
+	}
+
+	if err := testRepo.RetryAnalysisJob(context.Background(), claimed.ID, 0, "AI service unavailable"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	retried := claimJobFor(t, logID)
+	if retried.LastError != "AI service unavailable" || retried.Attempts != claimed.Attempts+1 {
+		t.Fatalf("Expected retried job with last error, got %+v", retried)
+	}
+
+	stats, err := testRepo.GetAnalysisQueueStats(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stats.Depth < 1 || stats.InProgress < 1 {
+		t.Fatalf("Expected at least one queued job in progress, got %+v", stats)
+	}
+
+	completed, err := testRepo.CompleteAnalysisJob(context.Background(), retried.ID, retried.Version)
+	if err != nil || !completed {
+		t.Fatalf("Expected the job to be completed, got %v (%v)", completed, err)
+	}
+}
+
+func TestCompleteAnalysisJobQueuedAgain(t *testing.T) {
+	logID := saveJobTestLog(t, "2024-05-02")
+	if err := testRepo.EnqueueAnalysis(context.Background(), logID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	claimed := claimJobFor(t, logID)
+
+	// The log changes while its analysis is running
+	if err := testRepo.EnqueueAnalysis(context.Background(), logID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	completed, err := testRepo.CompleteAnalysisJob(context.Background(), claimed.ID, claimed.Version)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if completed {
+		t.Fatal("Expected the job queued again to be kept")
+	}
+
+	// The job is released for the new data right away
+	requeued := claimJobFor(t, logID)
+	if requeued.ID != claimed.ID || requeued.Version != claimed.Version+1 || requeued.Attempts != 1 {
+		t.Fatalf("Expected the next version of job %d with a fresh attempt count, got %+v", claimed.ID, requeued)
+	}
+
+	if completed, err := testRepo.CompleteAnalysisJob(context.Background(), requeued.ID, requeued.Version); err != nil || !completed {
+		t.Fatalf("Expected the job to be completed, got %v (%v)", completed, err)
+	}
+}
+
+func TestFailAnalysisJob(t *testing.T) {
+	logID := saveJobTestLog(t, "2024-05-03")
+	if err := testRepo.EnqueueAnalysis(context.Background(), logID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	claimed := claimJobFor(t, logID)
+	if err := testRepo.FailAnalysisJob(context.Background(), claimed.ID, claimed.Version, "AI service unavailable"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A failed job is kept but never claimed again
+	if job := findJob(t, logID); job != nil {
+		t.Fatalf("Expected the failed job not to be claimed, got %+v", job)
+	}
+
+	stats, err := testRepo.GetAnalysisQueueStats(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stats.Failed < 1 {
+		t.Fatalf("Expected at least one failed job, got %+v", stats)
+	}
+
+	// Only the owner of the log sees its job
+	own, err := testRepo.GetUserAnalysisQueueStats(context.Background(), "1")
+	if err != nil || own.Failed < 1 {
+		t.Fatalf("Expected the owner to see the failed job, got %+v (%v)", own, err)
+	}
+	other, err := testRepo.GetUserAnalysisQueueStats(context.Background(), "no_jobs_user")
+	if err != nil || other.Depth != 0 || other.Failed != 0 {
+		t.Fatalf("Expected no jobs for another user, got %+v (%v)", other, err)
+	}
+
+	// Neither does the sweep revive it, but queueing the log again does
+	if _, err := testRepo.EnqueueUnanalyzedLogs(context.Background(), 0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if job := findJob(t, logID); job != nil {
+		t.Fatalf("Expected the sweep to leave the failed job alone, got %+v", job)
+	}
+
+	if err := testRepo.EnqueueAnalysis(context.Background(), logID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	revived := claimJobFor(t, logID)
+	if completed, err := testRepo.CompleteAnalysisJob(context.Background(), revived.ID, revived.Version); err != nil || !completed {
+		t.Fatalf("Expected the revived job to be completed, got %v (%v)", completed, err)
+	}
+}
+
+// saveJobTestLog saves a routine log for user "1" on logDate and returns its ID
+func saveJobTestLog(t *testing.T, logDate string) int {
+	t.Helper()
+
+	logID, _, _, err := testRepo.SaveRoutineLog(context.Background(), RoutineLog{
+		UserID:      "1",
+		SleepHours:  8.0,
+		MealTimes:   []string{"08:00"},
+		WakeUpTime:  "07:00",
+		BedTime:     "23:00",
+		StressLevel: 4,
+		LogDate:     logDate,
+	}, true)
+	if err != nil {
+		t.Fatalf("Failed to save routine log: %v", err)
+	}
+	return logID
+}
+
+// claimJobFor claims due jobs until it finds the one for logID, releasing the others
+func claimJobFor(t *testing.T, logID int) AnalysisJob {
+	t.Helper()
+
+	job := findJob(t, logID)
+	if job == nil {
+		t.Fatalf("Expected a due job for log %d", logID)
+	}
+	return *job
+}
+
+// findJob claims all due jobs and returns the one for logID, if any
+func findJob(t *testing.T, logID int) *AnalysisJob {
+	t.Helper()
+
+	jobs, err := testRepo.ClaimAnalysisJobs(context.Background(), 1000, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to claim analysis jobs: %v", err)
+	}
+
+	var found *AnalysisJob
+	for i := range jobs {
+		if jobs[i].RoutineLogID == logID {
+			found = &jobs[i]
+			continue
+		}
+		testRepo.RetryAnalysisJob(context.Background(), jobs[i].ID, 0, jobs[i].LastError)
+	}
+	return found
+}
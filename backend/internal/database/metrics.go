@@ -0,0 +1,39 @@
+package database
+
+import (
+	"lifepattern-api/internal/metrics"
+)
+
+// RegisterMetrics exposes the connection pool statistics of the repository
+// Values are read from sql.DB.Stats at scrape time
+func (r *Repository) RegisterMetrics(registry *metrics.Registry) {
+	stats := r.db.Stats
+
+	registry.NewGaugeFunc("lifepattern_db_max_open_connections",
+		"Maximum number of open connections to the database.",
+		func() float64 { return float64(stats().MaxOpenConnections) })
+	registry.NewGaugeFunc("lifepattern_db_open_connections",
+		"Established connections, in use or idle.",
+		func() float64 { return float64(stats().OpenConnections) })
+	registry.NewGaugeFunc("lifepattern_db_in_use_connections",
+		"Connections currently in use.",
+		func() float64 { return float64(stats().InUse) })
+	registry.NewGaugeFunc("lifepattern_db_idle_connections",
+		"Idle connections.",
+		func() float64 { return float64(stats().Idle) })
+	registry.NewCounterFunc("lifepattern_db_wait_count_total",
+		"Connections waited for because the pool was exhausted.",
+		func() float64 { return float64(stats().WaitCount) })
+	registry.NewCounterFunc("lifepattern_db_wait_duration_seconds_total",
+		"Time spent waiting for a connection.",
+		func() float64 { return stats().WaitDuration.Seconds() })
+	registry.NewCounterFunc("lifepattern_db_max_idle_closed_total",
+		"Connections closed because of the idle pool limit.",
+		func() float64 { return float64(stats().MaxIdleClosed) })
+	registry.NewCounterFunc("lifepattern_db_max_idle_time_closed_total",
+		"Connections closed because they were idle too long.",
+		func() float64 { return float64(stats().MaxIdleTimeClosed) })
+	registry.NewCounterFunc("lifepattern_db_max_lifetime_closed_total",
+		"Connections closed because they reached their maximum lifetime.",
+		func() float64 { return float64(stats().MaxLifetimeClosed) })
+}
@@ -0,0 +1,287 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"lifepattern-api/migrations"
+)
+
+// migrationLockID is the PostgreSQL advisory lock key that serializes
+// migrations when several server replicas start at the same time
+const migrationLockID = 7310420985
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Migrator applies versioned SQL migrations and records them in schema_migrations
+type Migrator struct {
+	db     *sql.DB
+	source fs.FS
+	logger *slog.Logger
+}
+
+func NewMigrator(db *sql.DB, source fs.FS, logger *slog.Logger) *Migrator {
+	return &Migrator{
+		db:     db,
+		source: source,
+		logger: logger,
+	}
+}
+
+// Migrator returns a migrator for the migrations embedded in the binary
+func (r *Repository) Migrator() *Migrator {
+	return NewMigrator(r.db, migrations.FS, r.logger)
+}
+
+// LoadMigrations reads NNN_name.up.sql / NNN_name.down.sql files from source, ordered by version
+func LoadMigrations(source fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		contents, err := fs.ReadFile(source, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d has conflicting names %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %03d_%s has no up file", migration.Version, migration.Name)
+		}
+		result = append(result, *migration)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// Up applies all pending migrations in version order and returns the ones applied
+func (m *Migrator) Up() ([]Migration, error) {
+	all, err := LoadMigrations(m.source)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	err = m.withLock(func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range all {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			m.logger.Info("applying migration", "version", migration.Version, "name", migration.Name)
+			err := inTx(conn, func(tx *sql.Tx) error {
+				if _, err := tx.Exec(migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+					migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %03d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down rolls back the most recently applied migration
+// It returns nil when there is nothing to roll back
+func (m *Migrator) Down() (*Migration, error) {
+	all, err := LoadMigrations(m.source)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted *Migration
+	err = m.withLock(func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(all) - 1; i >= 0; i-- {
+			migration := all[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("migration %03d_%s has no down file", migration.Version, migration.Name)
+			}
+
+			m.logger.Info("reverting migration", "version", migration.Version, "name", migration.Name)
+			err := inTx(conn, func(tx *sql.Tx) error {
+				if _, err := tx.Exec(migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %03d_%s: %w", migration.Version, migration.Name, err)
+			}
+			reverted = &migration
+			return nil
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status reports every known migration and whether it has been applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	all, err := LoadMigrations(m.source)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	err = m.withLock(func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range all {
+			status := MigrationStatus{Migration: migration}
+			if appliedAt, ok := done[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+
+	return statuses, err
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory lock
+func (m *Migrator) withLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		    version INTEGER PRIMARY KEY,
+		    name VARCHAR(255) NOT NULL,
+		    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// appliedVersions returns the applied migration versions and when they were applied
+func appliedVersions(conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(context.Background(), `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		versions[version] = appliedAt
+	}
+
+	return versions, rows.Err()
+}
+
+// inTx runs fn in a transaction on conn, rolling back on error
+func inTx(conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"lifepattern-api/migrations"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	source := fstest.MapFS{
+		"002_add_index.up.sql":   {Data: []byte("CREATE INDEX a ON t(x);")},
+		"002_add_index.down.sql": {Data: []byte("DROP INDEX a;")},
+		"001_create.up.sql":      {Data: []byte("CREATE TABLE t (x INT);")},
+		"README.md":              {Data: []byte("ignored")},
+	}
+
+	loaded, err := LoadMigrations(source)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(loaded) != 2 {
+		t.Fatalf("Expected 2 migrations, got %d", len(loaded))
+	}
+
+	if loaded[0].Version != 1 || loaded[1].Version != 2 {
+		t.Fatalf("Expected migrations ordered by version, got %d, %d", loaded[0].Version, loaded[1].Version)
+	}
+
+	if loaded[0].Down != "" {
+		t.Fatal("Expected no down migration for version 1")
+	}
+
+	if loaded[1].Name != "add_index" || loaded[1].Down != "DROP INDEX a;" {
+		t.Fatalf("Unexpected migration %+v", loaded[1])
+	}
+}
+
+func TestLoadMigrationsRequiresUpFile(t *testing.T) {
+	source := fstest.MapFS{
+		"001_create.down.sql": {Data: []byte("DROP TABLE t;")},
+	}
+
+	if _, err := LoadMigrations(source); err == nil {
+		t.Fatal("Expected error for migration without up file")
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("Expected embedded migrations to load, got %v", err)
+	}
+
+	for i, migration := range loaded {
+		if migration.Version != i+1 {
+			t.Fatalf("Expected contiguous versions, found %d at position %d", migration.Version, i)
+		}
+		if migration.Down == "" {
+			t.Fatalf("Expected down migration for %03d_%s", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestMigratorStatus(t *testing.T) {
+	if _, err := testRepo.Migrator().Up(); err != nil {
+		t.Fatalf("Expected migrations to apply, got %v", err)
+	}
+
+	statuses, err := testRepo.Migrator().Status()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Fatalf("Expected %03d_%s to be applied", status.Version, status.Name)
+		}
+	}
+}
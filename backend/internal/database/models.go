@@ -7,7 +7,7 @@ import (
 // RoutineLog represents a daily routine log entry
 type RoutineLog struct {
 	ID               int       `json:"id,omitempty" db:"id"`
-	UserID           string    `json:"user_id" db:"user_id"` // Changed from int to string
+	UserID           string    `json:"user_id" db:"user_id"`
 	SleepHours       float64   `json:"sleep_hours" db:"sleep_hours"`
 	MealTimes        []string  `json:"meal_times" db:"meal_times"`
 	ScreenTime       float64   `json:"screen_time" db:"screen_time"`
@@ -19,6 +19,20 @@ type RoutineLog struct {
 	LogDate          string    `json:"log_date" db:"log_date"`
 	CreatedAt        time.Time `json:"created_at,omitempty" db:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	// Sleep is derived from BedTime and WakeUpTime by the server; nil when they cannot be related
+	Sleep *SleepMetrics `json:"sleep,omitempty"`
+}
+
+// SleepMetrics describe the sleep window between bed and wake-up time
+type SleepMetrics struct {
+	DurationHours float64 `json:"duration_hours" db:"sleep_duration_hours"`
+	// MidpointMinutes is the middle of the window in minutes from midnight, in [-720, 720),
+	// so nights centred around midnight average and drift without wrapping
+	MidpointMinutes int `json:"midpoint_minutes" db:"sleep_midpoint_minutes"`
+	// Midpoint is MidpointMinutes as an HH:MM clock time
+	Midpoint string `json:"midpoint"`
+	// DiscrepancyHours is the reported sleep_hours minus DurationHours
+	DiscrepancyHours float64 `json:"discrepancy_hours" db:"sleep_discrepancy_hours"`
 }
 
 // AIReport represents an AI analysis report for a routine log
@@ -31,19 +45,142 @@ type AIReport struct {
 	Recommendations   []string  `json:"recommendations" db:"recommendations"`
 	AIServiceResponse string    `json:"ai_service_response" db:"ai_service_response"`
 	CreatedAt         time.Time `json:"created_at,omitempty" db:"created_at"`
+	// Source names the analyzer whose verdict the report holds, e.g. "ai_service" or "local_rules"
+	Source string `json:"source" db:"source"`
+	// Baseline compares the log with the user's own history; nil when there was too little of it
+	Baseline *BaselineReport `json:"baseline,omitempty" db:"baseline"`
+	// Analyses lists the answer of every analyzer that ran on the log
+	Analyses []AnalyzerResult `json:"analyses,omitempty" db:"analyses"`
+	// ModelName, ModelVersion, ModelAccuracy and FeatureNames identify the model behind the verdict;
+	// they are empty when the analyzer has no trained model
+	ModelName     string   `json:"model_name,omitempty" db:"model_name"`
+	ModelVersion  string   `json:"model_version,omitempty" db:"model_version"`
+	ModelAccuracy *float64 `json:"model_accuracy,omitempty" db:"model_accuracy"`
+	FeatureNames  []string `json:"feature_names,omitempty" db:"feature_names"`
+}
+
+// AnalyzerResult is the answer of one analyzer of the analyzer chain
+type AnalyzerResult struct {
+	Analyzer        string   `json:"analyzer"`
+	IsAnomaly       bool     `json:"is_anomaly"`
+	ConfidenceScore float64  `json:"confidence_score"`
+	AnomalyType     string   `json:"anomaly_type"`
+	Recommendations []string `json:"recommendations"`
+	ModelName       string   `json:"model_name,omitempty"`
+	ModelVersion    string   `json:"model_version,omitempty"`
+	// Fallback is set when Analyzer answered because the analyzers before it failed
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// BaselineReport compares a routine log with the user's rolling personal baseline
+type BaselineReport struct {
+	// WindowDays is how many days before the log's log_date the baseline covers
+	WindowDays int `json:"window_days"`
+	// SampleSize is the number of logs in the window
+	SampleSize int     `json:"sample_size"`
+	ZThreshold float64 `json:"z_threshold"`
+	// IsDeviation is set when any metric is at least ZThreshold standard deviations from its mean
+	IsDeviation bool `json:"is_deviation"`
+	// Deviations names the metrics that deviate, in metric order
+	Deviations []string `json:"deviations"`
+	// Metrics holds the comparison of every metric with enough history, by field name
+	Metrics map[string]MetricBaseline `json:"metrics"`
+}
+
+// MetricBaseline compares one field of a routine log with the user's baseline for it
+type MetricBaseline struct {
+	Value  float64 `json:"value"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	ZScore float64 `json:"z_score"`
 }
 
 // InsightResponse combines routine log and AI report
+// AIReport is nil when AI analysis was unavailable for the log
 type InsightResponse struct {
 	RoutineLog RoutineLog `json:"routine_log"`
-	AIReport   AIReport   `json:"ai_report"`
+	AIReport   *AIReport  `json:"ai_report"`
+}
+
+// LogCursor is the keyset position of a routine log in (log_date DESC, id DESC) order
+type LogCursor struct {
+	LogDate string
+	ID      int
+}
+
+// LogFilter selects a page of a user's routine logs, newest log_date first
+type LogFilter struct {
+	UserID string
+	From   string     // inclusive lower log_date bound (YYYY-MM-DD), optional
+	To     string     // inclusive upper log_date bound (YYYY-MM-DD), optional
+	After  *LogCursor // only logs after this position, optional
+	// ModelVersion keeps only logs whose latest AI report came from this model version, optional
+	ModelVersion string
+	Limit        int
+}
+
+// TrendFilter selects the routine logs aggregated into trend buckets
+type TrendFilter struct {
+	UserID string
+	Period string // date_trunc field of each bucket: "week" or "month"
+	From   string // inclusive lower log_date bound (YYYY-MM-DD), optional
+	To     string // inclusive upper log_date bound (YYYY-MM-DD), optional
+}
+
+// MetricSummary aggregates one routine log field over a trend bucket
+type MetricSummary struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+}
+
+// TrendBucket aggregates a user's routine logs over one week or month
+type TrendBucket struct {
+	// PeriodStart is the first day of the bucket (YYYY-MM-DD); weeks start on Monday
+	PeriodStart      string        `json:"period_start"`
+	LogCount         int           `json:"log_count"`
+	SleepHours       MetricSummary `json:"sleep_hours"`
+	ScreenTime       MetricSummary `json:"screen_time"`
+	ExerciseDuration MetricSummary `json:"exercise_duration"`
+	WaterIntake      MetricSummary `json:"water_intake"`
+	StressLevel      MetricSummary `json:"stress_level"`
+	// AnalyzedCount is the number of logs with an AI report; AnomalyCount those flagged as anomalies
+	AnalyzedCount int `json:"analyzed_count"`
+	AnomalyCount  int `json:"anomaly_count"`
+	// AnomalyRate is AnomalyCount / AnalyzedCount, nil when no log in the bucket was analyzed
+	AnomalyRate *float64 `json:"anomaly_rate"`
+}
+
+// AnalysisJob is a routine log waiting for (re-)analysis by the AI service
+type AnalysisJob struct {
+	ID           int `json:"id"`
+	RoutineLogID int `json:"routine_log_id"`
+	Attempts     int `json:"attempts"`
+	// Version changes whenever the log is queued again while its job is pending
+	Version   int       `json:"version"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AnalysisQueueStats summarizes the pending analysis queue
+type AnalysisQueueStats struct {
+	Depth            int        `json:"depth"`        // all pending jobs
+	Ready            int        `json:"ready"`        // jobs due to run now
+	InProgress       int        `json:"in_progress"`  // jobs claimed by a worker
+	Failed           int        `json:"failed"`       // jobs that used up their attempts and are no longer retried
+	MaxAttempts      int        `json:"max_attempts"` // highest attempt count among pending jobs
+	AttemptLimit     int        `json:"attempt_limit"`
+	OldestEnqueuedAt *time.Time `json:"oldest_enqueued_at"`
 }
 
 // User represents a system user (simplified for privacy)
 type User struct {
-	ID        string    `json:"id,omitempty" db:"id"` // Changed from int to string
-	Username  string    `json:"username" db:"username"`
-	DeviceID  string    `json:"device_id" db:"device_id"`
-	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	ID       string `json:"id,omitempty" db:"id"`
+	Username string `json:"username" db:"username"`
+	// DeviceID identifies the device the user registered from; it is never serialized
+	DeviceID string `json:"-" db:"device_id"`
+	// SecretHash is the SHA-256 hash of the secret issued at registration; it is never serialized
+	SecretHash string    `json:"-" db:"secret_hash"`
+	CreatedAt  time.Time `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty" db:"updated_at"`
 }
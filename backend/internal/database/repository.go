@@ -1,19 +1,25 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 )
 
 type Repository struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *slog.Logger
 }
 
-func NewRepository(dbURL string) (*Repository, error) {
+func NewRepository(dbURL string, logger *slog.Logger) (*Repository, error) {
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -23,8 +29,8 @@ func NewRepository(dbURL string) (*Repository, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Println("✅ Connected to database")
-	return &Repository{db: db}, nil
+	logger.Info("connected to database")
+	return &Repository{db: db, logger: logger}, nil
 }
 
 func (r *Repository) Close() error {
@@ -32,106 +38,434 @@ func (r *Repository) Close() error {
 }
 
 // SaveRoutineLog saves a routine log to the database
-func (r *Repository) SaveRoutineLog(log RoutineLog) (int, error) {
-	query := `
-		INSERT INTO routine_logs (user_id, sleep_hours, meal_times, screen_time, exercise_duration, 
-		                         wake_up_time, bed_time, water_intake, stress_level, log_date)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		RETURNING id`
+// There is at most one log per user per day: when one already exists for log.LogDate,
+// overwrite replaces it in place (keeping its ID) and otherwise ErrConflict is returned.
+// A replaced log loses its AI report in the same transaction, since the report describes the old data.
+// created reports whether a new row was inserted; updatedAt is the saved row's updated_at.
+func (r *Repository) SaveRoutineLog(ctx context.Context, log RoutineLog, overwrite bool) (id int, created bool, updatedAt time.Time, err error) {
+	query := insertRoutineLog
+	if overwrite {
+		query += `
+		ON CONFLICT (user_id, log_date) DO UPDATE
+		SET sleep_hours = EXCLUDED.sleep_hours, meal_times = EXCLUDED.meal_times,
+		    screen_time = EXCLUDED.screen_time, exercise_duration = EXCLUDED.exercise_duration,
+		    wake_up_time = EXCLUDED.wake_up_time, bed_time = EXCLUDED.bed_time,
+		    water_intake = EXCLUDED.water_intake, stress_level = EXCLUDED.stress_level,
+		    sleep_duration_hours = EXCLUDED.sleep_duration_hours,
+		    sleep_midpoint_minutes = EXCLUDED.sleep_midpoint_minutes,
+		    sleep_discrepancy_hours = EXCLUDED.sleep_discrepancy_hours`
+	}
+	// xmax is only zero for freshly inserted rows, which tells an insert from an update
+	query += `
+		RETURNING id, (xmax = 0), updated_at`
+
+	values, err := routineLogValues(log)
+	if err != nil {
+		return 0, false, time.Time{}, err
+	}
+	args := append([]interface{}{log.UserID}, values...)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, time.Time{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&id, &created, &updatedAt)
 
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, false, time.Time{}, fmt.Errorf("failed to save routine log: %w", ErrConflict)
+		}
+		return 0, false, time.Time{}, fmt.Errorf("failed to save routine log: %w", err)
+	}
+
+	if !created {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM ai_reports WHERE routine_log_id = $1`, id); err != nil {
+			return 0, false, time.Time{}, fmt.Errorf("failed to delete AI report: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, time.Time{}, fmt.Errorf("failed to commit routine log: %w", err)
+	}
+
+	return id, created, updatedAt, nil
+}
+
+// insertRoutineLog inserts a routine log with log.UserID followed by routineLogValues(log)
+const insertRoutineLog = `
+	INSERT INTO routine_logs (user_id, sleep_hours, meal_times, screen_time, exercise_duration,
+	                         wake_up_time, bed_time, water_intake, stress_level, log_date,
+	                         sleep_duration_hours, sleep_midpoint_minutes, sleep_discrepancy_hours)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+
+// routineLogValues returns the values of every stored routine log column from sleep_hours on,
+// in insertRoutineLog order
+func routineLogValues(log RoutineLog) ([]interface{}, error) {
 	mealTimesJSON, err := json.Marshal(log.MealTimes)
 	if err != nil {
-		return 0, fmt.Errorf("failed to marshal meal times: %w", err)
+		return nil, fmt.Errorf("failed to marshal meal times: %w", err)
+	}
+
+	return append([]interface{}{
+		log.SleepHours, mealTimesJSON, log.ScreenTime, log.ExerciseDuration,
+		log.WakeUpTime, log.BedTime, log.WaterIntake, log.StressLevel, log.LogDate,
+	}, sleepMetricArgs(log.Sleep)...), nil
+}
+
+// MergeRoutineLog applies merge to the user's routine log for logDate and saves the result
+// The stored log stays locked from the read until the merged log is written, so concurrent
+// merges of the same day apply one after the other instead of losing each other's fields.
+// Without a stored log, merge starts from an empty log for the day; a replaced log loses its
+// AI report like in SaveRoutineLog. An error from merge aborts the merge and is returned as is.
+func (r *Repository) MergeRoutineLog(ctx context.Context, userID string, logDate string, merge func(*RoutineLog) error) (merged *RoutineLog, created bool, err error) {
+	merged, created, err = r.mergeRoutineLog(ctx, userID, logDate, merge)
+	if errors.Is(err, errMergeRaced) {
+		// Another request created the day in the meantime; its row can be locked now
+		merged, created, err = r.mergeRoutineLog(ctx, userID, logDate, merge)
+	}
+	return merged, created, err
+}
+
+// errMergeRaced is returned by mergeRoutineLog when the day was created after it found no log
+var errMergeRaced = errors.New("routine log created concurrently")
+
+// mergeRoutineLog makes one attempt of MergeRoutineLog in its own transaction
+func (r *Repository) mergeRoutineLog(ctx context.Context, userID string, logDate string, merge func(*RoutineLog) error) (*RoutineLog, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + routineLogColumns + ` FROM routine_logs WHERE user_id = $1 AND log_date = $2 FOR UPDATE`
 
-	var id int
-	err = r.db.QueryRow(query,
-		log.UserID, log.SleepHours, mealTimesJSON, log.ScreenTime, log.ExerciseDuration,
-		log.WakeUpTime, log.BedTime, log.WaterIntake, log.StressLevel, log.LogDate).Scan(&id)
+	existing, err := scanRoutineLog(tx.QueryRowContext(ctx, query, userID, logDate))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, fmt.Errorf("failed to lock routine log: %w", err)
+	}
+
+	log := RoutineLog{UserID: userID, LogDate: logDate}
+	if existing != nil {
+		log = *existing
+	}
+	if err := merge(&log); err != nil {
+		return nil, false, err
+	}
 
+	var saved *RoutineLog
+	if existing != nil {
+		log.ID = existing.ID
+		saved, err = updateRoutineLog(ctx, tx, log)
+	} else {
+		saved, err = insertRoutineLogIfAbsent(ctx, tx, log)
+	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to save routine log: %w", err)
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit routine log: %w", err)
 	}
 
-	return id, nil
+	return saved, existing == nil, nil
 }
 
-// SaveAIReport saves an AI report to the database
-func (r *Repository) SaveAIReport(report AIReport) error {
-	query := `
-		INSERT INTO ai_reports (routine_log_id, is_anomaly, confidence_score, anomaly_type, 
-		                       recommendations, ai_service_response)
-		VALUES ($1, $2, $3, $4, $5, $6)`
+// insertRoutineLogIfAbsent inserts log within tx, returning errMergeRaced when its day already exists
+func insertRoutineLogIfAbsent(ctx context.Context, tx *sql.Tx, log RoutineLog) (*RoutineLog, error) {
+	values, err := routineLogValues(log)
+	if err != nil {
+		return nil, err
+	}
 
-	recommendationsJSON, err := json.Marshal(report.Recommendations)
+	query := insertRoutineLog + `
+		ON CONFLICT (user_id, log_date) DO NOTHING
+		RETURNING ` + routineLogColumns
+
+	inserted, err := scanRoutineLog(tx.QueryRowContext(ctx, query, append([]interface{}{log.UserID}, values...)...))
 	if err != nil {
-		return fmt.Errorf("failed to marshal recommendations: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errMergeRaced
+		}
+		return nil, fmt.Errorf("failed to save routine log: %w", err)
 	}
 
-	_, err = r.db.Exec(query,
-		report.RoutineLogID, report.IsAnomaly, report.ConfidenceScore,
-		report.AnomalyType, recommendationsJSON, report.AIServiceResponse)
+	return inserted, nil
+}
 
+// SaveAIReport saves an AI report to the database
+func (r *Repository) SaveAIReport(ctx context.Context, report AIReport) error {
+	args, err := aiReportArgs(report)
 	if err != nil {
+		return err
+	}
+
+	if _, err := r.db.ExecContext(ctx, insertAIReport, args...); err != nil {
 		return fmt.Errorf("failed to save AI report: %w", err)
 	}
 
 	return nil
 }
 
-// GetRoutineLogWithAIReport retrieves a routine log with its AI report
-func (r *Repository) GetRoutineLogWithAIReport(logID int) (*InsightResponse, error) {
-	// Get routine log
-	logQuery := `SELECT id, user_id, sleep_hours, meal_times, screen_time, exercise_duration,
-	                    wake_up_time, bed_time, water_intake, stress_level, log_date, created_at
-	             FROM routine_logs WHERE id = $1`
+// insertAIReport inserts an AI report with the arguments returned by aiReportArgs
+const insertAIReport = `
+	INSERT INTO ai_reports (routine_log_id, is_anomaly, confidence_score, anomaly_type,
+	                       recommendations, ai_service_response, baseline, source, analyses,
+	                       model_name, model_version, model_accuracy, feature_names)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+
+// aiReportArgs returns the insertAIReport arguments for report
+// A nil baseline, empty analyses and missing model provenance are stored as SQL NULL
+func aiReportArgs(report AIReport) ([]interface{}, error) {
+	recommendationsJSON, err := json.Marshal(report.Recommendations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal recommendations: %w", err)
+	}
+
+	var baselineJSON interface{}
+	if report.Baseline != nil {
+		encoded, err := json.Marshal(report.Baseline)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal baseline: %w", err)
+		}
+		baselineJSON = encoded
+	}
+
+	var analysesJSON interface{}
+	if len(report.Analyses) > 0 {
+		encoded, err := json.Marshal(report.Analyses)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal analyses: %w", err)
+		}
+		analysesJSON = encoded
+	}
+
+	var featureNamesJSON interface{}
+	if len(report.FeatureNames) > 0 {
+		encoded, err := json.Marshal(report.FeatureNames)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal feature names: %w", err)
+		}
+		featureNamesJSON = encoded
+	}
+
+	return []interface{}{
+		report.RoutineLogID, report.IsAnomaly, report.ConfidenceScore,
+		report.AnomalyType, recommendationsJSON, report.AIServiceResponse, baselineJSON, report.Source,
+		analysesJSON,
+		sql.NullString{String: report.ModelName, Valid: report.ModelName != ""},
+		sql.NullString{String: report.ModelVersion, Valid: report.ModelVersion != ""},
+		report.ModelAccuracy, featureNamesJSON,
+	}, nil
+}
+
+// routineLogColumns is the column list read by scanRoutineLog
+const routineLogColumns = `id, user_id, sleep_hours, meal_times, screen_time, exercise_duration,
+	wake_up_time, bed_time, water_intake, stress_level, to_char(log_date, 'YYYY-MM-DD'),
+	created_at, updated_at, sleep_duration_hours, sleep_midpoint_minutes, sleep_discrepancy_hours`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	var routineLog RoutineLog
+// scanRoutineLog scans a row selected with routineLogColumns
+func scanRoutineLog(row rowScanner) (*RoutineLog, error) {
+	var log RoutineLog
 	var mealTimesJSON []byte
-	err := r.db.QueryRow(logQuery, logID).Scan(
-		&routineLog.ID, &routineLog.UserID, &routineLog.SleepHours, &mealTimesJSON,
-		&routineLog.ScreenTime, &routineLog.ExerciseDuration, &routineLog.WakeUpTime,
-		&routineLog.BedTime, &routineLog.WaterIntake, &routineLog.StressLevel,
-		&routineLog.LogDate, &routineLog.CreatedAt)
+	var sleep nullSleepMetrics
+	dest := []interface{}{
+		&log.ID, &log.UserID, &log.SleepHours, &mealTimesJSON, &log.ScreenTime,
+		&log.ExerciseDuration, &log.WakeUpTime, &log.BedTime, &log.WaterIntake,
+		&log.StressLevel, &log.LogDate, &log.CreatedAt, &log.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, sleep.dest()...)...); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(mealTimesJSON, &log.MealTimes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal meal times: %w", err)
+	}
+	log.Sleep = sleep.metrics()
+
+	return &log, nil
+}
+
+// GetRoutineLog retrieves a single routine log
+func (r *Repository) GetRoutineLog(ctx context.Context, logID int) (*RoutineLog, error) {
+	query := `SELECT ` + routineLogColumns + ` FROM routine_logs WHERE id = $1`
+
+	routineLog, err := scanRoutineLog(r.db.QueryRowContext(ctx, query, logID))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get routine log: %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get routine log: %w", err)
+	}
+
+	return routineLog, nil
+}
+
+// GetRoutineLogByDate retrieves a user's routine log for a day (YYYY-MM-DD)
+func (r *Repository) GetRoutineLogByDate(ctx context.Context, userID string, logDate string) (*RoutineLog, error) {
+	query := `SELECT ` + routineLogColumns + ` FROM routine_logs WHERE user_id = $1 AND log_date = $2`
+
+	routineLog, err := scanRoutineLog(r.db.QueryRowContext(ctx, query, userID, logDate))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get routine log: %w", ErrNotFound)
+		}
 		return nil, fmt.Errorf("failed to get routine log: %w", err)
 	}
 
-	if err := json.Unmarshal(mealTimesJSON, &routineLog.MealTimes); err != nil {
+	return routineLog, nil
+}
+
+// insightQuery selects routine logs joined with their latest AI report, if any
+// The report columns are aliased so id and created_at stay unambiguous
+const insightQuery = `SELECT ` + routineLogColumns + `,
+	       ar.report_id, ar.is_anomaly, ar.confidence_score, ar.anomaly_type,
+	       ar.recommendations, ar.ai_service_response, ar.report_created_at, ar.baseline,
+	       ar.source, ar.analyses, ar.model_name, ar.model_version, ar.model_accuracy, ar.feature_names
+	FROM routine_logs
+	LEFT JOIN LATERAL (
+	    SELECT id AS report_id, is_anomaly, confidence_score, anomaly_type,
+	           recommendations, ai_service_response, created_at AS report_created_at, baseline,
+	           source, analyses, model_name, model_version, model_accuracy, feature_names
+	    FROM ai_reports
+	    WHERE routine_log_id = routine_logs.id
+	    ORDER BY created_at DESC, id DESC
+	    LIMIT 1
+	) ar ON true`
+
+// scanInsight scans a row selected with insightQuery
+// AIReport is nil when the log has not been analyzed
+func scanInsight(row rowScanner) (*InsightResponse, error) {
+	var log RoutineLog
+	var mealTimesJSON []byte
+	var sleep nullSleepMetrics
+	var reportID sql.NullInt64
+	var isAnomaly sql.NullBool
+	var confidenceScore sql.NullFloat64
+	var anomalyType, aiServiceResponse, source, modelName, modelVersion sql.NullString
+	var modelAccuracy sql.NullFloat64
+	var recommendationsJSON, baselineJSON, analysesJSON, featureNamesJSON []byte
+	var reportCreatedAt sql.NullTime
+	dest := []interface{}{
+		&log.ID, &log.UserID, &log.SleepHours, &mealTimesJSON, &log.ScreenTime,
+		&log.ExerciseDuration, &log.WakeUpTime, &log.BedTime, &log.WaterIntake,
+		&log.StressLevel, &log.LogDate, &log.CreatedAt, &log.UpdatedAt,
+	}
+	dest = append(dest, sleep.dest()...)
+	dest = append(dest, &reportID, &isAnomaly, &confidenceScore, &anomalyType,
+		&recommendationsJSON, &aiServiceResponse, &reportCreatedAt, &baselineJSON, &source, &analysesJSON,
+		&modelName, &modelVersion, &modelAccuracy, &featureNamesJSON)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(mealTimesJSON, &log.MealTimes); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal meal times: %w", err)
 	}
+	log.Sleep = sleep.metrics()
+
+	insight := &InsightResponse{RoutineLog: log}
+	if !reportID.Valid {
+		return insight, nil
+	}
+
+	insight.AIReport = &AIReport{
+		ID:                int(reportID.Int64),
+		RoutineLogID:      log.ID,
+		IsAnomaly:         isAnomaly.Bool,
+		ConfidenceScore:   confidenceScore.Float64,
+		AnomalyType:       anomalyType.String,
+		AIServiceResponse: aiServiceResponse.String,
+		CreatedAt:         reportCreatedAt.Time,
+		Source:            source.String,
+		ModelName:         modelName.String,
+		ModelVersion:      modelVersion.String,
+	}
+	if modelAccuracy.Valid {
+		insight.AIReport.ModelAccuracy = &modelAccuracy.Float64
+	}
+	if err := json.Unmarshal(recommendationsJSON, &insight.AIReport.Recommendations); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recommendations: %w", err)
+	}
+	if len(baselineJSON) > 0 {
+		if err := json.Unmarshal(baselineJSON, &insight.AIReport.Baseline); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal baseline: %w", err)
+		}
+	}
+	if len(analysesJSON) > 0 {
+		if err := json.Unmarshal(analysesJSON, &insight.AIReport.Analyses); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal analyses: %w", err)
+		}
+	}
+	if len(featureNamesJSON) > 0 {
+		if err := json.Unmarshal(featureNamesJSON, &insight.AIReport.FeatureNames); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal feature names: %w", err)
+		}
+	}
 
-	// Get AI report
-	aiQuery := `SELECT id, routine_log_id, is_anomaly, confidence_score, anomaly_type,
-	                   recommendations, ai_service_response, created_at
-	            FROM ai_reports WHERE routine_log_id = $1`
+	return insight, nil
+}
 
-	var aiReport AIReport
-	var recommendationsJSON []byte
-	err = r.db.QueryRow(aiQuery, logID).Scan(
-		&aiReport.ID, &aiReport.RoutineLogID, &aiReport.IsAnomaly, &aiReport.ConfidenceScore,
-		&aiReport.AnomalyType, &recommendationsJSON, &aiReport.AIServiceResponse, &aiReport.CreatedAt)
+// GetRoutineLogWithAIReport retrieves a routine log of userID with its AI report
+// Another user's log is not found, so log IDs cannot be probed for existence
+// The AI report is nil when the log has not been analyzed
+func (r *Repository) GetRoutineLogWithAIReport(ctx context.Context, userID string, logID int) (*InsightResponse, error) {
+	insight, err := scanInsight(r.db.QueryRowContext(ctx, insightQuery+` WHERE id = $1 AND user_id = $2`, logID, userID))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get AI report: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get routine log: %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get insight: %w", err)
 	}
 
-	if err := json.Unmarshal(recommendationsJSON, &aiReport.Recommendations); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal recommendations: %w", err)
+	return insight, nil
+}
+
+// GetInsightsByUser retrieves a page of a user's routine logs with their AI reports in one query
+// It pages exactly like GetRoutineLogsByUser; logs without an AI report are included with a nil report
+func (r *Repository) GetInsightsByUser(ctx context.Context, filter LogFilter) ([]InsightResponse, error) {
+	where, args := logFilterClause(filter)
+	query := insightQuery + `
+	          WHERE ` + where + `
+	          ORDER BY log_date DESC, id DESC 
+	          LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query insights: %w", err)
 	}
+	defer rows.Close()
+
+	var insights []InsightResponse
+	for rows.Next() {
+		insight, err := scanInsight(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan insight: %w", err)
+		}
 
-	return &InsightResponse{RoutineLog: routineLog, AIReport: aiReport}, nil
+		insights = append(insights, *insight)
+	}
+
+	return insights, rows.Err()
 }
 
-// GetRoutineLogsByUser retrieves routine logs for a specific user
-func (r *Repository) GetRoutineLogsByUser(userID int, limit int) ([]RoutineLog, error) {
-	query := `SELECT id, user_id, sleep_hours, meal_times, screen_time, exercise_duration,
-	                 wake_up_time, bed_time, water_intake, stress_level, log_date, created_at
+// GetRoutineLogsByUser retrieves a page of routine logs for a specific user
+// Logs are ordered by log_date then id, newest first, so filter.After gives stable keyset pagination
+func (r *Repository) GetRoutineLogsByUser(ctx context.Context, filter LogFilter) ([]RoutineLog, error) {
+	where, args := logFilterClause(filter)
+	query := `SELECT ` + routineLogColumns + `
 	          FROM routine_logs 
-	          WHERE user_id = $1 
-	          ORDER BY created_at DESC 
-	          LIMIT $2`
+	          WHERE ` + where + `
+	          ORDER BY log_date DESC, id DESC 
+	          LIMIT $` + strconv.Itoa(len(args))
 
-	rows, err := r.db.Query(query, userID, limit)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query routine logs: %w", err)
 	}
@@ -139,27 +473,236 @@ func (r *Repository) GetRoutineLogsByUser(userID int, limit int) ([]RoutineLog,
 
 	var logs []RoutineLog
 	for rows.Next() {
-		var log RoutineLog
-		var mealTimesJSON []byte
-		err := rows.Scan(
-			&log.ID, &log.UserID, &log.SleepHours, &mealTimesJSON, &log.ScreenTime,
-			&log.ExerciseDuration, &log.WakeUpTime, &log.BedTime, &log.WaterIntake,
-			&log.StressLevel, &log.LogDate, &log.CreatedAt)
+		log, err := scanRoutineLog(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan routine log: %w", err)
 		}
 
-		if err := json.Unmarshal(mealTimesJSON, &log.MealTimes); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal meal times: %w", err)
+		logs = append(logs, *log)
+	}
+
+	return logs, rows.Err()
+}
+
+// logFilterClause builds the WHERE conditions for filter over routine_logs
+// The limit is appended as the last argument
+func logFilterClause(filter LogFilter) (string, []interface{}) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	if filter.From != "" {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("log_date >= $%d", len(args)))
+	}
+	if filter.To != "" {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("log_date <= $%d", len(args)))
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.LogDate, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(log_date, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	if filter.ModelVersion != "" {
+		// Compares with the latest report only, like insightQuery
+		args = append(args, filter.ModelVersion)
+		conditions = append(conditions, fmt.Sprintf(`(SELECT model_version FROM ai_reports
+		    WHERE routine_log_id = routine_logs.id
+		    ORDER BY created_at DESC, id DESC
+		    LIMIT 1) = $%d`, len(args)))
+	}
+	args = append(args, filter.Limit)
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// UpdateRoutineLog overwrites the fields of an existing routine log owned by log.UserID
+// A log of another user is ErrNotFound, like a missing one
+// Its AI report is deleted in the same transaction, since it describes the old data
+// The updated_at trigger on routine_logs stamps the change
+func (r *Repository) UpdateRoutineLog(ctx context.Context, log RoutineLog) (*RoutineLog, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	updated, err := updateRoutineLog(ctx, tx, log)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit routine log update: %w", err)
+	}
+
+	return updated, nil
+}
+
+// updateRoutineLog overwrites the routine log with log.ID and log.UserID and deletes its AI report within tx
+func updateRoutineLog(ctx context.Context, tx *sql.Tx, log RoutineLog) (*RoutineLog, error) {
+	query := `
+		UPDATE routine_logs
+		SET sleep_hours = $2, meal_times = $3, screen_time = $4, exercise_duration = $5,
+		    wake_up_time = $6, bed_time = $7, water_intake = $8, stress_level = $9, log_date = $10,
+		    sleep_duration_hours = $11, sleep_midpoint_minutes = $12, sleep_discrepancy_hours = $13
+		WHERE id = $1 AND user_id = $14
+		RETURNING ` + routineLogColumns
+
+	values, err := routineLogValues(log)
+	if err != nil {
+		return nil, err
+	}
+	args := append(append([]interface{}{log.ID}, values...), log.UserID)
+
+	updated, err := scanRoutineLog(tx.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to update routine log: %w", ErrNotFound)
+		}
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("failed to update routine log: %w", ErrConflict)
+		}
+		return nil, fmt.Errorf("failed to update routine log: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM ai_reports WHERE routine_log_id = $1`, log.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete AI report: %w", err)
+	}
+
+	return updated, nil
+}
+
+// PatchRoutineLog applies patch to the routine log with logID owned by userID and saves the result
+// The log stays locked from the read until the patched log is written, like in MergeRoutineLog,
+// so concurrent patches of the same log apply one after the other instead of losing each other's
+// fields. A log of another user is ErrNotFound, like a missing one, and its AI report is deleted
+// like in UpdateRoutineLog. An error from patch aborts the patch and is returned as is.
+func (r *Repository) PatchRoutineLog(ctx context.Context, userID string, logID int, patch func(*RoutineLog) error) (*RoutineLog, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + routineLogColumns + ` FROM routine_logs WHERE id = $1 AND user_id = $2 FOR UPDATE`
+
+	log, err := scanRoutineLog(tx.QueryRowContext(ctx, query, logID, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to lock routine log: %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to lock routine log: %w", err)
+	}
+
+	if err := patch(log); err != nil {
+		return nil, err
+	}
+	log.ID = logID
+	log.UserID = userID
+
+	patched, err := updateRoutineLog(ctx, tx, *log)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit routine log: %w", err)
+	}
+
+	return patched, nil
+}
+
+// DeleteRoutineLog deletes a routine log owned by userID; its AI report is removed by ON DELETE CASCADE
+// A log of another user is ErrNotFound, like a missing one
+func (r *Repository) DeleteRoutineLog(ctx context.Context, userID string, logID int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM routine_logs WHERE id = $1 AND user_id = $2`, logID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete routine log: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete routine log: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("failed to delete routine log: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// ReplaceAIReport replaces any existing AI report for the routine log with report
+func (r *Repository) ReplaceAIReport(ctx context.Context, report AIReport) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceAIReport(ctx, tx, report); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit AI report: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceAIReportIfUnchanged replaces the routine log's AI report with report unless the log
+// was updated or deleted since updatedAt; saved reports whether the report was written
+// The log row stays locked until the report is written, so an update cannot slip in between
+func (r *Repository) ReplaceAIReportIfUnchanged(ctx context.Context, report AIReport, updatedAt time.Time) (saved bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var unchanged bool
+	err = tx.QueryRowContext(ctx, `SELECT updated_at = $2 FROM routine_logs WHERE id = $1 FOR UPDATE`,
+		report.RoutineLogID, updatedAt).Scan(&unchanged)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
 		}
+		return false, fmt.Errorf("failed to lock routine log: %w", err)
+	}
+	if !unchanged {
+		return false, nil
+	}
 
-		logs = append(logs, log)
+	if err := replaceAIReport(ctx, tx, report); err != nil {
+		return false, err
 	}
 
-	return logs, nil
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit AI report: %w", err)
+	}
+
+	return true, nil
+}
+
+// replaceAIReport deletes the routine log's AI report and inserts report within tx
+func replaceAIReport(ctx context.Context, tx *sql.Tx, report AIReport) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM ai_reports WHERE routine_log_id = $1`, report.RoutineLogID); err != nil {
+		return fmt.Errorf("failed to delete AI report: %w", err)
+	}
+
+	args, err := aiReportArgs(report)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, insertAIReport, args...); err != nil {
+		return fmt.Errorf("failed to save AI report: %w", err)
+	}
+
+	return nil
 }
 
 // Ping checks database connectivity
-func (r *Repository) Ping() error {
-	return r.db.Ping()
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+const minutesPerDay = 24 * 60
+
+// NewSleepMetrics returns the metrics of a sleep window starting bedMinutes after midnight
+// and lasting windowMinutes, for a log that reported reportedHours of sleep
+func NewSleepMetrics(bedMinutes, windowMinutes int, reportedHours float64) *SleepMetrics {
+	duration := float64(windowMinutes) / 60
+	midpoint := (bedMinutes+windowMinutes/2+minutesPerDay/2)%minutesPerDay - minutesPerDay/2
+
+	return &SleepMetrics{
+		DurationHours:    roundHours(duration),
+		MidpointMinutes:  midpoint,
+		Midpoint:         clockTime(midpoint),
+		DiscrepancyHours: roundHours(reportedHours - duration),
+	}
+}
+
+// roundHours rounds to two decimals, as the metrics are stored
+func roundHours(hours float64) float64 {
+	return math.Round(hours*100) / 100
+}
+
+// clockTime formats minutes from midnight, possibly negative, as HH:MM
+func clockTime(minutes int) string {
+	minutes = (minutes%minutesPerDay + minutesPerDay) % minutesPerDay
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// nullSleepMetrics scans the nullable sleep metric columns of routine_logs
+type nullSleepMetrics struct {
+	duration    sql.NullFloat64
+	midpoint    sql.NullInt64
+	discrepancy sql.NullFloat64
+}
+
+// dest returns the scan destinations, in the order of routineLogColumns
+func (n *nullSleepMetrics) dest() []interface{} {
+	return []interface{}{&n.duration, &n.midpoint, &n.discrepancy}
+}
+
+// metrics returns the scanned metrics, or nil when the log has none
+func (n *nullSleepMetrics) metrics() *SleepMetrics {
+	if !n.duration.Valid || !n.midpoint.Valid || !n.discrepancy.Valid {
+		return nil
+	}
+	return &SleepMetrics{
+		DurationHours:    n.duration.Float64,
+		MidpointMinutes:  int(n.midpoint.Int64),
+		Midpoint:         clockTime(int(n.midpoint.Int64)),
+		DiscrepancyHours: n.discrepancy.Float64,
+	}
+}
+
+// sleepMetricArgs returns the column values to store for metrics, NULL when nil
+func sleepMetricArgs(metrics *SleepMetrics) []interface{} {
+	if metrics == nil {
+		return []interface{}{nil, nil, nil}
+	}
+	return []interface{}{metrics.DurationHours, metrics.MidpointMinutes, metrics.DiscrepancyHours}
+}
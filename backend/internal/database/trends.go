@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// trendMetrics are the routine_logs columns summarized per trend bucket, in TrendBucket order
+var trendMetrics = []string{"sleep_hours", "screen_time", "exercise_duration", "water_intake", "stress_level"}
+
+// GetRoutineTrends aggregates a user's routine logs into weekly or monthly buckets, oldest first
+// Each log counts towards the anomaly rate with its latest AI report; unanalyzed logs are left out of it
+// Buckets without logs are omitted
+func (r *Repository) GetRoutineTrends(ctx context.Context, filter TrendFilter) ([]TrendBucket, error) {
+	columns := make([]string, 0, len(trendMetrics))
+	for _, metric := range trendMetrics {
+		columns = append(columns, fmt.Sprintf("round(avg(%[1]s), 2), min(%[1]s), max(%[1]s)", metric))
+	}
+
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID, filter.Period}
+	if filter.From != "" {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("log_date >= $%d", len(args)))
+	}
+	if filter.To != "" {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("log_date <= $%d", len(args)))
+	}
+
+	query := `
+		SELECT to_char(date_trunc($2, log_date::timestamp), 'YYYY-MM-DD') AS period_start,
+		       count(*),
+		       ` + strings.Join(columns, ",\n\t\t       ") + `,
+		       count(ar.is_anomaly),
+		       count(*) FILTER (WHERE ar.is_anomaly),
+		       round(avg(ar.is_anomaly::int), 4)
+		FROM routine_logs
+		LEFT JOIN LATERAL (
+		    SELECT is_anomaly
+		    FROM ai_reports
+		    WHERE routine_log_id = routine_logs.id
+		    ORDER BY created_at DESC, id DESC
+		    LIMIT 1
+		) ar ON true
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY period_start
+		ORDER BY period_start`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query routine trends: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []TrendBucket
+	for rows.Next() {
+		var bucket TrendBucket
+		var anomalyRate sql.NullFloat64
+		dest := []interface{}{&bucket.PeriodStart, &bucket.LogCount}
+		for _, summary := range []*MetricSummary{
+			&bucket.SleepHours, &bucket.ScreenTime, &bucket.ExerciseDuration,
+			&bucket.WaterIntake, &bucket.StressLevel,
+		} {
+			dest = append(dest, &summary.Mean, &summary.Min, &summary.Max)
+		}
+		dest = append(dest, &bucket.AnalyzedCount, &bucket.AnomalyCount, &anomalyRate)
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan trend bucket: %w", err)
+		}
+		if anomalyRate.Valid {
+			bucket.AnomalyRate = &anomalyRate.Float64
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
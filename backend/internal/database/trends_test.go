@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetRoutineTrends(t *testing.T) {
+	// 2024-05-06 is a Monday: the first two logs share a week, the third starts the next one
+	logs := []struct {
+		date       string
+		sleepHours float64
+		stress     int
+		anomaly    *bool
+	}{
+		{"2024-05-06", 6.0, 2, boolPtr(true)},
+		{"2024-05-08", 8.0, 6, boolPtr(false)},
+		{"2024-05-13", 7.5, 4, nil},
+	}
+
+	for _, l := range logs {
+		id, _, _, err := testRepo.SaveRoutineLog(context.Background(), RoutineLog{
+			UserID:      "1",
+			SleepHours:  l.sleepHours,
+			MealTimes:   []string{"08:00"},
+			ScreenTime:  3.0,
+			WakeUpTime:  "07:00",
+			BedTime:     "23:00",
+			WaterIntake: 2.0,
+			StressLevel: l.stress,
+			LogDate:     l.date,
+		}, true)
+		if err != nil {
+			t.Fatalf("Failed to save routine log: %v", err)
+		}
+
+		if _, err := testRepo.db.Exec(`DELETE FROM ai_reports WHERE routine_log_id = $1`, id); err != nil {
+			t.Fatalf("Failed to clear AI reports: %v", err)
+		}
+		if l.anomaly != nil {
+			err = testRepo.SaveAIReport(context.Background(), AIReport{
+				RoutineLogID:      id,
+				IsAnomaly:         *l.anomaly,
+				AnomalyType:       "normal_routine",
+				Recommendations:   []string{},
+				AIServiceResponse: "{}",
+			})
+			if err != nil {
+				t.Fatalf("Failed to save AI report: %v", err)
+			}
+		}
+	}
+
+	weeks, err := testRepo.GetRoutineTrends(context.Background(), TrendFilter{
+		UserID: "1", Period: "week", From: "2024-05-06", To: "2024-05-19",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(weeks) != 2 {
+		t.Fatalf("Expected 2 weekly buckets, got %d", len(weeks))
+	}
+
+	first := weeks[0]
+	if first.PeriodStart != "2024-05-06" || first.LogCount != 2 {
+		t.Fatalf("Expected 2 logs in the week of 2024-05-06, got %d in %s", first.LogCount, first.PeriodStart)
+	}
+	if expected := (MetricSummary{Mean: 7.0, Min: 6.0, Max: 8.0}); first.SleepHours != expected {
+		t.Fatalf("Expected sleep hours %+v, got %+v", expected, first.SleepHours)
+	}
+	if expected := (MetricSummary{Mean: 4.0, Min: 2.0, Max: 6.0}); first.StressLevel != expected {
+		t.Fatalf("Expected stress level %+v, got %+v", expected, first.StressLevel)
+	}
+	if first.AnalyzedCount != 2 || first.AnomalyCount != 1 || first.AnomalyRate == nil || *first.AnomalyRate != 0.5 {
+		t.Fatalf("Expected 1 anomaly in 2 analyzed logs, got %d in %d (rate %v)", first.AnomalyCount, first.AnalyzedCount, first.AnomalyRate)
+	}
+
+	// A week without analyzed logs has no anomaly rate
+	if second := weeks[1]; second.PeriodStart != "2024-05-13" || second.AnalyzedCount != 0 || second.AnomalyRate != nil {
+		t.Fatalf("Expected an unanalyzed week of 2024-05-13, got %+v", second)
+	}
+
+	months, err := testRepo.GetRoutineTrends(context.Background(), TrendFilter{
+		UserID: "1", Period: "month", From: "2024-05-06", To: "2024-05-19",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(months) != 1 || months[0].PeriodStart != "2024-05-01" || months[0].LogCount != 3 {
+		t.Fatalf("Expected one bucket of 3 logs for May 2024, got %+v", months)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
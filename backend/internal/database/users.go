@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// CreateUser registers a new device-bound user
+func (r *Repository) CreateUser(ctx context.Context, user User) (*User, error) {
+	query := `
+		INSERT INTO users (id, username, device_id, secret_hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, user.ID, user.Username, user.DeviceID,
+		sql.NullString{String: user.SecretHash, Valid: user.SecretHash != ""}).Scan(&user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("failed to create user: %w", ErrConflict)
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// GetUserByID retrieves a user by ID
+func (r *Repository) GetUserByID(ctx context.Context, userID string) (*User, error) {
+	query := `SELECT id, username, device_id, secret_hash, created_at, updated_at
+	          FROM users WHERE id = $1`
+
+	return scanUser(r.db.QueryRowContext(ctx, query, userID))
+}
+
+// UpdateUsername renames a user
+func (r *Repository) UpdateUsername(ctx context.Context, userID string, username string) (*User, error) {
+	query := `UPDATE users SET username = $2
+	          WHERE id = $1
+	          RETURNING id, username, device_id, secret_hash, created_at, updated_at`
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, userID, username))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("failed to update username: %w", ErrConflict)
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ClaimUserSecret sets the secret hash of the user with userID and deviceID if it has none yet
+// Users registered before secrets existed use it once to obtain a secret; it returns ErrNotFound
+// when no user without a secret has both the ID and the device
+func (r *Repository) ClaimUserSecret(ctx context.Context, userID string, deviceID string, secretHash string) (*User, error) {
+	query := `UPDATE users SET secret_hash = $3
+	          WHERE id = $1 AND device_id = $2 AND secret_hash IS NULL
+	          RETURNING id, username, device_id, secret_hash, created_at, updated_at`
+
+	return scanUser(r.db.QueryRowContext(ctx, query, userID, deviceID, secretHash))
+}
+
+// scanUser scans a single users row
+func scanUser(row *sql.Row) (*User, error) {
+	var user User
+	var deviceID, secretHash sql.NullString
+	err := row.Scan(&user.ID, &user.Username, &deviceID, &secretHash, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get user: %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	user.DeviceID = deviceID.String
+	user.SecretHash = secretHash.String
+	return &user, nil
+}
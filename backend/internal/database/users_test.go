@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCreateAndGetUser(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	user := User{
+		ID:       fmt.Sprintf("user_test_%d", suffix),
+		Username: fmt.Sprintf("Test-User-%d", suffix%100000000),
+		DeviceID: fmt.Sprintf("device_%d", suffix),
+		// Any 64-character hex string stands in for the hash of a secret
+		SecretHash: fmt.Sprintf("%064x", suffix),
+	}
+
+	created, err := testRepo.CreateUser(context.Background(), user)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if created.CreatedAt.IsZero() {
+		t.Fatal("Expected created_at to be set")
+	}
+
+	fetched, err := testRepo.GetUserByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if fetched.DeviceID != user.DeviceID {
+		t.Fatalf("Expected device ID %s, got %s", user.DeviceID, fetched.DeviceID)
+	}
+
+	if fetched.SecretHash != user.SecretHash {
+		t.Fatalf("Expected secret hash %s, got %s", user.SecretHash, fetched.SecretHash)
+	}
+
+	// Registering the same device again must conflict
+	duplicate := user
+	duplicate.ID = user.ID + "_dup"
+	duplicate.Username = user.Username + "x"
+	if _, err := testRepo.CreateUser(context.Background(), duplicate); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+}
+
+func TestUpdateUsername(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	user := User{
+		ID:       fmt.Sprintf("user_rename_%d", suffix),
+		Username: fmt.Sprintf("Rename-Me-%d", suffix%100000000),
+		DeviceID: fmt.Sprintf("device_rename_%d", suffix),
+	}
+
+	if _, err := testRepo.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	updated, err := testRepo.UpdateUsername(context.Background(), user.ID, user.Username+"-2")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if updated.Username != user.Username+"-2" {
+		t.Fatalf("Expected username %s-2, got %s", user.Username, updated.Username)
+	}
+}
+
+func TestGetUserByIDNotFound(t *testing.T) {
+	_, err := testRepo.GetUserByID(context.Background(), "user_does_not_exist")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestClaimUserSecret(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	user := User{
+		ID:       fmt.Sprintf("user_legacy_%d", suffix),
+		Username: fmt.Sprintf("Legacy-User-%d", suffix%100000000),
+		DeviceID: fmt.Sprintf("device_legacy_%d", suffix),
+	}
+
+	// A user registered before secrets existed has no secret hash
+	if _, err := testRepo.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	// The device ID alone does not match the user
+	if _, err := testRepo.ClaimUserSecret(context.Background(), "user_other", user.DeviceID, fmt.Sprintf("%064x", suffix)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	secretHash := fmt.Sprintf("%064x", suffix)
+	claimed, err := testRepo.ClaimUserSecret(context.Background(), user.ID, user.DeviceID, secretHash)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if claimed.ID != user.ID || claimed.SecretHash != secretHash {
+		t.Fatalf("Expected %s with secret hash %s, got %s with %s", user.ID, secretHash, claimed.ID, claimed.SecretHash)
+	}
+
+	// Once a secret is set it cannot be claimed again
+	if _, err := testRepo.ClaimUserSecret(context.Background(), user.ID, user.DeviceID, fmt.Sprintf("%064x", suffix+1)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"lifepattern-api/internal/apperror"
+	"lifepattern-api/internal/auth"
+	"lifepattern-api/internal/services"
+)
+
+// TokenIssuer issues bearer tokens for authenticated users
+type TokenIssuer interface {
+	Issue(userID string) (string, time.Time, error)
+}
+
+type AuthHandler struct {
+	userService services.UserServiceInterface
+	tokens      TokenIssuer
+}
+
+func NewAuthHandler(userService services.UserServiceInterface, tokens TokenIssuer) *AuthHandler {
+	return &AuthHandler{
+		userService: userService,
+		tokens:      tokens,
+	}
+}
+
+// TokenResponse is returned by POST /auth/token
+type TokenResponse struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	UserID      string    `json:"user_id"`
+}
+
+// IssueToken handles POST /auth/token requests
+// A registered user logs in with their user ID and the secret issued at registration
+func (h *AuthHandler) IssueToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
+	}
+
+	var credentials struct {
+		UserID string `json:"user_id"`
+		Secret string `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		apperror.Write(w, r, apperror.ErrInvalidJSON)
+		return
+	}
+
+	if credentials.UserID == "" {
+		apperror.Write(w, r, apperror.Validation("user_id", "user_id is required"))
+		return
+	}
+	if credentials.Secret == "" {
+		apperror.Write(w, r, apperror.Validation("secret", "secret is required"))
+		return
+	}
+
+	user, err := h.userService.Authenticate(r.Context(), credentials.UserID, credentials.Secret)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	token, expiresAt, err := h.tokens.Issue(user.ID)
+	if err != nil {
+		apperror.Write(w, r, fmt.Errorf("failed to issue token: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresAt:   expiresAt,
+		UserID:      user.ID,
+	})
+}
+
+// authorizeUser returns the authenticated user ID from the request context
+// A non-empty requestedUserID that differs from the authenticated user is forbidden
+func authorizeUser(w http.ResponseWriter, r *http.Request, requestedUserID string) (string, bool) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		apperror.Write(w, r, apperror.New(apperror.CodeUnauthorized, "authentication required"))
+		return "", false
+	}
+
+	if requestedUserID != "" && requestedUserID != userID {
+		apperror.Write(w, r, services.ErrForbidden)
+		return "", false
+	}
+
+	return userID, true
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"lifepattern-api/internal/apperror"
+	"lifepattern-api/internal/auth"
+)
+
+// withUser returns a copy of req authenticated as userID
+func withUser(req *http.Request, userID string) *http.Request {
+	return req.WithContext(auth.WithUserID(req.Context(), userID))
+}
+
+// decodeError decodes the JSON error envelope of w
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) apperror.Response {
+	t.Helper()
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Fatalf("Expected Content-Type application/json, got %s", contentType)
+	}
+
+	var response apperror.Response
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	return response
+}
+
+func newTestTokenManager(t *testing.T) *auth.TokenManager {
+	tokens, err := auth.NewTokenManager("test-secret", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create token manager: %v", err)
+	}
+	return tokens
+}
+
+func TestIssueToken(t *testing.T) {
+	tokens := newTestTokenManager(t)
+	handler := NewAuthHandler(NewMockUserService(), tokens)
+
+	body := `{"user_id": "user_1", "secret": "secret_1"}`
+	req := httptest.NewRequest("POST", "/auth/token", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+
+	handler.IssueToken(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response TokenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.TokenType != "Bearer" {
+		t.Fatalf("Expected token type Bearer, got %s", response.TokenType)
+	}
+
+	claims, err := tokens.Verify(response.AccessToken)
+	if err != nil {
+		t.Fatalf("Expected issued token to verify, got %v", err)
+	}
+
+	if claims.UserID != "user_1" {
+		t.Fatalf("Expected token for user_1, got %s", claims.UserID)
+	}
+}
+
+func TestIssueTokenWrongSecret(t *testing.T) {
+	handler := NewAuthHandler(NewMockUserService(), newTestTokenManager(t))
+
+	// Knowing the user and device IDs is not enough
+	bodies := []string{
+		`{"user_id": "user_1", "secret": "someone_elses_secret"}`,
+		`{"user_id": "user_1", "secret": "device_1"}`,
+	}
+
+	for _, body := range bodies {
+		req := httptest.NewRequest("POST", "/auth/token", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+
+		handler.IssueToken(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status 401 for %s, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestIssueTokenMissingFields(t *testing.T) {
+	handler := NewAuthHandler(NewMockUserService(), newTestTokenManager(t))
+
+	req := httptest.NewRequest("POST", "/auth/token", bytes.NewBufferString(`{"user_id": "user_1"}`))
+	w := httptest.NewRecorder()
+
+	handler.IssueToken(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+}
@@ -5,60 +5,102 @@ import (
 	"net/http"
 	"time"
 
+	"lifepattern-api/internal/apperror"
 	"lifepattern-api/internal/services"
 )
 
 type HealthHandler struct {
-	repo      services.RepositoryInterface
+	health    services.HealthReporter
 	aiService services.AIServiceInterface
 }
 
-func NewHealthHandler(repo services.RepositoryInterface, aiService services.AIServiceInterface) *HealthHandler {
+func NewHealthHandler(health services.HealthReporter, aiService services.AIServiceInterface) *HealthHandler {
 	return &HealthHandler{
-		repo:      repo,
+		health:    health,
 		aiService: aiService,
 	}
 }
 
-// HealthCheck handles GET /health requests
-func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+// Livez handles GET /livez requests
+// It only reports that the process is serving HTTP and never checks dependencies
+func (h *HealthHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Readyz handles GET /readyz requests
+// The API can serve requests as long as the database is reachable; the AI service is
+// optional because logs are saved and queued for analysis while it is down
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
 		return
 	}
 
-	// Check database connection
-	dbStatus := "healthy"
-	if err := h.repo.Ping(); err != nil {
-		dbStatus = "unhealthy"
+	database := h.health.Dependencies()[services.DependencyDatabase]
+
+	status := "ready"
+	code := http.StatusOK
+	if database.Status != services.DependencyHealthy {
+		status = "not_ready"
+		code = http.StatusServiceUnavailable
 	}
 
-	// Check AI service
-	aiStatus := "healthy"
-	if err := h.aiService.CheckHealth(); err != nil {
-		aiStatus = "unhealthy"
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   status,
+		"database": database,
+	})
+}
+
+// HealthCheck handles GET /health requests
+// It reports the cached status, latency and last success of every dependency:
+// "healthy" when all are up, "degraded" (still 200) when only the AI service is down,
+// and "unhealthy" (503) when the database is down
+func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
 	}
 
+	dependencies := h.health.Dependencies()
+	dbStatus := dependencies[services.DependencyDatabase].Status
+	aiStatus := dependencies[services.DependencyAIService].Status
+
 	// Determine overall status
 	overallStatus := "healthy"
-	if dbStatus == "unhealthy" || aiStatus == "unhealthy" {
+	if dbStatus != services.DependencyHealthy {
 		overallStatus = "unhealthy"
+	} else if aiStatus != services.DependencyHealthy {
+		overallStatus = "degraded"
 	}
 
 	response := map[string]interface{}{
-		"status":     overallStatus,
-		"database":   dbStatus,
-		"ai_service": aiStatus,
-		"timestamp":  time.Now().Format(time.RFC3339),
+		"status":       overallStatus,
+		"database":     dbStatus,
+		"ai_service":   aiStatus,
+		"dependencies": dependencies,
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}
+
+	// Report the circuit breaker when the AI service is guarded by one
+	if reporter, ok := h.aiService.(services.CircuitReporter); ok {
+		response["ai_circuit"] = reporter.CircuitState()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 
 	// Set appropriate status code
-	if overallStatus == "healthy" {
-		w.WriteHeader(http.StatusOK)
-	} else {
+	if overallStatus == "unhealthy" {
 		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
 	}
 
 	json.NewEncoder(w).Encode(response)
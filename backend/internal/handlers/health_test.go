@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
 	"lifepattern-api/internal/services"
 )
 
@@ -22,23 +26,107 @@ func NewMockHealthRepository(shouldFail bool) *MockHealthRepository {
 	}
 }
 
-func (m *MockHealthRepository) SaveRoutineLog(log database.RoutineLog) (int, error) {
+func (m *MockHealthRepository) SaveRoutineLog(ctx context.Context, log database.RoutineLog, overwrite bool) (int, bool, time.Time, error) {
+	return 0, false, time.Time{}, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) SaveAIReport(ctx context.Context, report database.AIReport) error {
+	return errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) GetRoutineLogWithAIReport(ctx context.Context, userID string, logID int) (*database.InsightResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) GetRoutineLogsByUser(ctx context.Context, filter database.LogFilter) ([]database.RoutineLog, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) GetInsightsByUser(ctx context.Context, filter database.LogFilter) ([]database.InsightResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) GetRoutineTrends(ctx context.Context, filter database.TrendFilter) ([]database.TrendBucket, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) GetRoutineLog(ctx context.Context, logID int) (*database.RoutineLog, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) MergeRoutineLog(ctx context.Context, userID string, logDate string, merge func(*database.RoutineLog) error) (*database.RoutineLog, bool, error) {
+	return nil, false, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) PatchRoutineLog(ctx context.Context, userID string, logID int, patch func(*database.RoutineLog) error) (*database.RoutineLog, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) UpdateRoutineLog(ctx context.Context, log database.RoutineLog) (*database.RoutineLog, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) DeleteRoutineLog(ctx context.Context, userID string, logID int) error {
+	return errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) ReplaceAIReport(ctx context.Context, report database.AIReport) error {
+	return errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) ReplaceAIReportIfUnchanged(ctx context.Context, report database.AIReport, updatedAt time.Time) (bool, error) {
+	return false, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) EnqueueAnalysis(ctx context.Context, logID int) error {
+	return errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) EnqueueUnanalyzedLogs(ctx context.Context, minAge time.Duration) (int, error) {
 	return 0, errors.New("not implemented")
 }
 
-func (m *MockHealthRepository) SaveAIReport(report database.AIReport) error {
+func (m *MockHealthRepository) ClaimAnalysisJobs(ctx context.Context, limit int, lease time.Duration) ([]database.AnalysisJob, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) CompleteAnalysisJob(ctx context.Context, jobID int, version int) (bool, error) {
+	return false, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) RetryAnalysisJob(ctx context.Context, jobID int, delay time.Duration, lastError string) error {
 	return errors.New("not implemented")
 }
 
-func (m *MockHealthRepository) GetRoutineLogWithAIReport(logID int) (*database.InsightResponse, error) {
+func (m *MockHealthRepository) FailAnalysisJob(ctx context.Context, jobID int, version int, lastError string) error {
+	return errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) GetAnalysisQueueStats(ctx context.Context) (*database.AnalysisQueueStats, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) GetUserAnalysisQueueStats(ctx context.Context, userID string) (*database.AnalysisQueueStats, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) CreateUser(ctx context.Context, user database.User) (*database.User, error) {
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockHealthRepository) GetRoutineLogsByUser(userID int, limit int) ([]database.RoutineLog, error) {
+func (m *MockHealthRepository) GetUserByID(ctx context.Context, userID string) (*database.User, error) {
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockHealthRepository) Ping() error {
+func (m *MockHealthRepository) UpdateUsername(ctx context.Context, userID string, username string) (*database.User, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) ClaimUserSecret(ctx context.Context, userID string, deviceID string, secretHash string) (*database.User, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockHealthRepository) Ping(ctx context.Context) error {
 	if m.shouldFail {
 		return errors.New("database ping failed")
 	}
@@ -60,28 +148,38 @@ func NewMockHealthAIService(shouldFail bool) *MockHealthAIService {
 	}
 }
 
-func (m *MockHealthAIService) AnalyzeRoutine(routineLog database.RoutineLog) (*services.AIServiceResponse, error) {
+func (m *MockHealthAIService) AnalyzeRoutine(ctx context.Context, routineLog database.RoutineLog) (*services.AIServiceResponse, error) {
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockHealthAIService) CheckHealth() error {
+func (m *MockHealthAIService) CheckHealth(ctx context.Context) error {
 	if m.shouldFail {
 		return errors.New("AI service health check failed")
 	}
 	return nil
 }
 
+// newTestHealthHandler checks the mocks once and serves the cached results
+func newTestHealthHandler(repo services.RepositoryInterface, aiService services.AIServiceInterface) *HealthHandler {
+	monitor := services.NewHealthMonitor(repo, aiService, services.HealthCheckConfig{
+		Interval: time.Minute,
+		Timeout:  time.Second,
+	}, logging.Discard())
+	monitor.CheckNow(context.Background())
+	return NewHealthHandler(monitor, aiService)
+}
+
 func TestNewHealthHandler(t *testing.T) {
-	mockRepo := NewMockHealthRepository(false)
+	monitor := services.NewHealthMonitor(NewMockHealthRepository(false), NewMockHealthAIService(false), services.HealthCheckConfig{}, logging.Discard())
 	mockAI := NewMockHealthAIService(false)
-	handler := NewHealthHandler(mockRepo, mockAI)
+	handler := NewHealthHandler(monitor, mockAI)
 
 	if handler == nil {
 		t.Fatal("Expected handler to be created")
 	}
 
-	if handler.repo != mockRepo {
-		t.Fatal("Expected repository to be set")
+	if handler.health != monitor {
+		t.Fatal("Expected health reporter to be set")
 	}
 
 	if handler.aiService != mockAI {
@@ -90,9 +188,7 @@ func TestNewHealthHandler(t *testing.T) {
 }
 
 func TestHealthCheck(t *testing.T) {
-	mockRepo := NewMockHealthRepository(false)
-	mockAI := NewMockHealthAIService(false)
-	handler := NewHealthHandler(mockRepo, mockAI)
+	handler := newTestHealthHandler(NewMockHealthRepository(false), NewMockHealthAIService(false))
 
 	req := httptest.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
@@ -125,10 +221,39 @@ func TestHealthCheck(t *testing.T) {
 	}
 }
 
+func TestHealthCheckReportsDependencies(t *testing.T) {
+	handler := newTestHealthHandler(NewMockHealthRepository(false), NewMockHealthAIService(true))
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+
+	handler.HealthCheck(w, req)
+
+	var response struct {
+		Dependencies map[string]services.DependencyHealth `json:"dependencies"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	database := response.Dependencies["database"]
+	if database.Status != services.DependencyHealthy || database.CheckedAt == nil || database.LastSuccess == nil {
+		t.Fatalf("Expected healthy database with check times, got %+v", database)
+	}
+
+	aiService := response.Dependencies["ai_service"]
+	if aiService.Status != services.DependencyUnhealthy || aiService.LastSuccess != nil {
+		t.Fatalf("Expected unhealthy AI service that never succeeded, got %+v", aiService)
+	}
+
+	// Check errors can name internal hosts and are never served
+	if strings.Contains(w.Body.String(), "health check failed") {
+		t.Fatalf("Expected no check error in the response, got %s", w.Body.String())
+	}
+}
+
 func TestHealthCheckInvalidMethod(t *testing.T) {
-	mockRepo := NewMockHealthRepository(false)
-	mockAI := NewMockHealthAIService(false)
-	handler := NewHealthHandler(mockRepo, mockAI)
+	handler := newTestHealthHandler(NewMockHealthRepository(false), NewMockHealthAIService(false))
 
 	req := httptest.NewRequest("POST", "/health", nil)
 	w := httptest.NewRecorder()
@@ -141,9 +266,7 @@ func TestHealthCheckInvalidMethod(t *testing.T) {
 }
 
 func TestHealthCheckDatabaseFailure(t *testing.T) {
-	mockRepo := NewMockHealthRepository(true) // Database will fail
-	mockAI := NewMockHealthAIService(false)
-	handler := NewHealthHandler(mockRepo, mockAI)
+	handler := newTestHealthHandler(NewMockHealthRepository(true), NewMockHealthAIService(false)) // Database will fail
 
 	req := httptest.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
@@ -173,17 +296,16 @@ func TestHealthCheckDatabaseFailure(t *testing.T) {
 }
 
 func TestHealthCheckAIServiceFailure(t *testing.T) {
-	mockRepo := NewMockHealthRepository(false)
-	mockAI := NewMockHealthAIService(true) // AI service will fail
-	handler := NewHealthHandler(mockRepo, mockAI)
+	handler := newTestHealthHandler(NewMockHealthRepository(false), NewMockHealthAIService(true)) // AI service will fail
 
 	req := httptest.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
 
 	handler.HealthCheck(w, req)
 
-	if w.Code != http.StatusServiceUnavailable {
-		t.Fatalf("Expected status 503, got %d", w.Code)
+	// The API keeps working without the AI service, so it is degraded but not unavailable
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
 	}
 
 	var response map[string]interface{}
@@ -191,8 +313,8 @@ func TestHealthCheckAIServiceFailure(t *testing.T) {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	if response["status"] != "unhealthy" {
-		t.Fatalf("Expected status 'unhealthy', got %v", response["status"])
+	if response["status"] != "degraded" {
+		t.Fatalf("Expected status 'degraded', got %v", response["status"])
 	}
 
 	if response["database"] != "healthy" {
@@ -205,9 +327,7 @@ func TestHealthCheckAIServiceFailure(t *testing.T) {
 }
 
 func TestHealthCheckBothFailures(t *testing.T) {
-	mockRepo := NewMockHealthRepository(true) // Database will fail
-	mockAI := NewMockHealthAIService(true)    // AI service will fail
-	handler := NewHealthHandler(mockRepo, mockAI)
+	handler := newTestHealthHandler(NewMockHealthRepository(true), NewMockHealthAIService(true)) // Both will fail
 
 	req := httptest.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
@@ -235,3 +355,123 @@ func TestHealthCheckBothFailures(t *testing.T) {
 		t.Fatalf("Expected ai_service 'unhealthy', got %v", response["ai_service"])
 	}
 }
+
+func TestLivez(t *testing.T) {
+	// Liveness does not depend on the database or the AI service
+	handler := newTestHealthHandler(NewMockHealthRepository(true), NewMockHealthAIService(true))
+
+	req := httptest.NewRequest("GET", "/livez", nil)
+	w := httptest.NewRecorder()
+
+	handler.Livez(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name     string
+		dbFails  bool
+		aiFails  bool
+		checked  bool
+		expected int
+		status   string
+	}{
+		{name: "all healthy", checked: true, expected: http.StatusOK, status: "ready"},
+		{name: "AI service down", aiFails: true, checked: true, expected: http.StatusOK, status: "ready"},
+		{name: "database down", dbFails: true, checked: true, expected: http.StatusServiceUnavailable, status: "not_ready"},
+		{name: "not checked yet", expected: http.StatusServiceUnavailable, status: "not_ready"},
+	}
+
+	for _, tt := range tests {
+		monitor := services.NewHealthMonitor(NewMockHealthRepository(tt.dbFails), NewMockHealthAIService(tt.aiFails), services.HealthCheckConfig{
+			Interval: time.Minute,
+			Timeout:  time.Second,
+		}, logging.Discard())
+		if tt.checked {
+			monitor.CheckNow(context.Background())
+		}
+		handler := NewHealthHandler(monitor, NewMockHealthAIService(tt.aiFails))
+
+		req := httptest.NewRequest("GET", "/readyz", nil)
+		w := httptest.NewRecorder()
+
+		handler.Readyz(w, req)
+
+		if w.Code != tt.expected {
+			t.Fatalf("%s: expected status %d, got %d", tt.name, tt.expected, w.Code)
+		}
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if response["status"] != tt.status {
+			t.Fatalf("%s: expected status %q, got %v", tt.name, tt.status, response["status"])
+		}
+	}
+}
+
+// Mock AI service guarded by a circuit breaker
+type MockCircuitAIService struct {
+	MockHealthAIService
+	snapshot services.CircuitSnapshot
+}
+
+func (m *MockCircuitAIService) CircuitState() services.CircuitSnapshot {
+	return m.snapshot
+}
+
+func TestHealthCheckReportsCircuitState(t *testing.T) {
+	openedAt := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	mockAI := &MockCircuitAIService{
+		MockHealthAIService: MockHealthAIService{shouldFail: true},
+		snapshot: services.CircuitSnapshot{
+			State:               services.CircuitOpen,
+			ConsecutiveFailures: 5,
+			OpenedAt:            &openedAt,
+		},
+	}
+	handler := newTestHealthHandler(NewMockHealthRepository(false), mockAI)
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+
+	handler.HealthCheck(w, req)
+
+	var response struct {
+		AIService string                   `json:"ai_service"`
+		AICircuit services.CircuitSnapshot `json:"ai_circuit"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.AIService != "unhealthy" {
+		t.Fatalf("Expected ai_service 'unhealthy', got %v", response.AIService)
+	}
+
+	if response.AICircuit.State != services.CircuitOpen || response.AICircuit.ConsecutiveFailures != 5 {
+		t.Fatalf("Expected open circuit with 5 failures, got %+v", response.AICircuit)
+	}
+}
+
+func TestHealthCheckWithoutCircuit(t *testing.T) {
+	handler := newTestHealthHandler(NewMockHealthRepository(false), NewMockHealthAIService(false))
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+
+	handler.HealthCheck(w, req)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if _, ok := response["ai_circuit"]; ok {
+		t.Fatal("Expected no ai_circuit without a circuit breaker")
+	}
+}
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/gorilla/mux"
+
+	"lifepattern-api/internal/apperror"
 	"lifepattern-api/internal/services"
 )
 
@@ -22,27 +24,33 @@ func NewInsightHandler(routineService services.RoutineServiceInterface) *Insight
 // GetInsight handles GET /insights requests
 func (h *InsightHandler) GetInsight(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
 		return
 	}
 
 	// Get log ID from query parameter
 	logIDStr := r.URL.Query().Get("log_id")
 	if logIDStr == "" {
-		http.Error(w, "log_id parameter required", http.StatusBadRequest)
+		apperror.Write(w, r, apperror.Validation("log_id", "log_id is required"))
 		return
 	}
 
 	logID, err := strconv.Atoi(logIDStr)
 	if err != nil {
-		http.Error(w, "Invalid log_id", http.StatusBadRequest)
+		apperror.Write(w, r, apperror.Validation("log_id", "log_id must be an integer"))
+		return
+	}
+
+	// Insights are only visible to the user who logged the routine;
+	// other users' logs are not found
+	userID, ok := authorizeUser(w, r, "")
+	if !ok {
 		return
 	}
 
-	// Get insight
-	insight, err := h.routineService.GetInsight(logID)
+	insight, err := h.routineService.GetInsight(r.Context(), userID, logID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving insight: %v", err), http.StatusInternalServerError)
+		apperror.Write(w, r, err)
 		return
 	}
 
@@ -53,44 +61,75 @@ func (h *InsightHandler) GetInsight(w http.ResponseWriter, r *http.Request) {
 // GetUserInsights handles GET /user-insights requests
 func (h *InsightHandler) GetUserInsights(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
 		return
 	}
 
-	// Get user ID from query parameter
-	userIDStr := r.URL.Query().Get("user_id")
-	if userIDStr == "" {
-		http.Error(w, "user_id parameter required", http.StatusBadRequest)
+	// The user comes from the bearer token; an explicit user_id must match it
+	userID, ok := authorizeUser(w, r, r.URL.Query().Get("user_id"))
+	if !ok {
 		return
 	}
 
-	userID, err := strconv.Atoi(userIDStr)
+	// Paging: limit (default 10, max 100), optional from/to log_date bounds and cursor
+	query, err := parseLogQuery(r, userID)
 	if err != nil {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		apperror.Write(w, r, err)
 		return
 	}
 
-	// Get limit from query parameter (default 10)
-	limitStr := r.URL.Query().Get("limit")
-	limit := 10
-	if limitStr != "" {
-		if limit, err = strconv.Atoi(limitStr); err != nil {
-			http.Error(w, "Invalid limit", http.StatusBadRequest)
-			return
-		}
+	// Optional model_version keeps the logs whose latest report came from that model version
+	if query.ModelVersion, err = parseModelVersion(r); err != nil {
+		apperror.Write(w, r, err)
+		return
 	}
 
 	// Get user insights
-	insights, err := h.routineService.GetUserInsights(userID, limit)
+	page, err := h.routineService.GetUserInsights(r.Context(), query)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving user insights: %v", err), http.StatusInternalServerError)
+		apperror.Write(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"user_id":  userID,
-		"insights": insights,
-		"count":    len(insights),
+		"user_id":     userID,
+		"insights":    page.Insights,
+		"count":       len(page.Insights),
+		"next_cursor": page.NextCursor,
 	})
 }
+
+// GetTrends handles GET /users/{id}/trends requests
+func (h *InsightHandler) GetTrends(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
+	}
+
+	userID := mux.Vars(r)["id"]
+	if !isValidUserID(userID) {
+		apperror.Write(w, r, errInvalidUserID)
+		return
+	}
+
+	if _, ok := authorizeUser(w, r, userID); !ok {
+		return
+	}
+
+	// Bucketing: period week (default) or month, optional from/to log_date bounds
+	query, err := parseTrendQuery(r, userID)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	trends, err := h.routineService.GetTrends(r.Context(), query)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trends)
+}
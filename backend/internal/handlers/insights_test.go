@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/gorilla/mux"
+
+	"lifepattern-api/internal/apperror"
 	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
 	"lifepattern-api/internal/services"
 )
 
@@ -15,6 +21,8 @@ import (
 type MockInsightRoutineService struct {
 	shouldFail bool
 	insight    *database.InsightResponse
+	query      services.LogQuery
+	trendQuery services.TrendQuery
 }
 
 func NewMockInsightRoutineService(shouldFail bool) *MockInsightRoutineService {
@@ -23,7 +31,7 @@ func NewMockInsightRoutineService(shouldFail bool) *MockInsightRoutineService {
 		insight: &database.InsightResponse{
 			RoutineLog: database.RoutineLog{
 				ID:               1,
-				UserID:           1,
+				UserID:           "user_1",
 				SleepHours:       8.0,
 				MealTimes:        []string{"07:30", "12:00", "18:30"},
 				ScreenTime:       4.5,
@@ -34,7 +42,7 @@ func NewMockInsightRoutineService(shouldFail bool) *MockInsightRoutineService {
 				StressLevel:      4,
 				LogDate:          "2024-01-15",
 			},
-			AIReport: database.AIReport{
+			AIReport: &database.AIReport{
 				ID:                1,
 				RoutineLogID:      1,
 				IsAnomaly:         true,
@@ -47,26 +55,68 @@ func NewMockInsightRoutineService(shouldFail bool) *MockInsightRoutineService {
 	}
 }
 
-func (m *MockInsightRoutineService) CreateRoutineLog(routineLog database.RoutineLog) (*services.CreateRoutineLogResponse, error) {
+func (m *MockInsightRoutineService) CreateRoutineLog(ctx context.Context, routineLog database.RoutineLog, mode services.ConflictMode) (*services.CreateRoutineLogResponse, error) {
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockInsightRoutineService) GetInsight(logID int) (*database.InsightResponse, error) {
+func (m *MockInsightRoutineService) GetInsight(ctx context.Context, userID string, logID int) (*database.InsightResponse, error) {
 	if m.shouldFail {
-		return nil, errors.New("service error")
+		return nil, errors.New("failed to get insight: sql: connection refused")
+	}
+	if logID != m.insight.RoutineLog.ID || userID != m.insight.RoutineLog.UserID {
+		return nil, services.ErrRoutineLogNotFound
 	}
 	return m.insight, nil
 }
 
-func (m *MockInsightRoutineService) GetUserRoutineLogs(userID int, limit int) ([]database.RoutineLog, error) {
+func (m *MockInsightRoutineService) GetRoutineLog(ctx context.Context, userID string, logID int) (*database.RoutineLog, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockInsightRoutineService) MergeRoutineLog(ctx context.Context, userID string, logDate string, patch []byte) (*services.CreateRoutineLogResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockInsightRoutineService) UpdateRoutineLog(ctx context.Context, userID string, routineLog database.RoutineLog) (*services.CreateRoutineLogResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockInsightRoutineService) PatchRoutineLog(ctx context.Context, userID string, logID int, patch []byte) (*services.CreateRoutineLogResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockInsightRoutineService) DeleteRoutineLog(ctx context.Context, userID string, logID int) error {
+	return errors.New("not implemented")
+}
+
+func (m *MockInsightRoutineService) GetUserRoutineLogs(ctx context.Context, query services.LogQuery) (*services.RoutineLogPage, error) {
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockInsightRoutineService) GetUserInsights(userID int, limit int) ([]database.InsightResponse, error) {
+func (m *MockInsightRoutineService) GetUserInsights(ctx context.Context, query services.LogQuery) (*services.InsightPage, error) {
 	if m.shouldFail {
 		return nil, errors.New("service error")
 	}
-	return []database.InsightResponse{*m.insight}, nil
+	if query.Cursor == "bad" {
+		return nil, services.ErrInvalidCursor
+	}
+	m.query = query
+	return &services.InsightPage{Insights: []database.InsightResponse{*m.insight}, NextCursor: "next"}, nil
+}
+
+func (m *MockInsightRoutineService) GetTrends(ctx context.Context, query services.TrendQuery) (*services.Trends, error) {
+	if m.shouldFail {
+		return nil, errors.New("service error")
+	}
+	m.trendQuery = query
+	rate := 0.5
+	return &services.Trends{
+		UserID: query.UserID,
+		Period: query.Period,
+		Buckets: []database.TrendBucket{
+			{PeriodStart: "2024-01-15", LogCount: 2, AnalyzedCount: 2, AnomalyCount: 1, AnomalyRate: &rate},
+		},
+	}, nil
 }
 
 func TestNewInsightHandler(t *testing.T) {
@@ -87,6 +137,7 @@ func TestGetInsight(t *testing.T) {
 	handler := NewInsightHandler(mockService)
 
 	req := httptest.NewRequest("GET", "/insights?log_id=1", nil)
+	req = withUser(req, "user_1")
 	w := httptest.NewRecorder()
 
 	handler.GetInsight(w, req)
@@ -139,6 +190,11 @@ func TestGetInsightMissingLogID(t *testing.T) {
 	if w.Code != http.StatusBadRequest {
 		t.Fatalf("Expected status 400, got %d", w.Code)
 	}
+
+	response := decodeError(t, w)
+	if response.Code != apperror.CodeValidation || response.Field != "log_id" {
+		t.Fatalf("Expected a validation error on log_id, got %+v", response)
+	}
 }
 
 func TestGetInsightInvalidLogID(t *testing.T) {
@@ -155,11 +211,28 @@ func TestGetInsightInvalidLogID(t *testing.T) {
 	}
 }
 
+func TestGetInsightOtherUser(t *testing.T) {
+	mockService := NewMockInsightRoutineService(false)
+	handler := NewInsightHandler(mockService)
+
+	req := httptest.NewRequest("GET", "/insights?log_id=1", nil)
+	req = withUser(req, "user_2")
+	w := httptest.NewRecorder()
+
+	handler.GetInsight(w, req)
+
+	// Another user's log must not be distinguishable from a missing one
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+}
+
 func TestGetInsightServiceError(t *testing.T) {
 	mockService := NewMockInsightRoutineService(true) // Service will fail
 	handler := NewInsightHandler(mockService)
 
 	req := httptest.NewRequest("GET", "/insights?log_id=1", nil)
+	req = withUser(req, "user_1")
 	w := httptest.NewRecorder()
 
 	handler.GetInsight(w, req)
@@ -167,4 +240,168 @@ func TestGetInsightServiceError(t *testing.T) {
 	if w.Code != http.StatusInternalServerError {
 		t.Fatalf("Expected status 500, got %d", w.Code)
 	}
+
+	// The internal error chain stays in the server log
+	response := decodeError(t, w)
+	if response.Code != apperror.CodeInternal || strings.Contains(response.Message, "sql") {
+		t.Fatalf("Expected a generic internal error, got %+v", response)
+	}
+}
+
+func TestGetInsightNotFound(t *testing.T) {
+	mockService := NewMockInsightRoutineService(false)
+	handler := NewInsightHandler(mockService)
+
+	req := httptest.NewRequest("GET", "/insights?log_id=999", nil)
+	req = withUser(req, "user_1")
+	req = req.WithContext(logging.WithRequestID(req.Context(), "req-123"))
+	w := httptest.NewRecorder()
+
+	handler.GetInsight(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+
+	response := decodeError(t, w)
+	if response.Code != apperror.CodeNotFound {
+		t.Fatalf("Expected code not_found, got %s", response.Code)
+	}
+	if response.RequestID != "req-123" {
+		t.Fatalf("Expected request_id req-123, got %s", response.RequestID)
+	}
+}
+
+func TestGetUserInsights(t *testing.T) {
+	mockService := NewMockInsightRoutineService(false)
+	handler := NewInsightHandler(mockService)
+
+	req := withUser(httptest.NewRequest("GET", "/user-insights?from=2024-01-01&limit=20", nil), "user_1")
+	w := httptest.NewRecorder()
+
+	handler.GetUserInsights(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	if mockService.query.From != "2024-01-01" || mockService.query.Limit != 20 {
+		t.Fatalf("Expected from and limit to be passed through, got %+v", mockService.query)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response["next_cursor"] != "next" {
+		t.Fatalf("Expected next_cursor next, got %v", response["next_cursor"])
+	}
+}
+
+func TestGetUserInsightsByModelVersion(t *testing.T) {
+	mockService := NewMockInsightRoutineService(false)
+	handler := NewInsightHandler(mockService)
+
+	req := withUser(httptest.NewRequest("GET", "/user-insights?model_version=1.1.0", nil), "user_1")
+	w := httptest.NewRecorder()
+
+	handler.GetUserInsights(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	if mockService.query.ModelVersion != "1.1.0" {
+		t.Fatalf("Expected model version 1.1.0 to be passed through, got %q", mockService.query.ModelVersion)
+	}
+
+	req = withUser(httptest.NewRequest("GET", "/user-insights?model_version="+strings.Repeat("9", 51), nil), "user_1")
+	w = httptest.NewRecorder()
+
+	handler.GetUserInsights(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for an overlong model version, got %d", w.Code)
+	}
+}
+
+func TestGetUserInsightsInvalidCursor(t *testing.T) {
+	handler := NewInsightHandler(NewMockInsightRoutineService(false))
+
+	req := withUser(httptest.NewRequest("GET", "/user-insights?cursor=bad", nil), "user_1")
+	w := httptest.NewRecorder()
+
+	handler.GetUserInsights(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestGetTrends(t *testing.T) {
+	mockService := NewMockInsightRoutineService(false)
+	handler := NewInsightHandler(mockService)
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/users/user_1/trends?period=month&from=2024-01-01", nil), map[string]string{"id": "user_1"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.GetTrends(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	expected := services.TrendQuery{UserID: "user_1", Period: services.TrendMonth, From: "2024-01-01"}
+	if mockService.trendQuery != expected {
+		t.Fatalf("Expected query %+v, got %+v", expected, mockService.trendQuery)
+	}
+
+	var response services.Trends
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.Period != services.TrendMonth || len(response.Buckets) != 1 || *response.Buckets[0].AnomalyRate != 0.5 {
+		t.Fatalf("Expected one monthly bucket with anomaly rate 0.5, got %+v", response)
+	}
+}
+
+func TestGetTrendsInvalidQuery(t *testing.T) {
+	handler := NewInsightHandler(NewMockInsightRoutineService(false))
+
+	tests := map[string]string{
+		"period": "/users/user_1/trends?period=year",
+		"from":   "/users/user_1/trends?from=01-01-2024",
+	}
+
+	for field, target := range tests {
+		req := mux.SetURLVars(httptest.NewRequest("GET", target, nil), map[string]string{"id": "user_1"})
+		req = withUser(req, "user_1")
+		w := httptest.NewRecorder()
+
+		handler.GetTrends(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", field, w.Code)
+		}
+		if response := decodeError(t, w); response.Field != field {
+			t.Fatalf("Expected field %s, got %s", field, response.Field)
+		}
+	}
+}
+
+func TestGetTrendsOtherUser(t *testing.T) {
+	handler := NewInsightHandler(NewMockInsightRoutineService(false))
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/users/user_2/trends", nil), map[string]string{"id": "user_2"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.GetTrends(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d", w.Code)
+	}
 }
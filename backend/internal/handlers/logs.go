@@ -2,14 +2,23 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/gorilla/mux"
+
+	"lifepattern-api/internal/apperror"
 	"lifepattern-api/internal/database"
 	"lifepattern-api/internal/services"
+	"lifepattern-api/internal/validation"
 )
 
+// maxLogBodyBytes bounds routine log request bodies; a day's log is well under 1 KiB
+const maxLogBodyBytes = 64 << 10
+
 type LogHandler struct {
 	routineService services.RoutineServiceInterface
 }
@@ -21,107 +30,232 @@ func NewLogHandler(routineService services.RoutineServiceInterface) *LogHandler
 }
 
 // CreateRoutineLog handles POST /log requests
+// The on_conflict query parameter (reject, replace or merge; default replace)
+// decides what happens when the user already logged the day
 func (h *LogHandler) CreateRoutineLog(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
+	}
+
+	mode, err := services.ParseConflictMode(r.URL.Query().Get("on_conflict"))
+	if err != nil {
+		apperror.Write(w, r, apperror.Validation("on_conflict", err.Error()))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxLogBodyBytes))
+	if err != nil {
+		apperror.Write(w, r, bodyError(err, apperror.Validation("", "invalid request body")))
 		return
 	}
 
 	var routineLog database.RoutineLog
-	if err := json.NewDecoder(r.Body).Decode(&routineLog); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := json.Unmarshal(body, &routineLog); err != nil {
+		apperror.Write(w, r, apperror.ErrInvalidJSON)
 		return
 	}
 
-	// Validate required fields
-	if err := validateRoutineLog(routineLog); err != nil {
-		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+	// Logs are always written for the authenticated user
+	userID, ok := authorizeUser(w, r, routineLog.UserID)
+	if !ok {
 		return
 	}
+	routineLog.UserID = userID
 
-	// Create routine log with AI analysis
-	response, err := h.routineService.CreateRoutineLog(routineLog)
+	if routineLog.LogDate == "" {
+		routineLog.LogDate = time.Now().Format(validation.DateLayout)
+	}
+
+	var response *services.CreateRoutineLogResponse
+	if mode == services.ConflictMerge {
+		// The service overlays the submitted fields onto the day's stored log and validates the result
+		if _, err := time.Parse(validation.DateLayout, routineLog.LogDate); err != nil {
+			apperror.Write(w, r, apperror.Validation("log_date", "log_date must be a YYYY-MM-DD date"))
+			return
+		}
+		response, err = h.routineService.MergeRoutineLog(r.Context(), userID, routineLog.LogDate, body)
+	} else {
+		// Validate every field, reporting all errors at once
+		if err := validation.RoutineLog(routineLog, time.Now()); err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		// Create routine log with AI analysis
+		response, err = h.routineService.CreateRoutineLog(r.Context(), routineLog, mode)
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating routine log: %v", err), http.StatusInternalServerError)
+		apperror.Write(w, r, err)
 		return
 	}
 
+	status := http.StatusOK
+	if response.Created {
+		status = http.StatusCreated
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(response)
 }
 
 // GetUserRoutineLogs handles GET /logs requests
 func (h *LogHandler) GetUserRoutineLogs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
 		return
 	}
 
-	// Get user ID from query parameter
-	userIDStr := r.URL.Query().Get("user_id")
-	if userIDStr == "" {
-		http.Error(w, "user_id parameter required", http.StatusBadRequest)
+	// The user comes from the bearer token; an explicit user_id must match it
+	userID, ok := authorizeUser(w, r, r.URL.Query().Get("user_id"))
+	if !ok {
 		return
 	}
 
-	userID, err := strconv.Atoi(userIDStr)
+	// Paging: limit (default 10, max 100), optional from/to log_date bounds and cursor
+	query, err := parseLogQuery(r, userID)
 	if err != nil {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		apperror.Write(w, r, err)
 		return
 	}
 
-	// Get limit from query parameter (default 10)
-	limitStr := r.URL.Query().Get("limit")
-	limit := 10
-	if limitStr != "" {
-		if limit, err = strconv.Atoi(limitStr); err != nil {
-			http.Error(w, "Invalid limit", http.StatusBadRequest)
-			return
-		}
-	}
-
 	// Get routine logs
-	logs, err := h.routineService.GetUserRoutineLogs(userID, limit)
+	page, err := h.routineService.GetUserRoutineLogs(r.Context(), query)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving routine logs: %v", err), http.StatusInternalServerError)
+		apperror.Write(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"user_id": userID,
-		"logs":    logs,
-		"count":   len(logs),
+		"user_id":     userID,
+		"logs":        page.Logs,
+		"count":       len(page.Logs),
+		"next_cursor": page.NextCursor,
 	})
 }
 
-// validateRoutineLog validates routine log data
-func validateRoutineLog(log database.RoutineLog) error {
-	if log.SleepHours < 0 || log.SleepHours > 24 {
-		return fmt.Errorf("sleep_hours must be between 0 and 24")
+// GetRoutineLog handles GET /logs/{id} requests
+func (h *LogHandler) GetRoutineLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
 	}
-	if log.ScreenTime < 0 || log.ScreenTime > 24 {
-		return fmt.Errorf("screen_time must be between 0 and 24")
+
+	logID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apperror.Write(w, r, apperror.Validation("id", "invalid log id"))
+		return
 	}
-	if log.ExerciseDuration < 0 || log.ExerciseDuration > 24 {
-		return fmt.Errorf("exercise_duration must be between 0 and 24")
+
+	userID, ok := authorizeUser(w, r, "")
+	if !ok {
+		return
 	}
-	if log.WaterIntake < 0 {
-		return fmt.Errorf("water_intake must be positive")
+
+	routineLog, err := h.routineService.GetRoutineLog(r.Context(), userID, logID)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
 	}
-	if log.StressLevel < 1 || log.StressLevel > 10 {
-		return fmt.Errorf("stress_level must be between 1 and 10")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(routineLog)
+}
+
+// UpdateRoutineLog handles PUT and PATCH /logs/{id} requests
+// PUT replaces the whole log, PATCH only overwrites the fields present in the body
+func (h *LogHandler) UpdateRoutineLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
 	}
-	if len(log.MealTimes) == 0 {
-		return fmt.Errorf("meal_times cannot be empty")
+
+	logID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apperror.Write(w, r, apperror.Validation("id", "invalid log id"))
+		return
+	}
+
+	userID, ok := authorizeUser(w, r, "")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxLogBodyBytes))
+	if err != nil {
+		apperror.Write(w, r, bodyError(err, apperror.Validation("", "invalid request body")))
+		return
+	}
+
+	var response *services.CreateRoutineLogResponse
+	if r.Method == http.MethodPatch {
+		// The service overlays the submitted fields onto the stored log and validates the result
+		response, err = h.routineService.PatchRoutineLog(r.Context(), userID, logID, body)
+	} else {
+		var routineLog database.RoutineLog
+		if err := json.Unmarshal(body, &routineLog); err != nil {
+			apperror.Write(w, r, apperror.ErrInvalidJSON)
+			return
+		}
+
+		// A log cannot be moved to another user
+		if routineLog.UserID != "" && routineLog.UserID != userID {
+			apperror.Write(w, r, services.ErrForbidden)
+			return
+		}
+		routineLog.ID = logID
+		routineLog.UserID = userID
+
+		if err := validation.RoutineLog(routineLog, time.Now()); err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		response, err = h.routineService.UpdateRoutineLog(r.Context(), userID, routineLog)
+	}
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteRoutineLog handles DELETE /logs/{id} requests
+func (h *LogHandler) DeleteRoutineLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
+	}
+
+	logID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apperror.Write(w, r, apperror.Validation("id", "invalid log id"))
+		return
 	}
-	if log.WakeUpTime == "" {
-		return fmt.Errorf("wake_up_time is required")
+
+	userID, ok := authorizeUser(w, r, "")
+	if !ok {
+		return
 	}
-	if log.BedTime == "" {
-		return fmt.Errorf("bed_time is required")
+
+	if err := h.routineService.DeleteRoutineLog(r.Context(), userID, logID); err != nil {
+		apperror.Write(w, r, err)
+		return
 	}
 
-	return nil
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bodyError returns the error to report for a request body that could not be read:
+// ErrBodyTooLarge past maxLogBodyBytes and fallback otherwise
+func bodyError(err error, fallback error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apperror.ErrBodyTooLarge
+	}
+	return fallback
 }
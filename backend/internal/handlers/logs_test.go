@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/gorilla/mux"
+
+	"lifepattern-api/internal/apperror"
 	"lifepattern-api/internal/database"
 	"lifepattern-api/internal/services"
 )
@@ -17,6 +22,10 @@ type MockRoutineService struct {
 	shouldFail bool
 	response   *services.CreateRoutineLogResponse
 	logs       []database.RoutineLog
+	updated    *database.RoutineLog
+	saved      *database.RoutineLog
+	savedMode  services.ConflictMode
+	query      services.LogQuery
 }
 
 func NewMockRoutineService(shouldFail bool) *MockRoutineService {
@@ -24,6 +33,7 @@ func NewMockRoutineService(shouldFail bool) *MockRoutineService {
 		shouldFail: shouldFail,
 		response: &services.CreateRoutineLogResponse{
 			LogID:   1,
+			Created: true,
 			Message: "Routine log saved and analyzed",
 			HasAI:   true,
 			AIResult: &services.AIResult{
@@ -36,32 +46,119 @@ func NewMockRoutineService(shouldFail bool) *MockRoutineService {
 	}
 }
 
-func (m *MockRoutineService) CreateRoutineLog(routineLog database.RoutineLog) (*services.CreateRoutineLogResponse, error) {
+func (m *MockRoutineService) CreateRoutineLog(ctx context.Context, routineLog database.RoutineLog, mode services.ConflictMode) (*services.CreateRoutineLogResponse, error) {
 	if m.shouldFail {
 		return nil, errors.New("service error")
 	}
+	if _, err := m.GetRoutineLogByDate(ctx, routineLog.UserID, routineLog.LogDate); err == nil && mode == services.ConflictReject {
+		return nil, services.ErrRoutineLogExists
+	}
+	m.saved = &routineLog
+	m.savedMode = mode
+	return m.response, nil
+}
+
+// MergeRoutineLog overlays patch onto the log served by GetRoutineLogByDate
+func (m *MockRoutineService) MergeRoutineLog(ctx context.Context, userID string, logDate string, patch []byte) (*services.CreateRoutineLogResponse, error) {
+	routineLog := database.RoutineLog{UserID: userID, LogDate: logDate}
+	if existing, err := m.GetRoutineLogByDate(ctx, userID, logDate); err == nil {
+		routineLog = *existing
+	}
+	if err := json.Unmarshal(patch, &routineLog); err != nil {
+		return nil, apperror.ErrInvalidJSON
+	}
+	m.saved = &routineLog
+	m.savedMode = services.ConflictMerge
 	return m.response, nil
 }
 
-func (m *MockRoutineService) GetInsight(logID int) (*database.InsightResponse, error) {
+// GetRoutineLogByDate serves log 1, which user_1 logged on 2024-01-15
+func (m *MockRoutineService) GetRoutineLogByDate(ctx context.Context, userID string, logDate string) (*database.RoutineLog, error) {
+	if logDate != "2024-01-15" {
+		return nil, services.ErrRoutineLogNotFound
+	}
+	return m.GetRoutineLog(ctx, userID, 1)
+}
+
+func (m *MockRoutineService) GetInsight(ctx context.Context, userID string, logID int) (*database.InsightResponse, error) {
 	if m.shouldFail {
 		return nil, errors.New("service error")
 	}
 	return &database.InsightResponse{}, nil
 }
 
-func (m *MockRoutineService) GetUserRoutineLogs(userID int, limit int) ([]database.RoutineLog, error) {
+// GetRoutineLog serves a single log 1 owned by user_1
+func (m *MockRoutineService) GetRoutineLog(ctx context.Context, userID string, logID int) (*database.RoutineLog, error) {
+	if m.shouldFail {
+		return nil, errors.New("service error")
+	}
+	if logID != 1 {
+		return nil, services.ErrRoutineLogNotFound
+	}
+	if userID != "user_1" {
+		return nil, services.ErrRoutineLogNotFound
+	}
+	return &database.RoutineLog{
+		ID:               1,
+		UserID:           "user_1",
+		SleepHours:       8.0,
+		MealTimes:        []string{"07:30", "12:00", "18:30"},
+		ScreenTime:       4.5,
+		ExerciseDuration: 1.0,
+		WakeUpTime:       "07:00",
+		BedTime:          "23:00",
+		WaterIntake:      2.5,
+		StressLevel:      4,
+		LogDate:          "2024-01-15",
+	}, nil
+}
+
+func (m *MockRoutineService) UpdateRoutineLog(ctx context.Context, userID string, routineLog database.RoutineLog) (*services.CreateRoutineLogResponse, error) {
+	if _, err := m.GetRoutineLog(ctx, userID, routineLog.ID); err != nil {
+		return nil, err
+	}
+	m.updated = &routineLog
+	return m.response, nil
+}
+
+// PatchRoutineLog overlays patch onto the log served by GetRoutineLog
+func (m *MockRoutineService) PatchRoutineLog(ctx context.Context, userID string, logID int, patch []byte) (*services.CreateRoutineLogResponse, error) {
+	routineLog, err := m.GetRoutineLog(ctx, userID, logID)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, routineLog); err != nil {
+		return nil, apperror.ErrInvalidJSON
+	}
+	if routineLog.UserID != userID {
+		return nil, services.ErrForbidden
+	}
+	m.updated = routineLog
+	return m.response, nil
+}
+
+func (m *MockRoutineService) DeleteRoutineLog(ctx context.Context, userID string, logID int) error {
+	_, err := m.GetRoutineLog(ctx, userID, logID)
+	return err
+}
+
+func (m *MockRoutineService) GetUserRoutineLogs(ctx context.Context, query services.LogQuery) (*services.RoutineLogPage, error) {
 	if m.shouldFail {
 		return nil, errors.New("service error")
 	}
-	return m.logs, nil
+	m.query = query
+	return &services.RoutineLogPage{Logs: m.logs}, nil
 }
 
-func (m *MockRoutineService) GetUserInsights(userID int, limit int) ([]database.InsightResponse, error) {
+func (m *MockRoutineService) GetUserInsights(ctx context.Context, query services.LogQuery) (*services.InsightPage, error) {
 	if m.shouldFail {
 		return nil, errors.New("service error")
 	}
-	return []database.InsightResponse{}, nil
+	return &services.InsightPage{Insights: []database.InsightResponse{}}, nil
+}
+
+func (m *MockRoutineService) GetTrends(ctx context.Context, query services.TrendQuery) (*services.Trends, error) {
+	return nil, errors.New("not implemented")
 }
 
 func TestNewLogHandler(t *testing.T) {
@@ -83,7 +180,7 @@ func TestCreateRoutineLog(t *testing.T) {
 
 	// Valid request
 	requestBody := database.RoutineLog{
-		UserID:           1,
+		UserID:           "user_1",
 		SleepHours:       8.0,
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -98,6 +195,7 @@ func TestCreateRoutineLog(t *testing.T) {
 	jsonBody, _ := json.Marshal(requestBody)
 	req := httptest.NewRequest("POST", "/log", bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
+	req = withUser(req, "user_1")
 	w := httptest.NewRecorder()
 
 	handler.CreateRoutineLog(w, req)
@@ -155,7 +253,7 @@ func TestCreateRoutineLogValidationError(t *testing.T) {
 
 	// Invalid request - negative sleep hours
 	requestBody := database.RoutineLog{
-		UserID:           1,
+		UserID:           "user_1",
 		SleepHours:       -1.0, // Invalid
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -170,6 +268,7 @@ func TestCreateRoutineLogValidationError(t *testing.T) {
 	jsonBody, _ := json.Marshal(requestBody)
 	req := httptest.NewRequest("POST", "/log", bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
+	req = withUser(req, "user_1")
 	w := httptest.NewRecorder()
 
 	handler.CreateRoutineLog(w, req)
@@ -178,9 +277,9 @@ func TestCreateRoutineLogValidationError(t *testing.T) {
 		t.Fatalf("Expected status 400, got %d", w.Code)
 	}
 
-	body := w.Body.String()
-	if body == "" {
-		t.Fatal("Expected error message in response body")
+	response := decodeError(t, w)
+	if response.Code != apperror.CodeValidation || response.Field != "sleep_hours" {
+		t.Fatalf("Expected a validation error on sleep_hours, got %+v", response)
 	}
 }
 
@@ -189,7 +288,7 @@ func TestCreateRoutineLogServiceError(t *testing.T) {
 	handler := NewLogHandler(mockService)
 
 	requestBody := database.RoutineLog{
-		UserID:           1,
+		UserID:           "user_1",
 		SleepHours:       8.0,
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -204,6 +303,7 @@ func TestCreateRoutineLogServiceError(t *testing.T) {
 	jsonBody, _ := json.Marshal(requestBody)
 	req := httptest.NewRequest("POST", "/log", bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
+	req = withUser(req, "user_1")
 	w := httptest.NewRecorder()
 
 	handler.CreateRoutineLog(w, req)
@@ -217,7 +317,8 @@ func TestGetUserRoutineLogs(t *testing.T) {
 	mockService := NewMockRoutineService(false)
 	handler := NewLogHandler(mockService)
 
-	req := httptest.NewRequest("GET", "/logs?user_id=1&limit=10", nil)
+	req := httptest.NewRequest("GET", "/logs?user_id=user_1&limit=10", nil)
+	req = withUser(req, "user_1")
 	w := httptest.NewRecorder()
 
 	handler.GetUserRoutineLogs(w, req)
@@ -231,8 +332,8 @@ func TestGetUserRoutineLogs(t *testing.T) {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	if response["user_id"] != float64(1) {
-		t.Fatalf("Expected user ID 1, got %v", response["user_id"])
+	if response["user_id"] != "user_1" {
+		t.Fatalf("Expected user ID user_1, got %v", response["user_id"])
 	}
 }
 
@@ -250,7 +351,7 @@ func TestGetUserRoutineLogsInvalidMethod(t *testing.T) {
 	}
 }
 
-func TestGetUserRoutineLogsMissingUserID(t *testing.T) {
+func TestGetUserRoutineLogsUnauthenticated(t *testing.T) {
 	mockService := NewMockRoutineService(false)
 	handler := NewLogHandler(mockService)
 
@@ -259,22 +360,52 @@ func TestGetUserRoutineLogsMissingUserID(t *testing.T) {
 
 	handler.GetUserRoutineLogs(w, req)
 
-	if w.Code != http.StatusBadRequest {
-		t.Fatalf("Expected status 400, got %d", w.Code)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d", w.Code)
 	}
 }
 
-func TestGetUserRoutineLogsInvalidUserID(t *testing.T) {
+func TestGetUserRoutineLogsOtherUser(t *testing.T) {
 	mockService := NewMockRoutineService(false)
 	handler := NewLogHandler(mockService)
 
-	req := httptest.NewRequest("GET", "/logs?user_id=invalid", nil)
+	req := httptest.NewRequest("GET", "/logs?user_id=user_2", nil)
+	req = withUser(req, "user_1")
 	w := httptest.NewRecorder()
 
 	handler.GetUserRoutineLogs(w, req)
 
-	if w.Code != http.StatusBadRequest {
-		t.Fatalf("Expected status 400, got %d", w.Code)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestCreateRoutineLogForOtherUser(t *testing.T) {
+	mockService := NewMockRoutineService(false)
+	handler := NewLogHandler(mockService)
+
+	requestBody := database.RoutineLog{
+		UserID:           "user_2",
+		SleepHours:       8.0,
+		MealTimes:        []string{"07:30", "12:00", "18:30"},
+		ScreenTime:       4.5,
+		ExerciseDuration: 1.0,
+		WakeUpTime:       "07:00",
+		BedTime:          "23:00",
+		WaterIntake:      2.5,
+		StressLevel:      4,
+		LogDate:          "2024-01-15",
+	}
+
+	jsonBody, _ := json.Marshal(requestBody)
+	req := httptest.NewRequest("POST", "/log", bytes.NewBuffer(jsonBody))
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.CreateRoutineLog(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d", w.Code)
 	}
 }
 
@@ -282,7 +413,8 @@ func TestGetUserRoutineLogsInvalidLimit(t *testing.T) {
 	mockService := NewMockRoutineService(false)
 	handler := NewLogHandler(mockService)
 
-	req := httptest.NewRequest("GET", "/logs?user_id=1&limit=invalid", nil)
+	req := httptest.NewRequest("GET", "/logs?user_id=user_1&limit=invalid", nil)
+	req = withUser(req, "user_1")
 	w := httptest.NewRecorder()
 
 	handler.GetUserRoutineLogs(w, req)
@@ -296,7 +428,8 @@ func TestGetUserRoutineLogsServiceError(t *testing.T) {
 	mockService := NewMockRoutineService(true) // Service will fail
 	handler := NewLogHandler(mockService)
 
-	req := httptest.NewRequest("GET", "/logs?user_id=1", nil)
+	req := httptest.NewRequest("GET", "/logs?user_id=user_1", nil)
+	req = withUser(req, "user_1")
 	w := httptest.NewRecorder()
 
 	handler.GetUserRoutineLogs(w, req)
@@ -306,64 +439,337 @@ func TestGetUserRoutineLogsServiceError(t *testing.T) {
 	}
 }
 
-func TestValidateRoutineLog(t *testing.T) {
-	// Test valid log
-	validLog := database.RoutineLog{
-		UserID:           1,
-		SleepHours:       8.0,
-		MealTimes:        []string{"07:30", "12:00", "18:30"},
-		ScreenTime:       4.5,
-		ExerciseDuration: 1.0,
-		WakeUpTime:       "07:00",
-		BedTime:          "23:00",
-		WaterIntake:      2.5,
-		StressLevel:      4,
-		LogDate:          "2024-01-15",
+func TestCreateRoutineLogReportsAllFieldErrors(t *testing.T) {
+	handler := NewLogHandler(NewMockRoutineService(false))
+
+	body := `{"sleep_hours": 8, "meal_times": ["07:30", "noon"], "screen_time": 4.5, "exercise_duration": 1,
+		"wake_up_time": "7am", "bed_time": "23:00", "water_intake": 2.5, "stress_level": 0, "log_date": "2024-01-15"}`
+	req := withUser(httptest.NewRequest("POST", "/log", bytes.NewBufferString(body)), "user_1")
+	w := httptest.NewRecorder()
+
+	handler.CreateRoutineLog(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
 	}
 
-	if err := validateRoutineLog(validLog); err != nil {
-		t.Fatalf("Expected no validation error, got %v", err)
+	response := decodeError(t, w)
+	var fields []string
+	for _, fieldErr := range response.Errors {
+		fields = append(fields, fieldErr.Field)
 	}
+	if strings.Join(fields, ",") != "stress_level,meal_times[1],wake_up_time" {
+		t.Fatalf("Expected errors on stress_level, meal_times[1] and wake_up_time, got %v", fields)
+	}
+}
+
+func TestGetRoutineLog(t *testing.T) {
+	handler := NewLogHandler(NewMockRoutineService(false))
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/logs/1", nil), map[string]string{"id": "1"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.GetRoutineLog(w, req)
 
-	// Test invalid sleep hours
-	invalidLog := validLog
-	invalidLog.SleepHours = -1.0
-	if err := validateRoutineLog(invalidLog); err == nil {
-		t.Fatal("Expected validation error for negative sleep hours")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
 	}
 
-	// Test invalid screen time
-	invalidLog = validLog
-	invalidLog.ScreenTime = 25.0
-	if err := validateRoutineLog(invalidLog); err == nil {
-		t.Fatal("Expected validation error for excessive screen time")
+	var routineLog database.RoutineLog
+	if err := json.Unmarshal(w.Body.Bytes(), &routineLog); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	// Test invalid stress level
-	invalidLog = validLog
-	invalidLog.StressLevel = 11
-	if err := validateRoutineLog(invalidLog); err == nil {
-		t.Fatal("Expected validation error for invalid stress level")
+	if routineLog.ID != 1 {
+		t.Fatalf("Expected log ID 1, got %d", routineLog.ID)
 	}
+}
 
-	// Test empty meal times
-	invalidLog = validLog
-	invalidLog.MealTimes = []string{}
-	if err := validateRoutineLog(invalidLog); err == nil {
-		t.Fatal("Expected validation error for empty meal times")
+func TestGetRoutineLogNotFound(t *testing.T) {
+	handler := NewLogHandler(NewMockRoutineService(false))
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/logs/2", nil), map[string]string{"id": "2"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.GetRoutineLog(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestGetRoutineLogOtherUser(t *testing.T) {
+	handler := NewLogHandler(NewMockRoutineService(false))
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/logs/1", nil), map[string]string{"id": "1"})
+	req = withUser(req, "user_2")
+	w := httptest.NewRecorder()
+
+	handler.GetRoutineLog(w, req)
+
+	// Another user's log is indistinguishable from a missing one
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
 	}
+}
+
+func TestPatchRoutineLog(t *testing.T) {
+	mockService := NewMockRoutineService(false)
+	handler := NewLogHandler(mockService)
+
+	body := `{"stress_level": 9}`
+	req := mux.SetURLVars(httptest.NewRequest("PATCH", "/logs/1", bytes.NewBufferString(body)), map[string]string{"id": "1"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.UpdateRoutineLog(w, req)
 
-	// Test missing wake up time
-	invalidLog = validLog
-	invalidLog.WakeUpTime = ""
-	if err := validateRoutineLog(invalidLog); err == nil {
-		t.Fatal("Expected validation error for missing wake up time")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	if mockService.updated == nil {
+		t.Fatal("Expected routine log to be updated")
+	}
+
+	if mockService.updated.StressLevel != 9 {
+		t.Fatalf("Expected stress level 9, got %d", mockService.updated.StressLevel)
+	}
+
+	if mockService.updated.SleepHours != 8.0 {
+		t.Fatalf("Expected untouched sleep hours 8.0, got %f", mockService.updated.SleepHours)
+	}
+}
+
+func TestPutRoutineLogValidationError(t *testing.T) {
+	mockService := NewMockRoutineService(false)
+	handler := NewLogHandler(mockService)
+
+	// PUT replaces the whole log, so omitted required fields are rejected
+	body := `{"stress_level": 9}`
+	req := mux.SetURLVars(httptest.NewRequest("PUT", "/logs/1", bytes.NewBufferString(body)), map[string]string{"id": "1"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.UpdateRoutineLog(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+
+	if mockService.updated != nil {
+		t.Fatal("Expected routine log not to be updated")
+	}
+}
+
+func TestUpdateRoutineLogMoveToOtherUser(t *testing.T) {
+	handler := NewLogHandler(NewMockRoutineService(false))
+
+	body := `{"user_id": "user_2"}`
+	req := mux.SetURLVars(httptest.NewRequest("PATCH", "/logs/1", bytes.NewBufferString(body)), map[string]string{"id": "1"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.UpdateRoutineLog(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestDeleteRoutineLog(t *testing.T) {
+	handler := NewLogHandler(NewMockRoutineService(false))
+
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/logs/1", nil), map[string]string{"id": "1"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.DeleteRoutineLog(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+}
+
+func TestDeleteRoutineLogNotFound(t *testing.T) {
+	handler := NewLogHandler(NewMockRoutineService(false))
+
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/logs/2", nil), map[string]string{"id": "2"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.DeleteRoutineLog(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestCreateRoutineLogConflictModes(t *testing.T) {
+	body := `{"sleep_hours": 8, "meal_times": ["08:00"], "wake_up_time": "07:00", "bed_time": "23:00", "stress_level": 5, "log_date": "2024-01-15"}`
+
+	// Replace is the default
+	mockService := NewMockRoutineService(false)
+	req := withUser(httptest.NewRequest("POST", "/log", bytes.NewBufferString(body)), "user_1")
+	w := httptest.NewRecorder()
+	NewLogHandler(mockService).CreateRoutineLog(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", w.Code)
+	}
+	if mockService.savedMode != services.ConflictReplace {
+		t.Fatalf("Expected conflict mode replace, got %s", mockService.savedMode)
+	}
+
+	// Reject refuses to overwrite the logged day
+	req = withUser(httptest.NewRequest("POST", "/log?on_conflict=reject", bytes.NewBufferString(body)), "user_1")
+	w = httptest.NewRecorder()
+	NewLogHandler(NewMockRoutineService(false)).CreateRoutineLog(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d", w.Code)
+	}
+	if response := decodeError(t, w); response.Code != apperror.CodeConflict {
+		t.Fatalf("Expected code conflict, got %s", response.Code)
+	}
+
+	// Unknown modes are rejected
+	req = withUser(httptest.NewRequest("POST", "/log?on_conflict=append", bytes.NewBufferString(body)), "user_1")
+	w = httptest.NewRecorder()
+	NewLogHandler(NewMockRoutineService(false)).CreateRoutineLog(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestCreateRoutineLogReplaced(t *testing.T) {
+	mockService := NewMockRoutineService(false)
+	mockService.response.Created = false
+	handler := NewLogHandler(mockService)
+
+	body := `{"sleep_hours": 8, "meal_times": ["08:00"], "wake_up_time": "07:00", "bed_time": "23:00", "stress_level": 5, "log_date": "2024-01-15"}`
+	req := withUser(httptest.NewRequest("POST", "/log", bytes.NewBufferString(body)), "user_1")
+	w := httptest.NewRecorder()
+
+	handler.CreateRoutineLog(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response services.CreateRoutineLogResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.Created {
+		t.Fatal("Expected created to be false for a replaced day")
+	}
+}
+
+func TestCreateRoutineLogMerge(t *testing.T) {
+	mockService := NewMockRoutineService(false)
+	handler := NewLogHandler(mockService)
+
+	// Only the stress level is submitted; everything else comes from the stored day
+	body := `{"stress_level": 9, "log_date": "2024-01-15"}`
+	req := withUser(httptest.NewRequest("POST", "/log?on_conflict=merge", bytes.NewBufferString(body)), "user_1")
+	w := httptest.NewRecorder()
+
+	handler.CreateRoutineLog(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", w.Code)
+	}
+
+	if mockService.saved.StressLevel != 9 {
+		t.Fatalf("Expected stress level 9, got %d", mockService.saved.StressLevel)
+	}
+
+	if mockService.saved.SleepHours != 8.0 {
+		t.Fatalf("Expected stored sleep hours 8.0, got %f", mockService.saved.SleepHours)
+	}
+
+	if mockService.savedMode != services.ConflictMerge {
+		t.Fatalf("Expected conflict mode merge, got %s", mockService.savedMode)
+	}
+}
+
+func TestCreateRoutineLogMergeInvalidDate(t *testing.T) {
+	mockService := NewMockRoutineService(false)
+	handler := NewLogHandler(mockService)
+
+	body := `{"stress_level": 9, "log_date": "15/01/2024"}`
+	req := withUser(httptest.NewRequest("POST", "/log?on_conflict=merge", bytes.NewBufferString(body)), "user_1")
+	w := httptest.NewRecorder()
+
+	handler.CreateRoutineLog(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+
+	if mockService.saved != nil {
+		t.Fatal("Expected nothing to be merged")
+	}
+}
+
+func TestCreateRoutineLogBodyTooLarge(t *testing.T) {
+	mockService := NewMockRoutineService(false)
+	handler := NewLogHandler(mockService)
+
+	body := `{"user_id": "` + strings.Repeat("x", maxLogBodyBytes) + `"}`
+	req := withUser(httptest.NewRequest("POST", "/log", bytes.NewBufferString(body)), "user_1")
+	w := httptest.NewRecorder()
+
+	handler.CreateRoutineLog(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status 413, got %d", w.Code)
+	}
+}
+
+func TestGetUserRoutineLogsInvalidQuery(t *testing.T) {
+	queries := []string{
+		"limit=0",
+		"limit=-5",
+		"limit=1000",
+		"from=2024-13-01",
+		"to=yesterday",
+		"from=2024-02-01&to=2024-01-01",
+	}
+
+	for _, query := range queries {
+		handler := NewLogHandler(NewMockRoutineService(false))
+		req := withUser(httptest.NewRequest("GET", "/logs?"+query, nil), "user_1")
+		w := httptest.NewRecorder()
+
+		handler.GetUserRoutineLogs(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status 400 for %q, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestGetUserRoutineLogsDateRange(t *testing.T) {
+	mockService := NewMockRoutineService(false)
+	handler := NewLogHandler(mockService)
+
+	req := withUser(httptest.NewRequest("GET", "/logs?from=2024-01-01&to=2024-01-31&limit=5&cursor=abc", nil), "user_1")
+	w := httptest.NewRecorder()
+
+	handler.GetUserRoutineLogs(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
 	}
 
-	// Test missing bed time
-	invalidLog = validLog
-	invalidLog.BedTime = ""
-	if err := validateRoutineLog(invalidLog); err == nil {
-		t.Fatal("Expected validation error for missing bed time")
+	expected := services.LogQuery{UserID: "user_1", From: "2024-01-01", To: "2024-01-31", Cursor: "abc", Limit: 5}
+	if mockService.query != expected {
+		t.Fatalf("Expected query %+v, got %+v", expected, mockService.query)
 	}
 }
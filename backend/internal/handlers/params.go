@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"lifepattern-api/internal/apperror"
+	"lifepattern-api/internal/services"
+)
+
+// userIDPattern matches the opaque user IDs generated by the mobile app,
+// e.g. "user_<timestamp>_<random>"
+var userIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,128}$`)
+
+// isValidUserID reports whether id is a well-formed user ID
+func isValidUserID(id string) bool {
+	return userIDPattern.MatchString(id)
+}
+
+// parseLogQuery reads the limit, from, to and cursor query parameters of a list endpoint
+func parseLogQuery(r *http.Request, userID string) (services.LogQuery, error) {
+	params := r.URL.Query()
+	query := services.LogQuery{
+		UserID: userID,
+		From:   params.Get("from"),
+		To:     params.Get("to"),
+		Cursor: params.Get("cursor"),
+		Limit:  services.DefaultPageSize,
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > services.MaxPageSize {
+			return query, apperror.Validation("limit", fmt.Sprintf("limit must be between 1 and %d", services.MaxPageSize))
+		}
+		query.Limit = limit
+	}
+
+	return query, validateDateRange(query.From, query.To)
+}
+
+// maxModelVersionLength is the size of the ai_reports.model_version column
+const maxModelVersionLength = 50
+
+// parseModelVersion reads the optional model_version query parameter
+func parseModelVersion(r *http.Request) (string, error) {
+	modelVersion := r.URL.Query().Get("model_version")
+	if len(modelVersion) > maxModelVersionLength {
+		return "", apperror.Validation("model_version",
+			fmt.Sprintf("model_version must be at most %d characters", maxModelVersionLength))
+	}
+	return modelVersion, nil
+}
+
+// parseTrendQuery reads the period, from and to query parameters of the trends endpoint
+func parseTrendQuery(r *http.Request, userID string) (services.TrendQuery, error) {
+	params := r.URL.Query()
+	query := services.TrendQuery{
+		UserID: userID,
+		From:   params.Get("from"),
+		To:     params.Get("to"),
+	}
+
+	period, err := services.ParseTrendPeriod(params.Get("period"))
+	if err != nil {
+		return query, apperror.Validation("period", "period must be week or month")
+	}
+	query.Period = period
+
+	return query, validateDateRange(query.From, query.To)
+}
+
+// validateDateRange checks the optional from/to log_date bounds of a query
+func validateDateRange(fromStr, toStr string) error {
+	var from, to time.Time
+	var err error
+	if fromStr != "" {
+		if from, err = time.Parse("2006-01-02", fromStr); err != nil {
+			return apperror.Validation("from", "from must be a YYYY-MM-DD date")
+		}
+	}
+	if toStr != "" {
+		if to, err = time.Parse("2006-01-02", toStr); err != nil {
+			return apperror.Validation("to", "to must be a YYYY-MM-DD date")
+		}
+	}
+	if fromStr != "" && toStr != "" && from.After(to) {
+		return apperror.Validation("from", "from must not be after to")
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"lifepattern-api/internal/apperror"
+	"lifepattern-api/internal/services"
+)
+
+type QueueHandler struct {
+	queue services.AnalysisQueueInterface
+}
+
+func NewQueueHandler(queue services.AnalysisQueueInterface) *QueueHandler {
+	return &QueueHandler{
+		queue: queue,
+	}
+}
+
+// GetQueueStats handles GET /analysis-queue requests
+// Only the analyses of the caller's own logs are counted
+func (h *QueueHandler) GetQueueStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
+	}
+
+	userID, ok := authorizeUser(w, r, "")
+	if !ok {
+		return
+	}
+
+	stats, err := h.queue.UserQueueStats(r.Context(), userID)
+	if err != nil {
+		apperror.Write(w, r, fmt.Errorf("failed to get queue stats: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
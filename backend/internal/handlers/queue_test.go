@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lifepattern-api/internal/database"
+)
+
+// Mock analysis queue for testing
+type MockAnalysisQueue struct {
+	shouldFail bool
+	// userID records the user whose stats were requested
+	userID string
+}
+
+func (m *MockAnalysisQueue) QueueStats(ctx context.Context) (*database.AnalysisQueueStats, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockAnalysisQueue) UserQueueStats(ctx context.Context, userID string) (*database.AnalysisQueueStats, error) {
+	m.userID = userID
+	if m.shouldFail {
+		return nil, errors.New("database unavailable")
+	}
+	return &database.AnalysisQueueStats{Depth: 3, Ready: 2, InProgress: 1, Failed: 1, MaxAttempts: 4, AttemptLimit: 20}, nil
+}
+
+func TestGetQueueStats(t *testing.T) {
+	queue := &MockAnalysisQueue{}
+	handler := NewQueueHandler(queue)
+
+	req := withUser(httptest.NewRequest("GET", "/analysis-queue", nil), "user_1")
+	w := httptest.NewRecorder()
+
+	handler.GetQueueStats(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var stats database.AnalysisQueueStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if stats.Depth != 3 || stats.Ready != 2 || stats.InProgress != 1 {
+		t.Fatalf("Expected depth 3, ready 2, in progress 1, got %+v", stats)
+	}
+
+	if stats.Failed != 1 || stats.AttemptLimit != 20 {
+		t.Fatalf("Expected 1 failed job with an attempt limit of 20, got %+v", stats)
+	}
+
+	if queue.userID != "user_1" {
+		t.Fatalf("Expected stats for the caller, got user %q", queue.userID)
+	}
+}
+
+func TestGetQueueStatsRequiresAuthentication(t *testing.T) {
+	handler := NewQueueHandler(&MockAnalysisQueue{})
+
+	req := httptest.NewRequest("GET", "/analysis-queue", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetQueueStats(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestGetQueueStatsError(t *testing.T) {
+	handler := NewQueueHandler(&MockAnalysisQueue{shouldFail: true})
+
+	req := withUser(httptest.NewRequest("GET", "/analysis-queue", nil), "user_1")
+	w := httptest.NewRecorder()
+
+	handler.GetQueueStats(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", w.Code)
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+
+	"lifepattern-api/internal/apperror"
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/services"
+	"lifepattern-api/internal/validation"
+)
+
+// usernamePattern matches the human-friendly names generated by the mobile app, e.g. "Swift-Runner-42"
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,50}$`)
+
+// deviceIDPattern matches device hashes and fallback device IDs
+var deviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// errInvalidUserID is returned for user IDs in the path that are not well-formed
+var errInvalidUserID = apperror.Validation("id", "invalid user id")
+
+// errInvalidUsername is returned for usernames that do not match usernamePattern
+var errInvalidUsername = apperror.Validation("username", "username must be 3-50 letters, digits, '-' or '_'")
+
+type UserHandler struct {
+	userService services.UserServiceInterface
+}
+
+func NewUserHandler(userService services.UserServiceInterface) *UserHandler {
+	return &UserHandler{
+		userService: userService,
+	}
+}
+
+// RegisterUser handles POST /users requests
+// The response carries the user's secret, which is not stored and cannot be retrieved again
+func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		DeviceID string `json:"device_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		apperror.Write(w, r, apperror.ErrInvalidJSON)
+		return
+	}
+
+	user := database.User{ID: body.ID, Username: body.Username, DeviceID: body.DeviceID}
+
+	if err := validateUser(user); err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	created, err := h.userService.RegisterUser(r.Context(), user)
+	if err != nil {
+		if errors.Is(err, services.ErrUserExists) {
+			err = apperror.Conflict("user, username or device already registered")
+		}
+		apperror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetUser handles GET /users/{id} requests
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
+	}
+
+	userID := mux.Vars(r)["id"]
+	if !isValidUserID(userID) {
+		apperror.Write(w, r, errInvalidUserID)
+		return
+	}
+
+	if _, ok := authorizeUser(w, r, userID); !ok {
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), userID)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// RenameUser handles PATCH /users/{id} requests
+func (h *UserHandler) RenameUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
+	}
+
+	userID := mux.Vars(r)["id"]
+	if !isValidUserID(userID) {
+		apperror.Write(w, r, errInvalidUserID)
+		return
+	}
+
+	if _, ok := authorizeUser(w, r, userID); !ok {
+		return
+	}
+
+	var body struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		apperror.Write(w, r, apperror.ErrInvalidJSON)
+		return
+	}
+
+	if !usernamePattern.MatchString(body.Username) {
+		apperror.Write(w, r, errInvalidUsername)
+		return
+	}
+
+	user, err := h.userService.RenameUser(r.Context(), userID, body.Username)
+	if err != nil {
+		if errors.Is(err, services.ErrUserExists) {
+			err = apperror.Conflict("username already taken")
+		}
+		apperror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// validateUser validates user registration data
+func validateUser(user database.User) error {
+	var errs validation.Errors
+	if user.ID != "" && !isValidUserID(user.ID) {
+		errs.Add("id", "id is invalid")
+	}
+	if !usernamePattern.MatchString(user.Username) {
+		errs.Add("username", errInvalidUsername.Message)
+	}
+	if !deviceIDPattern.MatchString(user.DeviceID) {
+		errs.Add("device_id", "device_id must be 1-64 letters, digits, '-' or '_'")
+	}
+
+	return errs.Err()
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/services"
+)
+
+// Mock user service for testing
+type MockUserService struct {
+	users   map[string]database.User
+	secrets map[string]string
+}
+
+func NewMockUserService() *MockUserService {
+	return &MockUserService{
+		users: map[string]database.User{
+			"user_1": {ID: "user_1", Username: "Swift-Runner-1", DeviceID: "device_1"},
+		},
+		secrets: map[string]string{
+			"user_1": "secret_1",
+		},
+	}
+}
+
+func (m *MockUserService) RegisterUser(ctx context.Context, user database.User) (*services.RegisteredUser, error) {
+	if user.ID == "" {
+		user.ID = "user_1705312200000_abc"
+	}
+	if _, exists := m.users[user.ID]; exists {
+		return nil, services.ErrUserExists
+	}
+	m.users[user.ID] = user
+	m.secrets[user.ID] = "secret_" + user.ID
+	return &services.RegisteredUser{User: user, Secret: m.secrets[user.ID]}, nil
+}
+
+func (m *MockUserService) GetUser(ctx context.Context, userID string) (*database.User, error) {
+	user, exists := m.users[userID]
+	if !exists {
+		return nil, services.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (m *MockUserService) Authenticate(ctx context.Context, userID string, secret string) (*database.User, error) {
+	user, exists := m.users[userID]
+	if !exists || m.secrets[userID] != secret {
+		return nil, services.ErrInvalidCredentials
+	}
+	return &user, nil
+}
+
+func (m *MockUserService) RenameUser(ctx context.Context, userID string, username string) (*database.User, error) {
+	user, exists := m.users[userID]
+	if !exists {
+		return nil, services.ErrUserNotFound
+	}
+	user.Username = username
+	m.users[userID] = user
+	return &user, nil
+}
+
+func TestRegisterUser(t *testing.T) {
+	handler := NewUserHandler(NewMockUserService())
+
+	body := `{"username": "Calm-Walker-7", "device_id": "a1b2c3d4e5f6a7b8"}`
+	req := httptest.NewRequest("POST", "/users", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.RegisterUser(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", w.Code)
+	}
+
+	var user services.RegisteredUser
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if user.ID == "" {
+		t.Fatal("Expected user ID to be assigned")
+	}
+
+	// The device ID is stored but never returned
+	if saved := handler.userService.(*MockUserService).users[user.ID]; saved.DeviceID != "a1b2c3d4e5f6a7b8" {
+		t.Fatalf("Expected device ID a1b2c3d4e5f6a7b8, got %s", saved.DeviceID)
+	}
+	if strings.Contains(w.Body.String(), "device_id") {
+		t.Fatalf("Expected no device_id in the response, got %s", w.Body.String())
+	}
+
+	if user.Secret == "" {
+		t.Fatal("Expected the user secret to be returned")
+	}
+
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "no-store" {
+		t.Fatalf("Expected Cache-Control no-store, got %s", cacheControl)
+	}
+}
+
+func TestRegisterUserValidationError(t *testing.T) {
+	handler := NewUserHandler(NewMockUserService())
+
+	bodies := []string{
+		`{"username": "Calm-Walker-7"}`,                                     // Missing device_id
+		`{"username": "x", "device_id": "abc"}`,                             // Username too short
+		`{"id": "bad id", "username": "Calm-Walker-7", "device_id": "abc"}`, // Invalid id
+	}
+
+	for _, body := range bodies {
+		req := httptest.NewRequest("POST", "/users", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+
+		handler.RegisterUser(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status 400 for %s, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestRegisterUserConflict(t *testing.T) {
+	handler := NewUserHandler(NewMockUserService())
+
+	body := `{"id": "user_1", "username": "Calm-Walker-7", "device_id": "abc"}`
+	req := httptest.NewRequest("POST", "/users", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+
+	handler.RegisterUser(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d", w.Code)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	handler := NewUserHandler(NewMockUserService())
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/users/user_1", nil), map[string]string{"id": "user_1"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.GetUser(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var user database.User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if user.Username != "Swift-Runner-1" {
+		t.Fatalf("Expected username Swift-Runner-1, got %s", user.Username)
+	}
+}
+
+func TestGetUserNotFound(t *testing.T) {
+	handler := NewUserHandler(NewMockUserService())
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/users/user_2", nil), map[string]string{"id": "user_2"})
+	req = withUser(req, "user_2")
+	w := httptest.NewRecorder()
+
+	handler.GetUser(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestGetUserOtherUser(t *testing.T) {
+	handler := NewUserHandler(NewMockUserService())
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/users/user_1", nil), map[string]string{"id": "user_1"})
+	req = withUser(req, "user_2")
+	w := httptest.NewRecorder()
+
+	handler.GetUser(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestRenameUser(t *testing.T) {
+	handler := NewUserHandler(NewMockUserService())
+
+	body := `{"username": "Bold-Dreamer-9"}`
+	req := mux.SetURLVars(httptest.NewRequest("PATCH", "/users/user_1", bytes.NewBufferString(body)), map[string]string{"id": "user_1"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.RenameUser(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var user database.User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if user.Username != "Bold-Dreamer-9" {
+		t.Fatalf("Expected username Bold-Dreamer-9, got %s", user.Username)
+	}
+}
+
+func TestRenameUserInvalidUsername(t *testing.T) {
+	handler := NewUserHandler(NewMockUserService())
+
+	body := `{"username": "no spaces allowed"}`
+	req := mux.SetURLVars(httptest.NewRequest("PATCH", "/users/user_1", bytes.NewBufferString(body)), map[string]string{"id": "user_1"})
+	req = withUser(req, "user_1")
+	w := httptest.NewRecorder()
+
+	handler.RenameUser(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+}
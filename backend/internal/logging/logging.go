@@ -0,0 +1,104 @@
+// Package logging builds the structured logger shared by every layer of the API
+// and carries the request ID through contexts so each log line can be correlated
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// RequestIDHeader carries the request ID on incoming requests, responses and outbound AI calls
+const RequestIDHeader = "X-Request-ID"
+
+// redacted replaces payload attributes unless debug logging is enabled
+const redacted = "[REDACTED]"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID of ctx, if any
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok && requestID != ""
+}
+
+// ParseLevel parses debug, info, warn or error (case-insensitive)
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", value)
+	}
+	return level, nil
+}
+
+// New returns a logger writing to w at level, as JSON lines when format is "json"
+// and as key=value text otherwise
+// Every record logged with a context carries that context's request_id, and
+// Payload attributes are redacted unless level is debug
+func New(w io.Writer, level slog.Level, format string) *slog.Logger {
+	debug := level <= slog.LevelDebug
+	options := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if p, ok := attr.Value.Any().(payload); ok {
+				if !debug {
+					return slog.String(attr.Key, redacted)
+				}
+				return slog.Any(attr.Key, p.value)
+			}
+			return attr
+		},
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// Discard returns a logger that drops every record
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// payload marks user health data that is only logged at debug level
+type payload struct {
+	value any
+}
+
+// Payload returns an attribute holding user-submitted data such as a routine log
+// Loggers created by New replace its value with "[REDACTED]" unless debug logging is on
+func Payload(key string, value any) slog.Attr {
+	return slog.Any(key, payload{value: value})
+}
+
+// contextHandler adds the request ID of the record's context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID, ok := RequestIDFromContext(ctx); ok {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
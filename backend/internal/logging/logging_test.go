@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	for value, expected := range cases {
+		level, err := ParseLevel(value)
+		if err != nil {
+			t.Fatalf("Expected %q to parse, got %v", value, err)
+		}
+		if level != expected {
+			t.Fatalf("Expected %s for %q, got %s", expected, value, level)
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatal("Expected error for unknown level")
+	}
+}
+
+func TestNewAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo, "json")
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.InfoContext(ctx, "hello", "user_id", "user_1")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected a JSON log line, got %q", buf.String())
+	}
+	if line["request_id"] != "req-1" {
+		t.Fatalf("Expected request_id req-1, got %v", line["request_id"])
+	}
+	if line["user_id"] != "user_1" {
+		t.Fatalf("Expected user_id user_1, got %v", line["user_id"])
+	}
+
+	// Records logged without a request context carry no request_id
+	buf.Reset()
+	logger.Info("hello")
+	if strings.Contains(buf.String(), "request_id") {
+		t.Fatalf("Expected no request_id, got %q", buf.String())
+	}
+}
+
+func TestNewRedactsPayloads(t *testing.T) {
+	routine := map[string]any{"sleep_hours": 7.5}
+
+	var buf bytes.Buffer
+	New(&buf, slog.LevelInfo, "json").Info("calling AI service", Payload("request", routine))
+	if strings.Contains(buf.String(), "sleep_hours") || !strings.Contains(buf.String(), `"request":"[REDACTED]"`) {
+		t.Fatalf("Expected payload to be redacted at info level, got %q", buf.String())
+	}
+
+	buf.Reset()
+	New(&buf, slog.LevelDebug, "json").Info("calling AI service", Payload("request", routine))
+	if !strings.Contains(buf.String(), `"request":{"sleep_hours":7.5}`) {
+		t.Fatalf("Expected payload to be logged at debug level, got %q", buf.String())
+	}
+}
+
+func TestNewTextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, slog.LevelWarn, "text").Info("dropped")
+	if buf.Len() != 0 {
+		t.Fatalf("Expected info record to be dropped at warn level, got %q", buf.String())
+	}
+
+	New(&buf, slog.LevelWarn, "text").Warn("kept", "log_id", "log_1")
+	if !strings.Contains(buf.String(), "msg=kept") || !strings.Contains(buf.String(), "log_id=log_1") {
+		t.Fatalf("Expected a key=value line, got %q", buf.String())
+	}
+}
@@ -0,0 +1,386 @@
+// Package metrics keeps counters, gauges and histograms in memory and writes them in the
+// Prometheus text exposition format, so /metrics can be scraped without a client library
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"lifepattern-api/internal/apperror"
+)
+
+// ContentType is the media type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are latency histogram buckets in seconds, from 5ms to 10s
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// family is one named metric with its samples
+type family interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds the metrics exposed by /metrics
+type Registry struct {
+	mu         sync.Mutex
+	families   []family
+	collectors []func(ctx context.Context)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.families {
+		if existing.name() == f.name() {
+			panic(fmt.Sprintf("metrics: %s registered twice", f.name()))
+		}
+	}
+	r.families = append(r.families, f)
+}
+
+// OnScrape registers fn to run before every scrape, e.g. to set gauges from a
+// snapshot such as sql.DB.Stats; ctx is the scrape request's context
+func (r *Registry) OnScrape(fn func(ctx context.Context)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, fn)
+}
+
+// Write runs the scrape hooks, then writes every metric sorted by name
+func (r *Registry) Write(ctx context.Context, w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]func(context.Context){}, r.collectors...)
+	families := append([]family{}, r.families...)
+	r.mu.Unlock()
+
+	for _, collect := range collectors {
+		collect(ctx)
+	}
+
+	sort.Slice(families, func(i, j int) bool { return families[i].name() < families[j].name() })
+
+	buf := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(buf)
+	}
+	return buf.Flush()
+}
+
+// Handler serves the registry in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			apperror.Write(w, req, apperror.ErrMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentType)
+		r.Write(req.Context(), w)
+	})
+}
+
+// desc describes a metric family and tracks one value per label combination
+type desc struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.metricName, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.metricName, d.kind)
+}
+
+// key joins label values into a map key, panicking on a label count mismatch
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.metricName, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs renders {name="value",...} for values, with extra pairs appended
+func (d *desc) labelPairs(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(d.labels)+len(extra)/2)
+	for i, label := range d.labels {
+		pairs = append(pairs, label+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// series is a single labelled value of a counter or gauge
+type series struct {
+	values []string
+	value  float64
+}
+
+// vector stores counter and gauge values by label combination
+type vector struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newVector(name, help, kind string, labels []string) *vector {
+	return &vector{
+		desc:   desc{metricName: name, help: help, kind: kind, labels: labels},
+		series: make(map[string]*series),
+	}
+}
+
+func (v *vector) update(values []string, fn func(current float64) float64) {
+	key := v.key(values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: append([]string{}, values...)}
+		v.series[key] = s
+	}
+	s.value = fn(s.value)
+}
+
+func (v *vector) get(values []string) float64 {
+	key := v.key(values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if s, ok := v.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (v *vector) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.writeHeader(w)
+	for _, key := range sortedKeys(v.series) {
+		s := v.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, v.labelPairs(s.values), formatFloat(s.value))
+	}
+}
+
+// Counter is a monotonically increasing value per label combination
+type Counter struct {
+	*vector
+}
+
+// NewCounter registers a counter; by convention its name ends in _total
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vector: newVector(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the counter for labelValues
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative, to the counter for labelValues
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.metricName))
+	}
+	c.update(labelValues, func(current float64) float64 { return current + delta })
+}
+
+// Value returns the counter for labelValues
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.get(labelValues)
+}
+
+// Gauge is a value per label combination that can go up and down
+type Gauge struct {
+	*vector
+}
+
+// NewGauge registers a gauge
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vector: newVector(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// Set sets the gauge for labelValues
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.update(labelValues, func(float64) float64 { return value })
+}
+
+// Value returns the gauge for labelValues
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.get(labelValues)
+}
+
+// valueFunc is an unlabelled metric whose value is read at scrape time
+type valueFunc struct {
+	desc
+	fn func() float64
+}
+
+func (v *valueFunc) write(w *bufio.Writer) {
+	v.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", v.metricName, formatFloat(v.fn()))
+}
+
+// NewGaugeFunc registers a gauge whose value is fn() at scrape time
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&valueFunc{desc: desc{metricName: name, help: help, kind: "gauge"}, fn: fn})
+}
+
+// NewCounterFunc registers a counter whose value is fn() at scrape time, for totals
+// kept elsewhere such as sql.DBStats.WaitCount
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&valueFunc{desc: desc{metricName: name, help: help, kind: "counter"}, fn: fn})
+}
+
+// Histogram counts observations into cumulative buckets per label combination
+type Histogram struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram with the given upper bounds, sorted ascending
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	h := &Histogram{
+		desc:    desc{metricName: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records value, e.g. a duration in seconds, for labelValues
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			values: append([]string{}, labelValues...),
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += value
+}
+
+// Count returns the number of observations for labelValues
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(s.values, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelPairs(s.values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelPairs(s.values), s.count)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, registry *Registry) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := registry.Write(context.Background(), &buf); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return buf.String()
+}
+
+func TestCounter(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounter("test_requests_total", "Requests.", "method", "status")
+
+	requests.Inc("GET", "200")
+	requests.Inc("GET", "200")
+	requests.Add(3, "POST", "500")
+
+	expected := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{method="GET",status="200"} 2
+test_requests_total{method="POST",status="500"} 3
+`
+	if got := scrape(t, registry); got != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	if value := requests.Value("GET", "200"); value != 2 {
+		t.Fatalf("Expected counter value 2, got %v", value)
+	}
+}
+
+func TestGaugeAndFuncs(t *testing.T) {
+	registry := NewRegistry()
+	depth := registry.NewGauge("test_queue_depth", "Queue depth.")
+	registry.NewGaugeFunc("test_connections", "Open connections.", func() float64 { return 4 })
+	registry.NewCounterFunc("test_waits_total", "Waits.", func() float64 { return 1.5 })
+
+	depth.Set(7)
+	depth.Set(3)
+
+	output := scrape(t, registry)
+	for _, line := range []string{
+		"# TYPE test_queue_depth gauge\ntest_queue_depth 3\n",
+		"# TYPE test_connections gauge\ntest_connections 4\n",
+		"# TYPE test_waits_total counter\ntest_waits_total 1.5\n",
+	} {
+		if !strings.Contains(output, line) {
+			t.Fatalf("Expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+
+	// Families are written sorted by name
+	if strings.Index(output, "test_connections") > strings.Index(output, "test_queue_depth") {
+		t.Fatalf("Expected families sorted by name, got:\n%s", output)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	registry := NewRegistry()
+	latency := registry.NewHistogram("test_duration_seconds", "Latency.", []float64{0.1, 1}, "route")
+
+	latency.Observe(0.05, "/logs")
+	latency.Observe(0.1, "/logs")
+	latency.Observe(0.5, "/logs")
+	latency.Observe(3, "/logs")
+
+	expected := `# HELP test_duration_seconds Latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/logs",le="0.1"} 2
+test_duration_seconds_bucket{route="/logs",le="1"} 3
+test_duration_seconds_bucket{route="/logs",le="+Inf"} 4
+test_duration_seconds_sum{route="/logs"} 3.65
+test_duration_seconds_count{route="/logs"} 4
+`
+	if got := scrape(t, registry); got != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	if count := latency.Count("/logs"); count != 4 {
+		t.Fatalf("Expected 4 observations, got %d", count)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("test_total", "Help with \\ and\nnewline.", "value")
+	counter.Inc("quote \" backslash \\ newline \n")
+
+	output := scrape(t, registry)
+	if !strings.Contains(output, `# HELP test_total Help with \\ and\nnewline.`) {
+		t.Fatalf("Expected escaped help text, got:\n%s", output)
+	}
+	if !strings.Contains(output, `test_total{value="quote \" backslash \\ newline \n"} 1`) {
+		t.Fatalf("Expected escaped label value, got:\n%s", output)
+	}
+}
+
+func TestOnScrape(t *testing.T) {
+	registry := NewRegistry()
+	gauge := registry.NewGauge("test_state", "State.", "state")
+
+	scrapes := 0
+	registry.OnScrape(func(ctx context.Context) {
+		scrapes++
+		gauge.Set(float64(scrapes), "open")
+	})
+
+	scrape(t, registry)
+	output := scrape(t, registry)
+
+	if !strings.Contains(output, `test_state{state="open"} 2`) {
+		t.Fatalf("Expected gauge set by the scrape hook, got:\n%s", output)
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("test_total", "Test.")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected duplicate registration to panic")
+		}
+	}()
+	registry.NewGauge("test_total", "Test.")
+}
+
+func TestHandler(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("test_total", "Test.").Inc()
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	registry.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != ContentType {
+		t.Fatalf("Expected Content-Type %s, got %s", ContentType, contentType)
+	}
+	if !strings.Contains(w.Body.String(), "test_total 1\n") {
+		t.Fatalf("Expected metrics in body, got:\n%s", w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/metrics", nil)
+	w = httptest.NewRecorder()
+
+	registry.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status 405, got %d", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), `"method_not_allowed"`) {
+		t.Fatalf("Expected a JSON error body, got %s", w.Body.String())
+	}
+}
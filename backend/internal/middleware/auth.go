@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"lifepattern-api/internal/apperror"
+	"lifepattern-api/internal/auth"
+)
+
+// TokenVerifier verifies bearer tokens
+type TokenVerifier interface {
+	Verify(token string) (*auth.Claims, error)
+}
+
+// Authenticate requires a valid bearer token and stores the authenticated user in the request context
+func Authenticate(verifier TokenVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="lifepattern"`)
+				apperror.Write(w, r, apperror.New(apperror.CodeUnauthorized, "missing bearer token"))
+				return
+			}
+
+			claims, err := verifier.Verify(token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="lifepattern", error="invalid_token"`)
+				apperror.Write(w, r, apperror.New(apperror.CodeUnauthorized, "invalid or expired token"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithUserID(r.Context(), claims.UserID)))
+		})
+	}
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"lifepattern-api/internal/auth"
+)
+
+func TestAuthenticate(t *testing.T) {
+	tokens, _ := auth.NewTokenManager("secret", time.Hour)
+	token, _, _ := tokens.Issue("user_1")
+
+	var gotUser string
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _ = auth.UserIDFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := Authenticate(tokens)(testHandler)
+
+	req := httptest.NewRequest("GET", "/logs", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	if gotUser != "user_1" {
+		t.Fatalf("Expected user_1 in context, got %q", gotUser)
+	}
+}
+
+func TestAuthenticateRejectsMissingOrInvalidToken(t *testing.T) {
+	tokens, _ := auth.NewTokenManager("secret", time.Hour)
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("Expected handler not to be called")
+	})
+
+	handler := Authenticate(tokens)(testHandler)
+
+	headers := []string{"", "Bearer", "Basic dXNlcjpwYXNz", "Bearer not.a-valid-token"}
+	for _, header := range headers {
+		req := httptest.NewRequest("GET", "/logs", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status 401 for %q, got %d", header, w.Code)
+		}
+
+		if w.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("Expected WWW-Authenticate header for %q", header)
+		}
+	}
+}
@@ -1,23 +1,236 @@
 package middleware
 
-import "net/http"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
-// CORS middleware handles Cross-Origin Resource Sharing
-func CORS(next http.Handler) http.Handler {
+	"github.com/gorilla/mux"
+
+	"lifepattern-api/internal/apperror"
+)
+
+// CORSConfig lists the cross-origin requests browsers may make to the API
+type CORSConfig struct {
+	// AllowedOrigins are exact origins (https://app.example.com), wildcard subdomains
+	// (https://*.example.com) or "*" for any origin
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are response headers scripts may read, e.g. X-Request-ID
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and Authorization headers cross-origin
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// RouteMatcher reports whether a request matches a registered route; *mux.Router implements it
+type RouteMatcher interface {
+	Match(req *http.Request, match *mux.RouteMatch) bool
+}
+
+// CORS applies a CORS policy in front of a router
+// It must wrap the router rather than be added with Router.Use, because mux does not
+// run middleware for the OPTIONS preflights of routes registered without OPTIONS
+type CORS struct {
+	origins          []originPattern
+	allowedMethods   map[string]bool
+	allowedHeaders   map[string]bool
+	methods          string
+	headers          string
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
+	routes           RouteMatcher
+}
+
+// originPattern matches one configured origin
+type originPattern struct {
+	any    bool
+	scheme string
+	host   string // exact host[:port], or the suffix after "*." for wildcard subdomains
+	suffix bool
+}
+
+// NewCORS validates config and returns a policy answering preflights only for
+// requests that routes would serve
+func NewCORS(config CORSConfig, routes RouteMatcher) (*CORS, error) {
+	c := &CORS{
+		allowedMethods:   make(map[string]bool),
+		allowedHeaders:   make(map[string]bool),
+		methods:          strings.Join(config.AllowedMethods, ", "),
+		headers:          strings.Join(config.AllowedHeaders, ", "),
+		exposedHeaders:   strings.Join(config.ExposedHeaders, ", "),
+		allowCredentials: config.AllowCredentials,
+		routes:           routes,
+	}
+	if config.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(config.MaxAge.Seconds()))
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		pattern, err := parseOriginPattern(origin)
+		if err != nil {
+			return nil, err
+		}
+		if pattern.any && config.AllowCredentials {
+			return nil, errors.New("CORS: a wildcard origin cannot be combined with credentials")
+		}
+		c.origins = append(c.origins, pattern)
+	}
+	for _, method := range config.AllowedMethods {
+		c.allowedMethods[strings.ToUpper(method)] = true
+	}
+	for _, header := range config.AllowedHeaders {
+		c.allowedHeaders[http.CanonicalHeaderKey(header)] = true
+	}
+
+	return c, nil
+}
+
+func parseOriginPattern(origin string) (originPattern, error) {
+	origin = strings.TrimSpace(origin)
+	if origin == "*" {
+		return originPattern{any: true}, nil
+	}
+
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok || scheme == "" || host == "" || strings.ContainsAny(host, "/?#") {
+		return originPattern{}, fmt.Errorf("CORS: invalid origin %q (expected scheme://host[:port])", origin)
+	}
+
+	pattern := originPattern{scheme: strings.ToLower(scheme), host: strings.ToLower(host)}
+	if strings.HasPrefix(pattern.host, "*.") {
+		pattern.host = pattern.host[1:] // keep the leading dot
+		pattern.suffix = true
+	}
+	if strings.Contains(pattern.host, "*") {
+		return originPattern{}, fmt.Errorf("CORS: invalid origin %q (wildcards are only allowed as the first label)", origin)
+	}
+	return pattern, nil
+}
+
+// allowsOrigin reports whether origin matches one of the configured patterns
+func (c *CORS) allowsOrigin(origin string) bool {
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return false
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Host)
+
+	for _, pattern := range c.origins {
+		switch {
+		case pattern.any:
+			return true
+		case pattern.scheme != scheme:
+			continue
+		case pattern.suffix && strings.HasSuffix(host, pattern.host) && len(host) > len(pattern.host):
+			return true
+		case !pattern.suffix && host == pattern.host:
+			return true
+		}
+	}
+	return false
+}
+
+// Handler returns next wrapped with the policy
+// Preflights are answered here: 403 for a disallowed origin, method or header,
+// 404 or 405 when no route serves the requested method, 204 otherwise
+// Other requests from an allowed origin get the origin echoed back; requests from
+// other origins are served without CORS headers, so browsers block the response
+func (c *CORS) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
-
-		// Handle preflight requests
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(w, r, origin)
 			return
 		}
 
-		// Call the next handler
+		if origin != "" && c.allowsOrigin(origin) {
+			c.setOriginHeaders(w, origin)
+			if c.exposedHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", c.exposedHeaders)
+			}
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	if origin == "" || !c.allowsOrigin(origin) {
+		apperror.Write(w, r, apperror.New(apperror.CodeForbidden, "CORS origin not allowed"))
+		return
+	}
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !c.allowedMethods[method] {
+		apperror.Write(w, r, apperror.New(apperror.CodeForbidden, "CORS method not allowed"))
+		return
+	}
+
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !c.allowedHeaders[http.CanonicalHeaderKey(header)] {
+			apperror.Write(w, r, apperror.New(apperror.CodeForbidden, fmt.Sprintf("CORS header %s not allowed", header)))
+			return
+		}
+	}
+
+	switch c.routeStatus(r, method) {
+	case http.StatusNotFound:
+		apperror.Write(w, r, apperror.ErrRouteNotFound)
+		return
+	case http.StatusMethodNotAllowed:
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+		return
+	}
+
+	c.setOriginHeaders(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", c.methods)
+	if c.headers != "" {
+		w.Header().Set("Access-Control-Allow-Headers", c.headers)
+	}
+	if c.maxAge != "" {
+		w.Header().Set("Access-Control-Max-Age", c.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// routeStatus reports whether a route serves method at the preflight's path:
+// 200 if so, 405 if the path exists for other methods only, 404 otherwise
+func (c *CORS) routeStatus(r *http.Request, method string) int {
+	if c.routes == nil {
+		return http.StatusOK
+	}
+
+	probe := r.Clone(r.Context())
+	probe.Method = method
+
+	var match mux.RouteMatch
+	if c.routes.Match(probe, &match) && match.MatchErr == nil {
+		return http.StatusOK
+	}
+	if errors.Is(match.MatchErr, mux.ErrMethodMismatch) {
+		return http.StatusMethodNotAllowed
+	}
+	return http.StatusNotFound
+}
+
+func (c *CORS) setOriginHeaders(w http.ResponseWriter, origin string) {
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.allowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
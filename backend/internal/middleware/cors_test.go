@@ -3,40 +3,71 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
 )
 
-func TestCORS(t *testing.T) {
-	// Create a test handler
-	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// newTestCORS wraps a router serving GET and POST /logs with the given origins
+func newTestCORS(t *testing.T, origins []string, credentials bool) http.Handler {
+	t.Helper()
+
+	r := mux.NewRouter()
+	r.HandleFunc("/logs", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("test response"))
-	})
+	}).Methods("GET", "POST")
+
+	cors, err := NewCORS(CORSConfig{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: credentials,
+		MaxAge:           24 * time.Hour,
+	}, r)
+	if err != nil {
+		t.Fatalf("Expected valid CORS config, got %v", err)
+	}
+	return cors.Handler(r)
+}
+
+func newPreflight(path, origin, method, headers string) *http.Request {
+	req := httptest.NewRequest("OPTIONS", path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return req
+}
 
-	// Apply CORS middleware
-	handler := CORS(testHandler)
+func TestCORS(t *testing.T) {
+	handler := newTestCORS(t, []string{"https://app.example.com"}, true)
 
-	// Test regular request
-	req := httptest.NewRequest("GET", "/test", nil)
+	req := httptest.NewRequest("GET", "/logs", nil)
+	req.Header.Set("Origin", "https://app.example.com")
 	w := httptest.NewRecorder()
 
 	handler.ServeHTTP(w, req)
 
-	// Check CORS headers
-	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
-		t.Fatalf("Expected Access-Control-Allow-Origin: *, got %s", w.Header().Get("Access-Control-Allow-Origin"))
+	// The matching origin is echoed instead of a wildcard
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Fatalf("Expected Access-Control-Allow-Origin: https://app.example.com, got %s", w.Header().Get("Access-Control-Allow-Origin"))
 	}
 
-	if w.Header().Get("Access-Control-Allow-Methods") != "GET, POST, PUT, DELETE, OPTIONS" {
-		t.Fatalf("Expected Access-Control-Allow-Methods: GET, POST, PUT, DELETE, OPTIONS, got %s", w.Header().Get("Access-Control-Allow-Methods"))
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatalf("Expected Access-Control-Allow-Credentials: true, got %s", w.Header().Get("Access-Control-Allow-Credentials"))
 	}
 
-	if w.Header().Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" {
-		t.Fatalf("Expected Access-Control-Allow-Headers: Content-Type, Authorization, got %s", w.Header().Get("Access-Control-Allow-Headers"))
+	if w.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+		t.Fatalf("Expected Access-Control-Expose-Headers: X-Request-ID, got %s", w.Header().Get("Access-Control-Expose-Headers"))
 	}
 
-	if w.Header().Get("Access-Control-Max-Age") != "86400" {
-		t.Fatalf("Expected Access-Control-Max-Age: 86400, got %s", w.Header().Get("Access-Control-Max-Age"))
+	if w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("Expected Vary: Origin, got %s", w.Header().Get("Vary"))
 	}
 
 	if w.Code != http.StatusOK {
@@ -44,32 +75,76 @@ func TestCORS(t *testing.T) {
 	}
 }
 
-func TestCORSPreflight(t *testing.T) {
-	// Create a test handler
-	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("test response"))
-	})
+func TestCORSDisallowedOrigin(t *testing.T) {
+	handler := newTestCORS(t, []string{"https://app.example.com"}, false)
+
+	for _, origin := range []string{"https://evil.example.org", "http://app.example.com", ""} {
+		req := httptest.NewRequest("GET", "/logs", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		// The request is served, but without CORS headers browsers block the response
+		if w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("Expected no Access-Control-Allow-Origin for %q, got %s", origin, w.Header().Get("Access-Control-Allow-Origin"))
+		}
+		if w.Header().Get("Vary") != "Origin" {
+			t.Fatalf("Expected Vary: Origin for %q, got %s", origin, w.Header().Get("Vary"))
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %q, got %d", origin, w.Code)
+		}
+	}
+}
+
+func TestCORSWildcardSubdomain(t *testing.T) {
+	handler := newTestCORS(t, []string{"https://*.example.com"}, false)
+
+	origins := map[string]bool{
+		"https://app.example.com":      true,
+		"https://a.b.example.com":      true,
+		"https://APP.example.com":      true,
+		"https://example.com":          false,
+		"https://evilexample.com":      false,
+		"http://app.example.com":       false,
+		"https://app.example.com.evil": false,
+	}
+
+	for origin, allowed := range origins {
+		req := httptest.NewRequest("GET", "/logs", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
 
-	// Apply CORS middleware
-	handler := CORS(testHandler)
+		handler.ServeHTTP(w, req)
+
+		got := w.Header().Get("Access-Control-Allow-Origin") == origin
+		if got != allowed {
+			t.Fatalf("Expected origin %s allowed=%v, got Access-Control-Allow-Origin %q", origin, allowed, w.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	handler := newTestCORS(t, []string{"http://localhost:8081"}, false)
 
-	// Test preflight request
-	req := httptest.NewRequest("OPTIONS", "/test", nil)
-	req.Header.Set("Origin", "http://localhost:3000")
-	req.Header.Set("Access-Control-Request-Method", "POST")
-	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	req := newPreflight("/logs", "http://localhost:8081", "POST", "content-type, Authorization")
 	w := httptest.NewRecorder()
 
 	handler.ServeHTTP(w, req)
 
-	// Check CORS headers
-	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
-		t.Fatalf("Expected Access-Control-Allow-Origin: *, got %s", w.Header().Get("Access-Control-Allow-Origin"))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 for preflight request, got %d", w.Code)
+	}
+
+	if w.Header().Get("Access-Control-Allow-Origin") != "http://localhost:8081" {
+		t.Fatalf("Expected Access-Control-Allow-Origin: http://localhost:8081, got %s", w.Header().Get("Access-Control-Allow-Origin"))
 	}
 
-	if w.Header().Get("Access-Control-Allow-Methods") != "GET, POST, PUT, DELETE, OPTIONS" {
-		t.Fatalf("Expected Access-Control-Allow-Methods: GET, POST, PUT, DELETE, OPTIONS, got %s", w.Header().Get("Access-Control-Allow-Methods"))
+	if w.Header().Get("Access-Control-Allow-Methods") != "GET, POST, PUT, PATCH, DELETE" {
+		t.Fatalf("Expected Access-Control-Allow-Methods: GET, POST, PUT, PATCH, DELETE, got %s", w.Header().Get("Access-Control-Allow-Methods"))
 	}
 
 	if w.Header().Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" {
@@ -80,37 +155,70 @@ func TestCORSPreflight(t *testing.T) {
 		t.Fatalf("Expected Access-Control-Max-Age: 86400, got %s", w.Header().Get("Access-Control-Max-Age"))
 	}
 
-	if w.Code != http.StatusOK {
-		t.Fatalf("Expected status 200 for preflight request, got %d", w.Code)
+	if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("Expected no Access-Control-Allow-Credentials, got %s", w.Header().Get("Access-Control-Allow-Credentials"))
 	}
-}
-
-func TestCORSWithDifferentMethods(t *testing.T) {
-	// Create a test handler
-	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("test response"))
-	})
 
-	// Apply CORS middleware
-	handler := CORS(testHandler)
+	vary := strings.Join(w.Header().Values("Vary"), ", ")
+	if vary != "Origin, Access-Control-Request-Method, Access-Control-Request-Headers" {
+		t.Fatalf("Expected Vary on the origin and requested method and headers, got %s", vary)
+	}
+}
 
-	// Test different HTTP methods
-	methods := []string{"GET", "POST", "PUT", "DELETE"}
+func TestCORSRejectedPreflights(t *testing.T) {
+	handler := newTestCORS(t, []string{"http://localhost:8081"}, false)
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		expected int
+	}{
+		{"disallowed origin", newPreflight("/logs", "https://evil.example.org", "POST", ""), http.StatusForbidden},
+		{"disallowed method", newPreflight("/logs", "http://localhost:8081", "TRACE", ""), http.StatusForbidden},
+		{"disallowed header", newPreflight("/logs", "http://localhost:8081", "POST", "Content-Type, X-Debug"), http.StatusForbidden},
+		{"unknown route", newPreflight("/unknown", "http://localhost:8081", "GET", ""), http.StatusNotFound},
+		{"method not served by route", newPreflight("/logs", "http://localhost:8081", "DELETE", ""), http.StatusMethodNotAllowed},
+	}
 
-	for _, method := range methods {
-		req := httptest.NewRequest(method, "/test", nil)
+	for _, tt := range tests {
 		w := httptest.NewRecorder()
 
-		handler.ServeHTTP(w, req)
+		handler.ServeHTTP(w, tt.req)
 
-		// Check CORS headers are present
-		if w.Header().Get("Access-Control-Allow-Origin") != "*" {
-			t.Fatalf("Expected Access-Control-Allow-Origin: * for %s request, got %s", method, w.Header().Get("Access-Control-Allow-Origin"))
+		if w.Code != tt.expected {
+			t.Fatalf("%s: expected status %d, got %d", tt.name, tt.expected, w.Code)
 		}
+		if w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("%s: expected no Access-Control-Allow-Origin, got %s", tt.name, w.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+}
 
-		if w.Code != http.StatusOK {
-			t.Fatalf("Expected status 200 for %s request, got %d", method, w.Code)
+func TestCORSAnyOrigin(t *testing.T) {
+	handler := newTestCORS(t, []string{"*"}, false)
+
+	req := httptest.NewRequest("GET", "/logs", nil)
+	req.Header.Set("Origin", "https://anywhere.example.org")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://anywhere.example.org" {
+		t.Fatalf("Expected the origin to be echoed, got %s", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestNewCORSInvalidConfig(t *testing.T) {
+	configs := map[string]CORSConfig{
+		"wildcard with credentials": {AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		"missing scheme":            {AllowedOrigins: []string{"app.example.com"}},
+		"path in origin":            {AllowedOrigins: []string{"https://app.example.com/app"}},
+		"inner wildcard":            {AllowedOrigins: []string{"https://app.*.example.com"}},
+	}
+
+	for name, config := range configs {
+		if _, err := NewCORS(config, nil); err == nil {
+			t.Fatalf("%s: expected error", name)
 		}
 	}
 }
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifepattern-api/internal/metrics"
+)
+
+// unmatchedRoute labels requests that did not match a registered route
+const unmatchedRoute = "unmatched"
+
+// otherMethod labels requests with a method outside knownMethods
+const otherMethod = "OTHER"
+
+// knownMethods are the request methods Metrics labels as is; clients can send any
+// token as the method, so the rest share otherMethod to keep the number of series bounded
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+	http.MethodHead:    true,
+}
+
+// routeKey is the context key of the route template Metrics labels a request with
+type routeKey struct{}
+
+// Metrics counts requests and observes their latency per route template and status
+// Routes are labelled with their template (/logs/{id:[0-9]+}), never the raw path,
+// so the number of series stays bounded. Wrapped around the router, it only sees the
+// matched route through RecordRoute; requests no route matched are labelled "unmatched"
+func Metrics(registry *metrics.Registry) func(http.Handler) http.Handler {
+	requests := registry.NewCounter("lifepattern_http_requests_total",
+		"HTTP requests by method, route and status code.", "method", "route", "status")
+	duration := registry.NewHistogram("lifepattern_http_request_duration_seconds",
+		"HTTP request latency by method and route.", metrics.DefaultBuckets, "method", "route")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+			route := routeTemplate(r)
+
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), routeKey{}, &route)))
+
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+
+			method := methodLabel(r.Method)
+			requests.Inc(method, route, strconv.Itoa(recorder.status))
+			duration.Observe(time.Since(start).Seconds(), method, route)
+		})
+	}
+}
+
+// RecordRoute passes the template of the matched route out to Metrics
+// Register it with the router's Use when Metrics wraps the router
+func RecordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			*route = routeTemplate(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// methodLabel returns the method label of a request method
+func methodLabel(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return otherMethod
+}
+
+// routeTemplate returns the path template of the mux route that matched r
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return unmatchedRoute
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return unmatchedRoute
+	}
+	return template
+}
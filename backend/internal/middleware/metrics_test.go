@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"lifepattern-api/internal/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	r := mux.NewRouter()
+	r.Use(Metrics(registry))
+	r.HandleFunc("/logs/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "2" {
+			http.Error(w, "Routine log not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}).Methods("GET")
+
+	for _, path := range []string{"/logs/1", "/logs/3", "/logs/2"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+	}
+
+	var buf bytes.Buffer
+	registry.Write(context.Background(), &buf)
+	output := buf.String()
+
+	// Requests are labelled with the route template, not the raw path
+	for _, line := range []string{
+		`lifepattern_http_requests_total{method="GET",route="/logs/{id:[0-9]+}",status="200"} 2`,
+		`lifepattern_http_requests_total{method="GET",route="/logs/{id:[0-9]+}",status="404"} 1`,
+		`lifepattern_http_request_duration_seconds_count{method="GET",route="/logs/{id:[0-9]+}"} 3`,
+	} {
+		if !strings.Contains(output, line) {
+			t.Fatalf("Expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+	if strings.Contains(output, "/logs/1") {
+		t.Fatalf("Expected no raw paths in labels, got:\n%s", output)
+	}
+}
+
+func TestMetricsAroundRouter(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	r := mux.NewRouter()
+	r.Use(RecordRoute)
+	r.HandleFunc("/logs/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}).Methods("GET")
+	handler := Metrics(registry)(r)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/logs/1", nil),
+		httptest.NewRequest("GET", "/does-not-exist", nil),
+		httptest.NewRequest("DELETE", "/logs/1", nil),
+		httptest.NewRequest("PROPFIND", "/logs/1", nil),
+		httptest.NewRequest("X-RANDOM-1", "/logs/1", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var buf bytes.Buffer
+	registry.Write(context.Background(), &buf)
+	output := buf.String()
+
+	// Requests the router rejects are counted without their raw path
+	for _, line := range []string{
+		`lifepattern_http_requests_total{method="GET",route="/logs/{id:[0-9]+}",status="200"} 1`,
+		`lifepattern_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`lifepattern_http_requests_total{method="DELETE",route="unmatched",status="405"} 1`,
+		`lifepattern_http_requests_total{method="OTHER",route="unmatched",status="405"} 2`,
+	} {
+		if !strings.Contains(output, line) {
+			t.Fatalf("Expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+	if strings.Contains(output, "does-not-exist") {
+		t.Fatalf("Expected no raw paths in labels, got:\n%s", output)
+	}
+
+	// Arbitrary methods share one label
+	if strings.Contains(output, "PROPFIND") || strings.Contains(output, "X-RANDOM-1") {
+		t.Fatalf("Expected unknown methods to be labelled OTHER, got:\n%s", output)
+	}
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lifepattern-api/internal/apperror"
+	"lifepattern-api/internal/auth"
+)
+
+// errRateLimited is returned once a client has used up its bucket
+var errRateLimited = apperror.New(apperror.CodeRateLimited, "rate limit exceeded, retry later")
+
+// RateLimitConfig sets the limits per client and which proxies may report the client IP
+type RateLimitConfig struct {
+	// Read limits GET, HEAD and OPTIONS requests
+	Read RateLimit
+	// Write limits every other method, e.g. POST /log with its AI analysis
+	Write RateLimit
+	// TrustedProxies are IPs or CIDRs whose X-Forwarded-For header is honoured
+	TrustedProxies []string
+}
+
+// RateLimiter limits requests per authenticated user, or per client IP for anonymous requests
+type RateLimiter struct {
+	config  RateLimitConfig
+	store   RateLimitStore
+	logger  *slog.Logger
+	proxies []*net.IPNet
+}
+
+// NewRateLimiter validates config; the limiter keeps its buckets in store
+func NewRateLimiter(config RateLimitConfig, store RateLimitStore, logger *slog.Logger) (*RateLimiter, error) {
+	for _, limit := range []RateLimit{config.Read, config.Write} {
+		if limit.Rate <= 0 || limit.Burst <= 0 {
+			return nil, fmt.Errorf("rate limit: rate and burst must be positive, got %v/s with burst %d", limit.Rate, limit.Burst)
+		}
+	}
+
+	proxies, err := parseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RateLimiter{
+		config:  config,
+		store:   store,
+		logger:  logger,
+		proxies: proxies,
+	}, nil
+}
+
+func parseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("rate limit: invalid trusted proxy %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit: invalid trusted proxy %q: %w", value, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// Handler limits requests to next
+// Routes behind Authenticate are limited per user, other routes per client IP
+// Every limited response carries RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset;
+// rejected requests get 429 with Retry-After
+func (l *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class, limit := "read", l.config.Read
+		if !isReadMethod(r.Method) {
+			class, limit = "write", l.config.Write
+		}
+		key := class + ":" + l.clientKey(r)
+
+		result, err := l.store.Take(r.Context(), key, limit)
+		if err != nil {
+			// A broken store must not take the API down with it
+			l.logger.WarnContext(r.Context(), "rate limit store failed, allowing request", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, ceilSeconds(limit.window())))
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			l.logger.InfoContext(r.Context(), "rate limit exceeded", "key", key)
+			apperror.Write(w, r, errRateLimited)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientKey identifies the client: the authenticated user, otherwise its IP
+func (l *RateLimiter) clientKey(r *http.Request) string {
+	if userID, ok := auth.UserIDFromContext(r.Context()); ok {
+		return "user:" + userID
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+// clientIP returns the peer address, or the address a trusted proxy forwarded for
+// X-Forwarded-For is read right to left, skipping trusted proxies, so a client cannot
+// pick its own key by sending a forged header
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !l.trusted(remote) {
+		return remote
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		if net.ParseIP(hops[i]) == nil {
+			break
+		}
+		client = hops[i]
+		if !l.trusted(hops[i]) {
+			break
+		}
+	}
+	return client
+}
+
+func (l *RateLimiter) trusted(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// ceilSeconds rounds d up to whole seconds, as the rate limit headers require
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
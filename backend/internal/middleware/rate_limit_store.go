@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket: Burst requests at once, refilled at Rate requests per second
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// window is how long an empty bucket takes to refill completely
+func (l RateLimit) window() time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// RetryAfter is how long until a token is available, 0 when Allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// RateLimitStore keeps token buckets by key
+// Implementations must be safe for concurrent use; a shared store (e.g. Redis) lets
+// several API instances enforce one limit
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// memoryBucketSweepInterval is how often full buckets are dropped from a MemoryRateLimitStore
+const memoryBucketSweepInterval = time.Minute
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	limit   RateLimit
+}
+
+// refill adds the tokens earned since the last update, up to the burst
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.updated = now
+	}
+}
+
+// MemoryRateLimitStore keeps token buckets in process memory
+// Limits are per API instance; buckets that have refilled completely are dropped
+type MemoryRateLimitStore struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Take removes one token from the bucket for key, creating a full bucket if needed
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	bucket, ok := s.buckets[key]
+	if !ok || bucket.limit != limit {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), updated: now, limit: limit}
+		s.buckets[key] = bucket
+	}
+	bucket.refill(now)
+
+	result := RateLimitResult{}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else if limit.Rate > 0 {
+		result.RetryAfter = time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+	}
+
+	result.Remaining = int(bucket.tokens)
+	if limit.Rate > 0 {
+		result.Reset = time.Duration((float64(limit.Burst) - bucket.tokens) / limit.Rate * float64(time.Second))
+	}
+	return result, nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memoryBucketSweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) >= bucket.limit.window() {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestRateLimitStore returns a store whose clock is controlled by the returned pointer
+func newTestRateLimitStore() (*MemoryRateLimitStore, *time.Time) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestMemoryRateLimitStoreBurstAndRefill(t *testing.T) {
+	store, now := newTestRateLimitStore()
+	limit := RateLimit{Rate: 1, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		result, _ := store.Take(context.Background(), "user_1", limit)
+		if !result.Allowed {
+			t.Fatalf("Expected request %d within the burst to be allowed", i+1)
+		}
+		if result.Remaining != 2-i {
+			t.Fatalf("Expected %d remaining, got %d", 2-i, result.Remaining)
+		}
+	}
+
+	result, _ := store.Take(context.Background(), "user_1", limit)
+	if result.Allowed {
+		t.Fatal("Expected request beyond the burst to be rejected")
+	}
+	if result.RetryAfter != time.Second {
+		t.Fatalf("Expected retry after 1s, got %s", result.RetryAfter)
+	}
+	if result.Reset != 3*time.Second {
+		t.Fatalf("Expected the bucket to be full after 3s, got %s", result.Reset)
+	}
+
+	// Half a token is not enough
+	*now = now.Add(500 * time.Millisecond)
+	result, _ = store.Take(context.Background(), "user_1", limit)
+	if result.Allowed || result.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("Expected rejection with 500ms retry, got %+v", result)
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	result, _ = store.Take(context.Background(), "user_1", limit)
+	if !result.Allowed {
+		t.Fatal("Expected a refilled token to be allowed")
+	}
+
+	// Other keys have their own bucket
+	result, _ = store.Take(context.Background(), "user_2", limit)
+	if !result.Allowed || result.Remaining != 2 {
+		t.Fatalf("Expected a full bucket for another key, got %+v", result)
+	}
+}
+
+func TestMemoryRateLimitStoreRefillCapsAtBurst(t *testing.T) {
+	store, now := newTestRateLimitStore()
+	limit := RateLimit{Rate: 10, Burst: 2}
+
+	store.Take(context.Background(), "ip:10.0.0.1", limit)
+	*now = now.Add(time.Hour)
+
+	result, _ := store.Take(context.Background(), "ip:10.0.0.1", limit)
+	if result.Remaining != 1 {
+		t.Fatalf("Expected the bucket to refill to its burst of 2, got %d remaining after one request", result.Remaining)
+	}
+}
+
+func TestMemoryRateLimitStoreDropsFullBuckets(t *testing.T) {
+	store, now := newTestRateLimitStore()
+	limit := RateLimit{Rate: 1, Burst: 5}
+
+	store.Take(context.Background(), "user_1", limit)
+	*now = now.Add(2 * time.Minute)
+	store.Take(context.Background(), "user_2", limit)
+
+	store.mu.Lock()
+	_, kept := store.buckets["user_1"]
+	buckets := len(store.buckets)
+	store.mu.Unlock()
+
+	if kept || buckets != 1 {
+		t.Fatalf("Expected the idle bucket to be dropped, got %d buckets", buckets)
+	}
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lifepattern-api/internal/auth"
+	"lifepattern-api/internal/logging"
+)
+
+func newTestRateLimiter(t *testing.T, store RateLimitStore, trustedProxies ...string) http.Handler {
+	t.Helper()
+
+	limiter, err := NewRateLimiter(RateLimitConfig{
+		Read:           RateLimit{Rate: 1, Burst: 3},
+		Write:          RateLimit{Rate: 0.1, Burst: 1},
+		TrustedProxies: trustedProxies,
+	}, store, logging.Discard())
+	if err != nil {
+		t.Fatalf("Expected valid rate limit config, got %v", err)
+	}
+
+	return limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestRateLimiter(t *testing.T) {
+	store, _ := newTestRateLimitStore()
+	handler := newTestRateLimiter(t, store)
+
+	req := httptest.NewRequest("POST", "/log", nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), "user_1"))
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("Expected RateLimit-Limit 1 and RateLimit-Remaining 0, got %s and %s",
+			w.Header().Get("RateLimit-Limit"), w.Header().Get("RateLimit-Remaining"))
+	}
+	if w.Header().Get("RateLimit-Reset") != "10" {
+		t.Fatalf("Expected RateLimit-Reset 10, got %s", w.Header().Get("RateLimit-Reset"))
+	}
+	if w.Header().Get("RateLimit-Policy") != "1;w=10" {
+		t.Fatalf("Expected RateLimit-Policy 1;w=10, got %s", w.Header().Get("RateLimit-Policy"))
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "10" {
+		t.Fatalf("Expected Retry-After 10, got %s", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimiterSeparatesReadsAndWrites(t *testing.T) {
+	store, _ := newTestRateLimitStore()
+	handler := newTestRateLimiter(t, store)
+
+	ctx := auth.WithUserID(context.Background(), "user_1")
+
+	// Exhaust the write limit
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/log", nil).WithContext(ctx)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Reads have their own bucket
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/logs", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected read %d to be allowed, got %d", i+1, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/logs", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 after the read burst, got %d", w.Code)
+	}
+}
+
+func TestRateLimiterKeysByUserThenIP(t *testing.T) {
+	store, _ := newTestRateLimitStore()
+	handler := newTestRateLimiter(t, store)
+
+	send := func(userID, remoteAddr string) int {
+		req := httptest.NewRequest("POST", "/users", nil)
+		req.RemoteAddr = remoteAddr
+		if userID != "" {
+			req = req.WithContext(auth.WithUserID(req.Context(), userID))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Users behind the same IP have separate limits
+	if code := send("user_1", "10.0.0.1:1234"); code != http.StatusOK {
+		t.Fatalf("Expected user_1 to be allowed, got %d", code)
+	}
+	if code := send("user_2", "10.0.0.1:1234"); code != http.StatusOK {
+		t.Fatalf("Expected user_2 to be allowed, got %d", code)
+	}
+
+	// Anonymous requests are limited per IP
+	if code := send("", "10.0.0.1:1234"); code != http.StatusOK {
+		t.Fatalf("Expected the first anonymous request to be allowed, got %d", code)
+	}
+	if code := send("", "10.0.0.1:5678"); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the second anonymous request from the same IP to be limited, got %d", code)
+	}
+	if code := send("", "10.0.0.2:1234"); code != http.StatusOK {
+		t.Fatalf("Expected another IP to be allowed, got %d", code)
+	}
+}
+
+func TestRateLimiterClientIP(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{
+		Read:           RateLimit{Rate: 1, Burst: 1},
+		Write:          RateLimit{Rate: 1, Burst: 1},
+		TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"},
+	}, NewMemoryRateLimitStore(), logging.Discard())
+	if err != nil {
+		t.Fatalf("Expected valid rate limit config, got %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		expectedIP   string
+	}{
+		{"direct client", "203.0.113.7:1234", "", "203.0.113.7"},
+		{"untrusted peer cannot forge the header", "203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.5:1234", "198.51.100.1", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.5:1234", "198.51.100.1, 192.168.1.1, 10.1.2.3", "198.51.100.1"},
+		{"forged entry left of the real client", "10.0.0.5:1234", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"trusted proxy without header", "10.0.0.5:1234", "", "10.0.0.5"},
+		{"garbage entry", "10.0.0.5:1234", "198.51.100.1, not-an-ip", "10.0.0.5"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/logs", nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		}
+
+		if ip := limiter.clientIP(req); ip != tt.expectedIP {
+			t.Fatalf("%s: expected client IP %s, got %s", tt.name, tt.expectedIP, ip)
+		}
+	}
+}
+
+// failingRateLimitStore simulates an unreachable shared store
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store unavailable")
+}
+
+func TestRateLimiterStoreFailureAllowsRequests(t *testing.T) {
+	handler := newTestRateLimiter(t, failingRateLimitStore{})
+
+	req := httptest.NewRequest("POST", "/log", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 when the store fails, got %d", w.Code)
+	}
+}
+
+func TestNewRateLimiterInvalidConfig(t *testing.T) {
+	configs := map[string]RateLimitConfig{
+		"zero rate":     {Read: RateLimit{Rate: 0, Burst: 1}, Write: RateLimit{Rate: 1, Burst: 1}},
+		"zero burst":    {Read: RateLimit{Rate: 1, Burst: 1}, Write: RateLimit{Rate: 1, Burst: 0}},
+		"invalid proxy": {Read: RateLimit{Rate: 1, Burst: 1}, Write: RateLimit{Rate: 1, Burst: 1}, TrustedProxies: []string{"proxy.local"}},
+		"invalid CIDR":  {Read: RateLimit{Rate: 1, Burst: 1}, Write: RateLimit{Rate: 1, Burst: 1}, TrustedProxies: []string{"10.0.0.0/33"}},
+	}
+
+	for name, config := range configs {
+		if _, err := NewRateLimiter(config, NewMemoryRateLimitStore(), logging.Discard()); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
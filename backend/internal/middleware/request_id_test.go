@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lifepattern-api/internal/logging"
+)
+
+func TestRequestID(t *testing.T) {
+	var gotID string
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID, _ = logging.RequestIDFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := RequestID(testHandler)
+
+	// A valid client ID is propagated
+	req := httptest.NewRequest("GET", "/logs", nil)
+	req.Header.Set("X-Request-ID", "client-id-123")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if gotID != "client-id-123" {
+		t.Fatalf("Expected client-id-123 in context, got %q", gotID)
+	}
+	if header := w.Header().Get("X-Request-ID"); header != "client-id-123" {
+		t.Fatalf("Expected X-Request-ID: client-id-123, got %q", header)
+	}
+}
+
+func TestRequestIDGeneratesMissingOrInvalidID(t *testing.T) {
+	var gotID string
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID, _ = logging.RequestIDFromContext(r.Context())
+	})
+
+	handler := RequestID(testHandler)
+
+	headers := []string{"", "has spaces", "line\nbreak", string(make([]byte, 200))}
+	seen := make(map[string]bool)
+	for _, header := range headers {
+		req := httptest.NewRequest("GET", "/logs", nil)
+		if header != "" {
+			req.Header.Set("X-Request-ID", header)
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if len(gotID) != 32 {
+			t.Fatalf("Expected a generated 32 character ID for %q, got %q", header, gotID)
+		}
+		if w.Header().Get("X-Request-ID") != gotID {
+			t.Fatalf("Expected response header %q, got %q", gotID, w.Header().Get("X-Request-ID"))
+		}
+		if seen[gotID] {
+			t.Fatalf("Expected unique request IDs, got %q twice", gotID)
+		}
+		seen[gotID] = true
+	}
+}
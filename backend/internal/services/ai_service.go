@@ -2,19 +2,22 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
 )
 
 type AIService struct {
 	baseURL    string
 	httpClient *http.Client
+	logger     *slog.Logger
 }
 
 type AIServiceRequest struct {
@@ -34,22 +37,56 @@ type AIServiceResponse struct {
 	AnomalyType     string   `json:"anomaly_type"`
 	Recommendations []string `json:"recommendations"`
 	Timestamp       string   `json:"timestamp"`
+	// ModelName, ModelVersion, ModelAccuracy and FeatureNames identify the model behind the prediction;
+	// they are empty for analyzers without a trained model and for AI services predating them
+	ModelName     string   `json:"model_name,omitempty"`
+	ModelVersion  string   `json:"model_version,omitempty"`
+	ModelAccuracy *float64 `json:"model_accuracy,omitempty"`
+	FeatureNames  []string `json:"feature_names,omitempty"`
+	// Source names the analyzer, e.g. SourceAIService or SourceLocalRules
+	Source string `json:"-"`
+	// Baseline is set by BaselineDetector; it is not part of the AI service's answer
+	Baseline *database.BaselineReport `json:"-"`
+	// Analyses lists the answer of every analyzer of an AnalyzerChain
+	Analyses []database.AnalyzerResult `json:"-"`
+	// Fallback is set by an AnalyzerChain whose primary analyzer did not answer;
+	// the report is re-analyzed once it is back
+	Fallback bool `json:"-"`
 }
 
-func NewAIService(baseURL string) *AIService {
+// DefaultAIServiceTimeout bounds a single AI service call when no timeout is configured
+const DefaultAIServiceTimeout = 30 * time.Second
+
+// AIServiceStatusError is returned when the AI service answers with a non-200 status
+// Body is left out of Error() because validation errors may echo the submitted health data
+type AIServiceStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *AIServiceStatusError) Error() string {
+	return fmt.Sprintf("AI service error (status %d)", e.StatusCode)
+}
+
+// NewAIService creates a client for the AI service; timeout bounds each HTTP call
+func NewAIService(baseURL string, timeout time.Duration, logger *slog.Logger) *AIService {
+	if timeout <= 0 {
+		timeout = DefaultAIServiceTimeout
+	}
+
 	return &AIService{
 		baseURL: baseURL,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: timeout,
 		},
+		logger: logger,
 	}
 }
 
 // AnalyzeRoutine sends routine data to AI service and returns analysis
 // This method handles the communication between Backend and AI Service
-func (s *AIService) AnalyzeRoutine(routineLog database.RoutineLog) (*AIServiceResponse, error) {
-	log.Printf("🤖 Sending routine data to AI service at %s/predict", s.baseURL)
-
+// The call is aborted when ctx is cancelled, e.g. because the client disconnected
+func (s *AIService) AnalyzeRoutine(ctx context.Context, routineLog database.RoutineLog) (*AIServiceResponse, error) {
 	request := AIServiceRequest{
 		SleepHours:       routineLog.SleepHours,
 		MealTimes:        routineLog.MealTimes,
@@ -63,61 +100,79 @@ func (s *AIService) AnalyzeRoutine(routineLog database.RoutineLog) (*AIServiceRe
 
 	requestJSON, err := json.Marshal(request)
 	if err != nil {
-		log.Printf("❌ Failed to marshal AI service request: %v", err)
 		return nil, fmt.Errorf("failed to marshal AI service request: %w", err)
 	}
 
-	log.Printf("📤 Sending request to AI service: %s", string(requestJSON))
+	s.logger.InfoContext(ctx, "calling AI service",
+		"url", s.baseURL+"/predict",
+		logging.Payload("request", json.RawMessage(requestJSON)))
 
-	resp, err := s.httpClient.Post(s.baseURL+"/predict", "application/json", bytes.NewBuffer(requestJSON))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/predict", bytes.NewBuffer(requestJSON))
 	if err != nil {
-		log.Printf("❌ Failed to call AI service: %v", err)
+		return nil, fmt.Errorf("failed to build AI service request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if requestID, ok := logging.RequestIDFromContext(ctx); ok {
+		req.Header.Set(logging.RequestIDHeader, requestID)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "AI service call failed", "error", err)
 		return nil, fmt.Errorf("failed to call AI service: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("❌ Failed to read AI service response: %v", err)
+		s.logger.ErrorContext(ctx, "failed to read AI service response", "error", err)
 		return nil, fmt.Errorf("failed to read AI service response: %w", err)
 	}
 
-	log.Printf("📥 Received response from AI service (status: %d): %s", resp.StatusCode, string(body))
+	s.logger.DebugContext(ctx, "AI service responded",
+		"status", resp.StatusCode,
+		logging.Payload("response", string(body)))
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("❌ AI service returned error status %d: %s", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("AI service error (status %d): %s", resp.StatusCode, string(body))
+		s.logger.ErrorContext(ctx, "AI service returned an error status", "status", resp.StatusCode)
+		return nil, &AIServiceStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var aiResponse AIServiceResponse
 	if err := json.Unmarshal(body, &aiResponse); err != nil {
-		log.Printf("❌ Failed to unmarshal AI service response: %v", err)
+		s.logger.ErrorContext(ctx, "failed to unmarshal AI service response", "error", err)
 		return nil, fmt.Errorf("failed to unmarshal AI service response: %w", err)
 	}
+	aiResponse.Source = SourceAIService
 
-	log.Printf("✅ Successfully processed AI service response - Anomaly: %v, Type: %s",
-		aiResponse.IsAnomaly, aiResponse.AnomalyType)
+	s.logger.InfoContext(ctx, "AI analysis received",
+		"is_anomaly", aiResponse.IsAnomaly,
+		"anomaly_type", aiResponse.AnomalyType,
+		"model_version", aiResponse.ModelVersion)
 
 	return &aiResponse, nil
 }
 
 // CheckHealth checks if the AI service is healthy
 // This method verifies the AI service is available for communication
-func (s *AIService) CheckHealth() error {
-	log.Printf("🏥 Checking AI service health at %s/health", s.baseURL)
+func (s *AIService) CheckHealth(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/health", nil)
+	if err != nil {
+		return fmt.Errorf("failed to build AI service health request: %w", err)
+	}
 
-	resp, err := s.httpClient.Get(s.baseURL + "/health")
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		log.Printf("❌ AI service health check failed: %v", err)
+		s.logger.WarnContext(ctx, "AI service health check failed", "error", err)
 		return fmt.Errorf("failed to check AI service health: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("❌ AI service health check failed with status %d", resp.StatusCode)
+		s.logger.WarnContext(ctx, "AI service health check failed", "status", resp.StatusCode)
 		return fmt.Errorf("AI service health check failed with status %d", resp.StatusCode)
 	}
 
-	log.Printf("✅ AI service is healthy")
+	s.logger.DebugContext(ctx, "AI service is healthy")
 	return nil
 }
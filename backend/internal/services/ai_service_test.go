@@ -1,17 +1,21 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
 )
 
 func TestNewAIService(t *testing.T) {
-	aiService := NewAIService("http://localhost:8000")
+	aiService := NewAIService("http://localhost:8000", DefaultAIServiceTimeout, logging.Discard())
 	if aiService == nil {
 		t.Fatal("Expected AI service to be created")
 	}
@@ -39,12 +43,17 @@ func TestAnalyzeRoutine(t *testing.T) {
 		}
 
 		// Mock response
+		accuracy := 0.923
 		response := AIServiceResponse{
 			IsAnomaly:       true,
 			ConfidenceScore: 0.85,
 			AnomalyType:     "high_screen_time",
 			Recommendations: []string{"Reduce screen time", "Take more breaks"},
 			Timestamp:       time.Now().Format(time.RFC3339),
+			ModelName:       "routine_random_forest",
+			ModelVersion:    "1.1.0",
+			ModelAccuracy:   &accuracy,
+			FeatureNames:    []string{"sleep_hours", "screen_time"},
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -54,11 +63,11 @@ func TestAnalyzeRoutine(t *testing.T) {
 	defer server.Close()
 
 	// Create AI service with mock server URL
-	aiService := NewAIService(server.URL)
+	aiService := NewAIService(server.URL, DefaultAIServiceTimeout, logging.Discard())
 
 	// Create test routine log
 	routineLog := database.RoutineLog{
-		UserID:           1,
+		UserID:           "user_1",
 		SleepHours:       6.0,
 		MealTimes:        []string{"07:00", "12:30", "18:00"},
 		ScreenTime:       8.0,
@@ -71,7 +80,7 @@ func TestAnalyzeRoutine(t *testing.T) {
 	}
 
 	// Test analysis
-	response, err := aiService.AnalyzeRoutine(routineLog)
+	response, err := aiService.AnalyzeRoutine(context.Background(), routineLog)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -95,6 +104,15 @@ func TestAnalyzeRoutine(t *testing.T) {
 	if len(response.Recommendations) != 2 {
 		t.Fatalf("Expected 2 recommendations, got %d", len(response.Recommendations))
 	}
+
+	if response.Source != SourceAIService {
+		t.Fatalf("Expected source %s, got %s", SourceAIService, response.Source)
+	}
+
+	if response.ModelName != "routine_random_forest" || response.ModelVersion != "1.1.0" ||
+		response.ModelAccuracy == nil || *response.ModelAccuracy != 0.923 || len(response.FeatureNames) != 2 {
+		t.Fatalf("Expected the model provenance to be decoded, got %+v", response)
+	}
 }
 
 func TestAnalyzeRoutineServerError(t *testing.T) {
@@ -105,10 +123,10 @@ func TestAnalyzeRoutineServerError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	aiService := NewAIService(server.URL)
+	aiService := NewAIService(server.URL, DefaultAIServiceTimeout, logging.Discard())
 
 	routineLog := database.RoutineLog{
-		UserID:           1,
+		UserID:           "user_1",
 		SleepHours:       8.0,
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -120,7 +138,7 @@ func TestAnalyzeRoutineServerError(t *testing.T) {
 		LogDate:          "2024-01-15",
 	}
 
-	_, err := aiService.AnalyzeRoutine(routineLog)
+	_, err := aiService.AnalyzeRoutine(context.Background(), routineLog)
 	if err == nil {
 		t.Fatal("Expected error for server error")
 	}
@@ -135,10 +153,10 @@ func TestAnalyzeRoutineInvalidJSON(t *testing.T) {
 	}))
 	defer server.Close()
 
-	aiService := NewAIService(server.URL)
+	aiService := NewAIService(server.URL, DefaultAIServiceTimeout, logging.Discard())
 
 	routineLog := database.RoutineLog{
-		UserID:           1,
+		UserID:           "user_1",
 		SleepHours:       8.0,
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -150,7 +168,7 @@ func TestAnalyzeRoutineInvalidJSON(t *testing.T) {
 		LogDate:          "2024-01-15",
 	}
 
-	_, err := aiService.AnalyzeRoutine(routineLog)
+	_, err := aiService.AnalyzeRoutine(context.Background(), routineLog)
 	if err == nil {
 		t.Fatal("Expected error for invalid JSON")
 	}
@@ -158,10 +176,10 @@ func TestAnalyzeRoutineInvalidJSON(t *testing.T) {
 
 func TestAnalyzeRoutineConnectionError(t *testing.T) {
 	// Create AI service with invalid URL
-	aiService := NewAIService("http://invalid-url:9999")
+	aiService := NewAIService("http://invalid-url:9999", DefaultAIServiceTimeout, logging.Discard())
 
 	routineLog := database.RoutineLog{
-		UserID:           1,
+		UserID:           "user_1",
 		SleepHours:       8.0,
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -173,12 +191,33 @@ func TestAnalyzeRoutineConnectionError(t *testing.T) {
 		LogDate:          "2024-01-15",
 	}
 
-	_, err := aiService.AnalyzeRoutine(routineLog)
+	_, err := aiService.AnalyzeRoutine(context.Background(), routineLog)
 	if err == nil {
 		t.Fatal("Expected error for connection failure")
 	}
 }
 
+func TestAnalyzeRoutineForwardsRequestID(t *testing.T) {
+	var gotID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = r.Header.Get("X-Request-ID")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AIServiceResponse{AnomalyType: "normal"})
+	}))
+	defer server.Close()
+
+	aiService := NewAIService(server.URL, DefaultAIServiceTimeout, logging.Discard())
+
+	ctx := logging.WithRequestID(context.Background(), "req-123")
+	if _, err := aiService.AnalyzeRoutine(ctx, database.RoutineLog{UserID: "user_1"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if gotID != "req-123" {
+		t.Fatalf("Expected X-Request-ID req-123, got %q", gotID)
+	}
+}
+
 func TestCheckHealth(t *testing.T) {
 	// Create mock server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -195,9 +234,9 @@ func TestCheckHealth(t *testing.T) {
 	}))
 	defer server.Close()
 
-	aiService := NewAIService(server.URL)
+	aiService := NewAIService(server.URL, DefaultAIServiceTimeout, logging.Discard())
 
-	err := aiService.CheckHealth()
+	err := aiService.CheckHealth(context.Background())
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -211,9 +250,9 @@ func TestCheckHealthUnhealthy(t *testing.T) {
 	}))
 	defer server.Close()
 
-	aiService := NewAIService(server.URL)
+	aiService := NewAIService(server.URL, DefaultAIServiceTimeout, logging.Discard())
 
-	err := aiService.CheckHealth()
+	err := aiService.CheckHealth(context.Background())
 	if err == nil {
 		t.Fatal("Expected error for unhealthy service")
 	}
@@ -221,10 +260,69 @@ func TestCheckHealthUnhealthy(t *testing.T) {
 
 func TestCheckHealthConnectionError(t *testing.T) {
 	// Create AI service with invalid URL
-	aiService := NewAIService("http://invalid-url:9999")
+	aiService := NewAIService("http://invalid-url:9999", DefaultAIServiceTimeout, logging.Discard())
 
-	err := aiService.CheckHealth()
+	err := aiService.CheckHealth(context.Background())
 	if err == nil {
 		t.Fatal("Expected error for connection failure")
 	}
 }
+
+// newBlockingAIServer returns a server that holds every request until the client goes away
+// and reports the cancellation on the returned channel
+func newBlockingAIServer() (*httptest.Server, <-chan struct{}) {
+	aborted := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices a closed connection once the body has been read
+		io.ReadAll(r.Body)
+
+		select {
+		case <-r.Context().Done():
+			aborted <- struct{}{}
+		case <-time.After(10 * time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	return server, aborted
+}
+
+func TestAnalyzeRoutineCancelledContext(t *testing.T) {
+	server, aborted := newBlockingAIServer()
+	defer server.Close()
+
+	aiService := NewAIService(server.URL, DefaultAIServiceTimeout, logging.Discard())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := aiService.AnalyzeRoutine(ctx, database.RoutineLog{UserID: "user_1"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Expected the call to stop right after cancellation, took %s", elapsed)
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the AI service to see the request aborted")
+	}
+}
+
+func TestAnalyzeRoutineDeadline(t *testing.T) {
+	server, _ := newBlockingAIServer()
+	defer server.Close()
+
+	aiService := NewAIService(server.URL, DefaultAIServiceTimeout, logging.Discard())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := aiService.AnalyzeRoutine(ctx, database.RoutineLog{UserID: "user_1"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
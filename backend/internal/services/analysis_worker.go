@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"lifepattern-api/internal/database"
+)
+
+// AnalysisWorkerConfig tunes the background analysis worker pool
+type AnalysisWorkerConfig struct {
+	// Workers is the number of goroutines processing jobs
+	Workers int
+	// PollInterval is how long an idle worker waits before checking the queue again
+	PollInterval time.Duration
+	// BaseBackoff is the delay before the first retry; it doubles with every failed attempt
+	BaseBackoff time.Duration
+	// MaxBackoff caps the retry delay
+	MaxBackoff time.Duration
+	// SweepInterval is how often logs without an AI report are queued
+	SweepInterval time.Duration
+	// MaxAttempts is the number of attempts after which a job is marked failed and no longer retried
+	MaxAttempts int
+}
+
+// Defaults for AnalysisWorkerConfig fields that are not set
+const (
+	defaultMaxAttempts   = 20
+	defaultPollInterval  = 5 * time.Second
+	defaultSweepInterval = 5 * time.Minute
+)
+
+// errPrimaryAnalyzerUnavailable is recorded on jobs whose analysis only came from a fallback analyzer
+var errPrimaryAnalyzerUnavailable = errors.New("primary analyzer unavailable")
+
+// analysisLease is how long a claimed job is hidden from other workers;
+// it must exceed the AI service timeout
+const analysisLease = 2 * time.Minute
+
+// sweepMinAge keeps the sweep away from logs whose synchronous analysis is still running
+const sweepMinAge = 2 * time.Minute
+
+// AnalysisWorker drains the pending analysis queue in the background
+// Logs saved while the AI service was unavailable are retried with exponential
+// backoff until every routine log has an AI report or their job runs out of attempts
+type AnalysisWorker struct {
+	repo      RepositoryInterface
+	aiService AIServiceInterface
+	config    AnalysisWorkerConfig
+	logger    *slog.Logger
+	metrics   *Metrics
+
+	// ctx is cancelled when Stop gives up waiting, aborting in-flight AI calls and queries
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewAnalysisWorker(repo RepositoryInterface, aiService AIServiceInterface, config AnalysisWorkerConfig, logger *slog.Logger, metrics *Metrics) *AnalysisWorker {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.SweepInterval <= 0 {
+		config.SweepInterval = defaultSweepInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &AnalysisWorker{
+		repo:      repo,
+		aiService: aiService,
+		config:    config,
+		logger:    logger,
+		metrics:   metrics,
+		ctx:       ctx,
+		cancel:    cancel,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start launches the worker goroutines and the unanalyzed-log sweep
+func (w *AnalysisWorker) Start() {
+	w.logger.Info("starting analysis workers", "workers", w.config.Workers)
+
+	for i := 0; i < w.config.Workers; i++ {
+		w.wg.Add(1)
+		go w.run()
+	}
+
+	w.wg.Add(1)
+	go w.sweep()
+}
+
+// Stop stops claiming new jobs and waits for in-flight jobs to finish
+// If ctx is done first, in-flight jobs are cancelled and ctx.Err() is returned;
+// unfinished jobs are retried after their lease expires
+func (w *AnalysisWorker) Stop(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		w.logger.Info("analysis workers stopped")
+		return nil
+	case <-ctx.Done():
+		w.logger.Warn("analysis workers did not stop in time", "error", ctx.Err())
+		w.cancel()
+		return ctx.Err()
+	}
+}
+
+// QueueStats reports the depth of the pending analysis queue and the worker's attempt limit
+func (w *AnalysisWorker) QueueStats(ctx context.Context) (*database.AnalysisQueueStats, error) {
+	return w.withAttemptLimit(w.repo.GetAnalysisQueueStats(ctx))
+}
+
+// UserQueueStats is QueueStats limited to the analyses of userID's logs
+func (w *AnalysisWorker) UserQueueStats(ctx context.Context, userID string) (*database.AnalysisQueueStats, error) {
+	return w.withAttemptLimit(w.repo.GetUserAnalysisQueueStats(ctx, userID))
+}
+
+func (w *AnalysisWorker) withAttemptLimit(stats *database.AnalysisQueueStats, err error) (*database.AnalysisQueueStats, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	stats.AttemptLimit = w.config.MaxAttempts
+	return stats, nil
+}
+
+// run processes jobs until Stop is called, sleeping while the queue is empty
+func (w *AnalysisWorker) run() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		if w.processNext() {
+			continue
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(w.config.PollInterval):
+		}
+	}
+}
+
+// sweep periodically queues logs that have no AI report and no job,
+// e.g. because enqueueing failed while the database was briefly unavailable
+func (w *AnalysisWorker) sweep() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		queued, err := w.repo.EnqueueUnanalyzedLogs(w.ctx, sweepMinAge)
+		if err != nil {
+			w.logger.Warn("failed to queue unanalyzed logs", "error", err)
+		} else if queued > 0 {
+			w.logger.Info("queued unanalyzed routine logs", "count", queued)
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processNext claims and processes one job; it reports whether a job was found
+func (w *AnalysisWorker) processNext() bool {
+	jobs, err := w.repo.ClaimAnalysisJobs(w.ctx, 1, analysisLease)
+	if err != nil {
+		w.logger.Warn("failed to claim analysis job", "error", err)
+		return false
+	}
+	if len(jobs) == 0 {
+		return false
+	}
+
+	job := jobs[0]
+	if err := w.analyze(job.RoutineLogID); err != nil {
+		if job.Attempts >= w.config.MaxAttempts {
+			w.logger.Error("queued analysis failed, giving up",
+				"log_id", job.RoutineLogID,
+				"attempts", job.Attempts,
+				"error", err)
+
+			if err := w.repo.FailAnalysisJob(w.ctx, job.ID, job.Version, err.Error()); err != nil {
+				w.logger.Error("failed to mark analysis job failed", "job_id", job.ID, "error", err)
+			}
+			return true
+		}
+
+		delay := w.backoff(job.Attempts)
+		w.logger.Warn("queued analysis failed",
+			"log_id", job.RoutineLogID,
+			"attempt", job.Attempts,
+			"retry_in", delay,
+			"error", err)
+
+		if err := w.repo.RetryAnalysisJob(w.ctx, job.ID, delay, err.Error()); err != nil {
+			w.logger.Error("failed to reschedule analysis job", "job_id", job.ID, "error", err)
+		}
+		return true
+	}
+
+	completed, err := w.repo.CompleteAnalysisJob(w.ctx, job.ID, job.Version)
+	if err != nil {
+		w.logger.Error("failed to complete analysis job", "job_id", job.ID, "error", err)
+	} else if !completed {
+		w.logger.Info("log queued again during analysis, keeping its job", "log_id", job.RoutineLogID)
+	}
+	return true
+}
+
+// analyze runs AI analysis for a queued log and stores the report
+func (w *AnalysisWorker) analyze(logID int) error {
+	routineLog, err := w.repo.GetRoutineLog(w.ctx, logID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			// The log was deleted while queued; there is nothing left to analyze
+			return nil
+		}
+		return err
+	}
+
+	aiResponse, err := w.aiService.AnalyzeRoutine(w.ctx, *routineLog)
+	if err != nil {
+		return err
+	}
+	w.metrics.observeAnalysis(aiResponse)
+	if aiResponse.Fallback {
+		// The log already has a fallback analysis from its request; keep waiting for the primary analyzer
+		return errPrimaryAnalyzerUnavailable
+	}
+
+	// The AI call is slow; a log updated meanwhile already has a newer report or a newer job
+	saved, err := w.repo.ReplaceAIReportIfUnchanged(w.ctx, newAIReport(logID, aiResponse), routineLog.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		w.logger.Info("routine log changed during analysis, dropping report", "log_id", logID)
+		return nil
+	}
+
+	w.logger.Info("queued analysis completed", "log_id", logID, "is_anomaly", aiResponse.IsAnomaly)
+	return nil
+}
+
+// backoff returns the retry delay after the given number of attempts:
+// BaseBackoff, 2x, 4x, ... capped at MaxBackoff
+func (w *AnalysisWorker) backoff(attempts int) time.Duration {
+	delay := w.config.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= w.config.MaxBackoff {
+			return w.config.MaxBackoff
+		}
+	}
+
+	if delay > w.config.MaxBackoff {
+		return w.config.MaxBackoff
+	}
+	return delay
+}
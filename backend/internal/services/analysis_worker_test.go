@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"lifepattern-api/internal/config"
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
+)
+
+// notifyingAIService signals every analysis request on a channel
+type notifyingAIService struct {
+	*MockAIService
+	calls chan int
+}
+
+func (m *notifyingAIService) AnalyzeRoutine(ctx context.Context, routineLog database.RoutineLog) (*AIServiceResponse, error) {
+	defer func() { m.calls <- routineLog.ID }()
+	return m.MockAIService.AnalyzeRoutine(ctx, routineLog)
+}
+
+// enqueuingAIService queues the log again while it is being analyzed, like an update
+// arriving while the worker runs
+type enqueuingAIService struct {
+	*MockAIService
+	repo *MockRepository
+	once sync.Once
+}
+
+func (m *enqueuingAIService) AnalyzeRoutine(ctx context.Context, routineLog database.RoutineLog) (*AIServiceResponse, error) {
+	m.once.Do(func() { m.repo.EnqueueAnalysis(ctx, routineLog.ID) })
+	return m.MockAIService.AnalyzeRoutine(ctx, routineLog)
+}
+
+// updatingAIService updates the log and stores the report of its new data while the log is
+// being analyzed, like an update whose synchronous analysis finishes before the worker's
+type updatingAIService struct {
+	*MockAIService
+	repo *MockRepository
+	once sync.Once
+}
+
+func (m *updatingAIService) AnalyzeRoutine(ctx context.Context, routineLog database.RoutineLog) (*AIServiceResponse, error) {
+	m.once.Do(func() {
+		updated := routineLog
+		updated.SleepHours = 4
+		m.repo.UpdateRoutineLog(ctx, updated)
+		m.repo.ReplaceAIReport(ctx, database.AIReport{RoutineLogID: routineLog.ID, AnomalyType: "fresh"})
+	})
+	return m.MockAIService.AnalyzeRoutine(ctx, routineLog)
+}
+
+func newTestWorker(repo RepositoryInterface, aiService AIServiceInterface) *AnalysisWorker {
+	return NewAnalysisWorker(repo, aiService, AnalysisWorkerConfig{
+		Workers:       1,
+		PollInterval:  10 * time.Millisecond,
+		BaseBackoff:   time.Minute,
+		MaxBackoff:    time.Hour,
+		SweepInterval: time.Hour,
+	}, logging.Discard(), nil)
+}
+
+func TestAnalysisWorkerRetriesFailedAnalysis(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockAI := NewMockAIService(true)
+	worker := newTestWorker(mockRepo, mockAI)
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-15"}, false)
+	mockRepo.EnqueueAnalysis(context.Background(), logID)
+
+	// The AI service is down: the job stays queued and is pushed back
+	if !worker.processNext() {
+		t.Fatal("Expected a job to be processed")
+	}
+
+	queued := mockRepo.jobs[1]
+	if queued == nil || queued.job.Attempts != 1 || queued.job.LastError == "" {
+		t.Fatalf("Expected job to be retried after one failed attempt, got %+v", queued)
+	}
+
+	if queued.runAt.Before(time.Now().Add(59 * time.Second)) {
+		t.Fatalf("Expected first retry after the base backoff, got %s", queued.runAt)
+	}
+
+	if worker.processNext() {
+		t.Fatal("Expected no job to be due before its backoff expires")
+	}
+
+	// The AI service recovers and the job becomes due
+	mockAI.shouldFail = false
+	queued.runAt = time.Now()
+
+	if !worker.processNext() {
+		t.Fatal("Expected a job to be processed")
+	}
+
+	if _, exists := mockRepo.aiReports[logID]; !exists {
+		t.Fatal("Expected AI report to be saved")
+	}
+
+	if len(mockRepo.jobs) != 0 {
+		t.Fatalf("Expected queue to be empty, got %d jobs", len(mockRepo.jobs))
+	}
+}
+
+func TestAnalysisWorkerKeepsRetryingFallbackAnalyses(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockAI := NewMockAIService(true)
+	worker := newTestWorker(mockRepo, newTestAnalyzerChain(t, config.DefaultAnalyzers, mockAI, mockRepo, nil))
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-15"}, false)
+	mockRepo.EnqueueAnalysis(context.Background(), logID)
+
+	// Local rules answer, but the job waits for the AI service
+	if !worker.processNext() {
+		t.Fatal("Expected a job to be processed")
+	}
+
+	if _, exists := mockRepo.aiReports[logID]; exists {
+		t.Fatal("Expected no report from a fallback analyzer")
+	}
+
+	queued := mockRepo.jobs[1]
+	if queued == nil || queued.job.LastError != errPrimaryAnalyzerUnavailable.Error() {
+		t.Fatalf("Expected the job to be retried, got %+v", queued)
+	}
+}
+
+func TestAnalysisWorkerDefaultsNonPositiveIntervals(t *testing.T) {
+	worker := NewAnalysisWorker(NewMockRepository(), NewMockAIService(true), AnalysisWorkerConfig{
+		PollInterval:  -time.Second,
+		SweepInterval: 0,
+	}, logging.Discard(), nil)
+
+	if worker.config.PollInterval != defaultPollInterval || worker.config.SweepInterval != defaultSweepInterval {
+		t.Fatalf("Expected the default intervals, got %s/%s", worker.config.PollInterval, worker.config.SweepInterval)
+	}
+
+	// The sweep ticker must not panic
+	worker.Start()
+	if err := worker.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected the worker to stop, got %v", err)
+	}
+}
+
+func TestAnalysisWorkerGivesUpAfterMaxAttempts(t *testing.T) {
+	mockRepo := NewMockRepository()
+	worker := NewAnalysisWorker(mockRepo, NewMockAIService(true), AnalysisWorkerConfig{
+		BaseBackoff: time.Minute,
+		MaxBackoff:  time.Hour,
+		MaxAttempts: 2,
+	}, logging.Discard(), nil)
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-15"}, false)
+	mockRepo.EnqueueAnalysis(context.Background(), logID)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		mockRepo.jobs[1].runAt = time.Now()
+		if !worker.processNext() {
+			t.Fatalf("Expected attempt %d to be processed", attempt)
+		}
+	}
+
+	queued := mockRepo.jobs[1]
+	if queued == nil || !queued.failed || queued.job.LastError == "" {
+		t.Fatalf("Expected the job to be kept as failed after 2 attempts, got %+v", queued)
+	}
+
+	// A failed job is not retried, even once it is due
+	queued.runAt = time.Now()
+	if worker.processNext() {
+		t.Fatal("Expected a failed job not to be claimed")
+	}
+
+	stats, err := worker.QueueStats(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stats.Depth != 0 || stats.Failed != 1 || stats.AttemptLimit != 2 {
+		t.Fatalf("Expected 1 failed job and no pending ones, got %+v", stats)
+	}
+
+	// Other users don't see the job
+	stats, err = worker.UserQueueStats(context.Background(), "user_2")
+	if err != nil || stats.Failed != 0 || stats.AttemptLimit != 2 {
+		t.Fatalf("Expected no jobs for another user, got %+v (%v)", stats, err)
+	}
+
+	// Queueing the log again, e.g. after an update, revives the job
+	mockRepo.EnqueueAnalysis(context.Background(), logID)
+	if queued.failed || queued.job.Attempts != 0 {
+		t.Fatalf("Expected the job to be pending again, got %+v", queued)
+	}
+}
+
+func TestAnalysisWorkerKeepsJobQueuedDuringAnalysis(t *testing.T) {
+	mockRepo := NewMockRepository()
+	worker := newTestWorker(mockRepo, &enqueuingAIService{MockAIService: NewMockAIService(false), repo: mockRepo})
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-15"}, false)
+	mockRepo.EnqueueAnalysis(context.Background(), logID)
+
+	if !worker.processNext() {
+		t.Fatal("Expected a job to be processed")
+	}
+
+	// The analysis of the old data must not complete the job queued for the new data
+	queued := mockRepo.jobs[1]
+	if queued == nil || queued.locked || queued.job.Version != 2 {
+		t.Fatalf("Expected the re-queued job to be kept and released, got %+v", queued)
+	}
+
+	if !worker.processNext() {
+		t.Fatal("Expected the re-queued job to be processed")
+	}
+
+	if len(mockRepo.jobs) != 0 {
+		t.Fatalf("Expected queue to be empty, got %d jobs", len(mockRepo.jobs))
+	}
+}
+
+func TestAnalysisWorkerDropsReportOfChangedLog(t *testing.T) {
+	mockRepo := NewMockRepository()
+	worker := newTestWorker(mockRepo, &updatingAIService{MockAIService: NewMockAIService(false), repo: mockRepo})
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-15"}, false)
+	mockRepo.EnqueueAnalysis(context.Background(), logID)
+
+	if !worker.processNext() {
+		t.Fatal("Expected a job to be processed")
+	}
+
+	// The report of the old data must not overwrite the report of the new data
+	if report := mockRepo.aiReports[logID]; report.AnomalyType != "fresh" {
+		t.Fatalf("Expected the newer report to be kept, got %+v", report)
+	}
+}
+
+func TestAnalysisWorkerDropsDeletedLogs(t *testing.T) {
+	mockRepo := NewMockRepository()
+	worker := newTestWorker(mockRepo, NewMockAIService(false))
+
+	mockRepo.EnqueueAnalysis(context.Background(), 42)
+
+	if !worker.processNext() {
+		t.Fatal("Expected a job to be processed")
+	}
+
+	if len(mockRepo.jobs) != 0 {
+		t.Fatalf("Expected job for a deleted log to be completed, got %d jobs", len(mockRepo.jobs))
+	}
+}
+
+func TestAnalysisWorkerBackoff(t *testing.T) {
+	worker := NewAnalysisWorker(NewMockRepository(), NewMockAIService(false), AnalysisWorkerConfig{
+		BaseBackoff: time.Second,
+		MaxBackoff:  10 * time.Second,
+	}, logging.Discard(), nil)
+
+	expected := map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		4:  8 * time.Second,
+		5:  10 * time.Second,
+		60: 10 * time.Second,
+	}
+
+	for attempts, want := range expected {
+		if got := worker.backoff(attempts); got != want {
+			t.Fatalf("Expected backoff %s after %d attempts, got %s", want, attempts, got)
+		}
+	}
+}
+
+func TestAnalysisWorkerSweepsAndStops(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockAI := &notifyingAIService{MockAIService: NewMockAIService(false), calls: make(chan int, 1)}
+	worker := newTestWorker(mockRepo, mockAI)
+
+	// A log saved without a report and without a job is found by the sweep
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-15"}, false)
+
+	worker.Start()
+
+	select {
+	case analyzed := <-mockAI.calls:
+		if analyzed != logID {
+			t.Fatalf("Expected log %d to be analyzed, got %d", logID, analyzed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected unanalyzed log to be picked up by the worker")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := worker.Stop(ctx); err != nil {
+		t.Fatalf("Expected worker to stop cleanly, got %v", err)
+	}
+
+	if _, exists := mockRepo.aiReports[logID]; !exists {
+		t.Fatal("Expected AI report to be saved before the worker stopped")
+	}
+}
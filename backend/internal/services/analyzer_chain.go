@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"lifepattern-api/internal/database"
+)
+
+// AnalyzerRegistry names the analyzers an analyzer chain can be built from
+type AnalyzerRegistry struct {
+	analyzers map[string]AIServiceInterface
+}
+
+func NewAnalyzerRegistry() *AnalyzerRegistry {
+	return &AnalyzerRegistry{
+		analyzers: make(map[string]AIServiceInterface),
+	}
+}
+
+// Register adds analyzer under name; names must be unique and free of chain separators
+func (r *AnalyzerRegistry) Register(name string, analyzer AIServiceInterface) error {
+	if name == "" || strings.ContainsAny(name, ",| ") {
+		return fmt.Errorf("invalid analyzer name %q", name)
+	}
+	if _, exists := r.analyzers[name]; exists {
+		return fmt.Errorf("analyzer %q is already registered", name)
+	}
+	r.analyzers[name] = analyzer
+	return nil
+}
+
+// Chain builds the analyzer chain described by spec
+// Groups separated by "," run in parallel; within a group, analyzers separated by "|"
+// are tried in order until one succeeds. The first group decides the verdict:
+// "ai_service|local_rules,candidate" keeps the AI service in charge and runs candidate
+// next to it, so a new model can be compared with the old one on live traffic
+func (r *AnalyzerRegistry) Chain(spec string, logger *slog.Logger) (*AnalyzerChain, error) {
+	var groups [][]namedAnalyzer
+	seen := make(map[string]bool)
+
+	for _, group := range strings.Split(spec, ",") {
+		var analyzers []namedAnalyzer
+		for _, name := range strings.Split(group, "|") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return nil, fmt.Errorf("invalid analyzer chain %q: empty analyzer name", spec)
+			}
+			analyzer, ok := r.analyzers[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid analyzer chain %q: unknown analyzer %q", spec, name)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("invalid analyzer chain %q: analyzer %q is used twice", spec, name)
+			}
+			seen[name] = true
+			analyzers = append(analyzers, namedAnalyzer{name: name, analyzer: analyzer})
+		}
+		groups = append(groups, analyzers)
+	}
+
+	return &AnalyzerChain{
+		groups: groups,
+		logger: logger,
+	}, nil
+}
+
+type namedAnalyzer struct {
+	name     string
+	analyzer AIServiceInterface
+}
+
+// AnalyzerChain runs several analyzers on every log and merges their answers into one response
+// The verdict comes from the first group that succeeds; every successful analyzer is listed
+// in the response's Analyses so each one's answer can be attributed and compared
+type AnalyzerChain struct {
+	groups [][]namedAnalyzer
+	logger *slog.Logger
+}
+
+// groupResult is the outcome of one group of the chain
+type groupResult struct {
+	name     string
+	response *AIServiceResponse
+	// fallback is set when an analyzer other than the group's first one answered
+	fallback bool
+	err      error
+}
+
+// AnalyzeRoutine runs every group in parallel and merges the results
+// It fails only if every group fails
+func (c *AnalyzerChain) AnalyzeRoutine(ctx context.Context, routineLog database.RoutineLog) (*AIServiceResponse, error) {
+	results := make([]groupResult, len(c.groups))
+
+	var wg sync.WaitGroup
+	for i, group := range c.groups {
+		wg.Add(1)
+		go func(i int, group []namedAnalyzer) {
+			defer wg.Done()
+			results[i] = c.analyzeGroup(ctx, group, routineLog)
+		}(i, group)
+	}
+	wg.Wait()
+
+	return c.merge(results)
+}
+
+// analyzeGroup tries the analyzers of group in order until one succeeds
+func (c *AnalyzerChain) analyzeGroup(ctx context.Context, group []namedAnalyzer, routineLog database.RoutineLog) groupResult {
+	var errs []error
+	for j, named := range group {
+		response, err := named.analyzer.AnalyzeRoutine(ctx, routineLog)
+		if err == nil {
+			if j > 0 {
+				c.logger.WarnContext(ctx, "analyzer failed, using fallback analyzer",
+					"analyzer", group[0].name,
+					"fallback", named.name,
+					"error", errs[0])
+			}
+			return groupResult{name: named.name, response: response, fallback: j > 0}
+		}
+
+		if errors.Is(err, errInsufficientHistory) {
+			c.logger.DebugContext(ctx, "analyzer skipped", "analyzer", named.name, "reason", err)
+		} else if j == len(group)-1 {
+			c.logger.WarnContext(ctx, "analyzer failed", "analyzer", named.name, "error", err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", named.name, err))
+	}
+	return groupResult{name: group[0].name, err: errors.Join(errs...)}
+}
+
+// merge combines the group results into one response led by the first successful group
+func (c *AnalyzerChain) merge(results []groupResult) (*AIServiceResponse, error) {
+	var merged *AIServiceResponse
+	var errs []error
+
+	for _, result := range results {
+		if result.err != nil {
+			errs = append(errs, result.err)
+			continue
+		}
+
+		response := result.response
+		if merged == nil {
+			merged = &AIServiceResponse{
+				IsAnomaly:       response.IsAnomaly,
+				ConfidenceScore: response.ConfidenceScore,
+				AnomalyType:     response.AnomalyType,
+				Recommendations: response.Recommendations,
+				Timestamp:       response.Timestamp,
+				ModelName:       response.ModelName,
+				ModelVersion:    response.ModelVersion,
+				ModelAccuracy:   response.ModelAccuracy,
+				FeatureNames:    response.FeatureNames,
+				Source:          result.name,
+				// Results after the first group only add to the report; a degraded first group
+				// means the report is replaced once its primary analyzer is back
+				Fallback: results[0].err != nil || results[0].fallback,
+			}
+		}
+		if merged.Baseline == nil {
+			merged.Baseline = response.Baseline
+		}
+		merged.Analyses = append(merged.Analyses, database.AnalyzerResult{
+			Analyzer:        result.name,
+			IsAnomaly:       response.IsAnomaly,
+			ConfidenceScore: response.ConfidenceScore,
+			AnomalyType:     response.AnomalyType,
+			Recommendations: response.Recommendations,
+			ModelName:       response.ModelName,
+			ModelVersion:    response.ModelVersion,
+			Fallback:        result.fallback,
+		})
+	}
+
+	if merged == nil {
+		return nil, errors.Join(errs...)
+	}
+	return merged, nil
+}
+
+// CheckHealth checks the primary analyzer of the first group, which decides the verdict
+func (c *AnalyzerChain) CheckHealth(ctx context.Context) error {
+	return c.groups[0][0].analyzer.CheckHealth(ctx)
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"lifepattern-api/internal/config"
+	"lifepattern-api/internal/logging"
+)
+
+// newTestAnalyzerChain builds spec from an AI service, local rules, the baseline and any extra analyzers
+func newTestAnalyzerChain(t *testing.T, spec string, aiService AIServiceInterface, repo *MockRepository, extra map[string]AIServiceInterface) *AnalyzerChain {
+	t.Helper()
+
+	registry := NewAnalyzerRegistry()
+	registry.Register(SourceAIService, aiService)
+	registry.Register(SourceLocalRules, NewLocalRulesAnalyzer())
+	registry.Register(SourceBaseline, NewBaselineDetector(repo, testBaselineConfig))
+	for name, analyzer := range extra {
+		if err := registry.Register(name, analyzer); err != nil {
+			t.Fatalf("Expected %s to be registered, got %v", name, err)
+		}
+	}
+
+	chain, err := registry.Chain(spec, logging.Discard())
+	if err != nil {
+		t.Fatalf("Expected a valid analyzer chain, got %v", err)
+	}
+	return chain
+}
+
+func TestAnalyzerRegistryRegister(t *testing.T) {
+	registry := NewAnalyzerRegistry()
+
+	if err := registry.Register(SourceLocalRules, NewLocalRulesAnalyzer()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, name := range []string{"", "a,b", "a|b", SourceLocalRules} {
+		if err := registry.Register(name, NewLocalRulesAnalyzer()); err == nil {
+			t.Fatalf("Expected analyzer name %q to be rejected", name)
+		}
+	}
+}
+
+func TestAnalyzerRegistryChainErrors(t *testing.T) {
+	registry := NewAnalyzerRegistry()
+	registry.Register(SourceAIService, NewMockAIService(false))
+	registry.Register(SourceLocalRules, NewLocalRulesAnalyzer())
+
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"ai_service|model_v2", `unknown analyzer "model_v2"`},
+		{"ai_service,ai_service", `analyzer "ai_service" is used twice`},
+		{"ai_service|", "empty analyzer name"},
+		{"", "empty analyzer name"},
+	}
+
+	for _, tt := range tests {
+		_, err := registry.Chain(tt.spec, logging.Discard())
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Expected chain %q to fail with %q, got %v", tt.spec, tt.want, err)
+		}
+	}
+
+	if _, err := registry.Chain(" ai_service | local_rules ", logging.Discard()); err != nil {
+		t.Fatalf("Expected spaces around names to be ignored, got %v", err)
+	}
+}
+
+func TestAnalyzerChainFallback(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockAI := NewMockAIService(false)
+	chain := newTestAnalyzerChain(t, config.DefaultAnalyzers, mockAI, mockRepo, nil)
+
+	response, err := chain.AnalyzeRoutine(context.Background(), healthyLog())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.Source != SourceAIService || response.Fallback || response.AnomalyType != "test_anomaly" {
+		t.Fatalf("Expected the AI service verdict, got %+v", response)
+	}
+
+	// Without history the baseline is skipped, leaving only the AI service analysis
+	if len(response.Analyses) != 1 || response.Analyses[0].Analyzer != SourceAIService || response.Baseline != nil {
+		t.Fatalf("Expected only the AI service analysis, got %+v", response.Analyses)
+	}
+
+	mockAI.shouldFail = true
+	response, err = chain.AnalyzeRoutine(context.Background(), healthyLog())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.Source != SourceLocalRules || !response.Fallback || !response.Analyses[0].Fallback {
+		t.Fatalf("Expected a fallback to local rules, got %+v", response)
+	}
+
+	// A healthy fallback does not mask an unhealthy primary analyzer
+	if err := chain.CheckHealth(context.Background()); err == nil {
+		t.Fatal("Expected the AI service health error")
+	}
+}
+
+func TestAnalyzerChainMergesParallelAnalyzers(t *testing.T) {
+	mockRepo := NewMockRepository()
+	saveNightOwlHistory(mockRepo, 14)
+
+	// model_v2 is a candidate model running next to the AI service
+	candidate := NewMockAIService(false)
+	candidate.response = &AIServiceResponse{
+		IsAnomaly:       false,
+		ConfidenceScore: 0.6,
+		AnomalyType:     "normal_routine",
+		Recommendations: []string{"Candidate recommendation"},
+		ModelName:       "routine_random_forest",
+		ModelVersion:    "2.0.0",
+	}
+	chain := newTestAnalyzerChain(t, "ai_service|local_rules,model_v2,baseline", NewMockAIService(false), mockRepo,
+		map[string]AIServiceInterface{"model_v2": candidate})
+
+	response, err := chain.AnalyzeRoutine(context.Background(), nightOwlLog("2024-02-01", 4.0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The candidate is attributed but does not change the verdict
+	if response.Source != SourceAIService || response.AnomalyType != "test_anomaly" ||
+		response.Recommendations[0] != "Test recommendation" {
+		t.Fatalf("Expected the AI service verdict, got %+v", response)
+	}
+
+	if len(response.Analyses) != 3 {
+		t.Fatalf("Expected 3 analyses, got %+v", response.Analyses)
+	}
+	for i, name := range []string{SourceAIService, "model_v2", SourceBaseline} {
+		if response.Analyses[i].Analyzer != name {
+			t.Fatalf("Expected analysis %d by %s, got %s", i, name, response.Analyses[i].Analyzer)
+		}
+	}
+	if response.Analyses[1].AnomalyType != "normal_routine" || response.Analyses[2].AnomalyType != "personal_deviation" {
+		t.Fatalf("Expected each analyzer's own answer, got %+v", response.Analyses)
+	}
+	if response.ModelVersion != "1.0.0" || response.Analyses[1].ModelVersion != "2.0.0" {
+		t.Fatalf("Expected the verdict from 1.0.0 and the candidate's answer from 2.0.0, got %q and %q",
+			response.ModelVersion, response.Analyses[1].ModelVersion)
+	}
+
+	if response.Baseline == nil || !response.Baseline.IsDeviation {
+		t.Fatalf("Expected the baseline deviation to be attached, got %+v", response.Baseline)
+	}
+}
+
+func TestAnalyzerChainLaterGroupLeadsWhenFirstFails(t *testing.T) {
+	mockRepo := NewMockRepository()
+	saveNightOwlHistory(mockRepo, 14)
+	chain := newTestAnalyzerChain(t, "ai_service,baseline", NewMockAIService(true), mockRepo, nil)
+
+	response, err := chain.AnalyzeRoutine(context.Background(), nightOwlLog("2024-02-01", 8.0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.Source != SourceBaseline || !response.Fallback {
+		t.Fatalf("Expected a fallback baseline verdict, got %+v", response)
+	}
+
+	// Every group failing fails the chain
+	if _, err := chain.AnalyzeRoutine(context.Background(), nightOwlLog("2024-03-15", 8.0)); err == nil {
+		t.Fatal("Expected an error when every analyzer fails")
+	}
+}
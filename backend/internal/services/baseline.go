@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/validation"
+)
+
+// BaselineConfig tunes the personal baseline detector
+type BaselineConfig struct {
+	// WindowDays is how many days before a log form the user's baseline
+	WindowDays int
+	// MinSamples is the fewest logs a metric needs in the window before it is compared
+	MinSamples int
+	// ZThreshold is the absolute z-score from which a metric counts as a personal deviation
+	ZThreshold float64
+}
+
+// Defaults for BaselineConfig fields that are not set
+const (
+	defaultBaselineWindowDays = 28
+	defaultBaselineMinSamples = 7
+	defaultBaselineZThreshold = 2.0
+)
+
+// errInsufficientHistory is returned by the baseline analyzer until the user has enough logs
+var errInsufficientHistory = errors.New("not enough history for a personal baseline")
+
+// baselineMetric is a routine log field compared against the user's baseline
+type baselineMetric struct {
+	name string
+	// label names the metric in recommendations
+	label string
+	// minStdDev keeps a near-constant history from turning tiny changes into huge z-scores
+	minStdDev float64
+	// value returns the field of a log, or false when the log has none
+	value func(database.RoutineLog) (float64, bool)
+}
+
+// baselineMetrics are the fields of a log compared against the user's history
+// The sleep midpoint is what sets a night owl apart from the population model,
+// so it is compared to the user's own usual midpoint
+var baselineMetrics = []baselineMetric{
+	{"sleep_hours", "sleep duration", 0.25, func(l database.RoutineLog) (float64, bool) { return l.SleepHours, true }},
+	{"screen_time", "screen time", 0.25, func(l database.RoutineLog) (float64, bool) { return l.ScreenTime, true }},
+	{"exercise_duration", "exercise", 0.1, func(l database.RoutineLog) (float64, bool) { return l.ExerciseDuration, true }},
+	{"water_intake", "water intake", 0.25, func(l database.RoutineLog) (float64, bool) { return l.WaterIntake, true }},
+	{"stress_level", "stress level", 0.5, func(l database.RoutineLog) (float64, bool) { return float64(l.StressLevel), true }},
+	{"sleep_midpoint_minutes", "sleep timing", 15, func(l database.RoutineLog) (float64, bool) {
+		if l.Sleep == nil {
+			return 0, false
+		}
+		return float64(l.Sleep.MidpointMinutes), true
+	}},
+}
+
+// BaselineDetector flags deviations of a routine log from the user's own recent routine
+// Every metric is compared with its mean and standard deviation over the WindowDays
+// before the log, so a habit the user keeps every day is never a deviation
+type BaselineDetector struct {
+	repo   RepositoryInterface
+	config BaselineConfig
+}
+
+func NewBaselineDetector(repo RepositoryInterface, config BaselineConfig) *BaselineDetector {
+	if config.WindowDays <= 0 {
+		config.WindowDays = defaultBaselineWindowDays
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = defaultBaselineMinSamples
+	}
+	if config.ZThreshold <= 0 {
+		config.ZThreshold = defaultBaselineZThreshold
+	}
+
+	return &BaselineDetector{
+		repo:   repo,
+		config: config,
+	}
+}
+
+// Evaluate compares routineLog with the user's logs in the window before its log_date
+// It returns nil when the user has fewer than MinSamples logs in the window
+func (d *BaselineDetector) Evaluate(ctx context.Context, routineLog database.RoutineLog) (*database.BaselineReport, error) {
+	logDate, err := time.Parse(validation.DateLayout, routineLog.LogDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log date %q: %w", routineLog.LogDate, err)
+	}
+
+	// There is at most one log per day, so the window never holds more than WindowDays logs
+	history, err := d.repo.GetRoutineLogsByUser(ctx, database.LogFilter{
+		UserID: routineLog.UserID,
+		From:   logDate.AddDate(0, 0, -d.config.WindowDays).Format(validation.DateLayout),
+		To:     logDate.AddDate(0, 0, -1).Format(validation.DateLayout),
+		Limit:  d.config.WindowDays,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load baseline history: %w", err)
+	}
+
+	return d.compare(routineLog, history), nil
+}
+
+// AnalyzeRoutine reports a personal deviation as an anomaly, so the detector can run in an analyzer chain
+// It fails with errInsufficientHistory until the user has MinSamples logs in the window
+func (d *BaselineDetector) AnalyzeRoutine(ctx context.Context, routineLog database.RoutineLog) (*AIServiceResponse, error) {
+	report, err := d.Evaluate(ctx, routineLog)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, errInsufficientHistory
+	}
+
+	response := &AIServiceResponse{
+		IsAnomaly:       report.IsDeviation,
+		ConfidenceScore: heuristicConfidence,
+		AnomalyType:     "normal_routine",
+		Recommendations: []string{fmt.Sprintf("Your routine is in line with your last %d days.", report.WindowDays)},
+		Timestamp:       time.Now().Format(time.RFC3339),
+		Source:          SourceBaseline,
+		Baseline:        report,
+	}
+	if report.IsDeviation {
+		response.AnomalyType = "personal_deviation"
+		response.Recommendations = nil
+		for _, metric := range baselineMetrics {
+			if containsString(report.Deviations, metric.name) {
+				response.Recommendations = append(response.Recommendations,
+					fmt.Sprintf("Your %s differs from your usual routine of the last %d days.", metric.label, report.WindowDays))
+			}
+		}
+	}
+
+	return response, nil
+}
+
+// CheckHealth always succeeds; the detector reads the same database as the rest of the API
+func (d *BaselineDetector) CheckHealth(ctx context.Context) error {
+	return nil
+}
+
+// compare scores routineLog against history
+func (d *BaselineDetector) compare(routineLog database.RoutineLog, history []database.RoutineLog) *database.BaselineReport {
+	if len(history) < d.config.MinSamples {
+		return nil
+	}
+
+	report := &database.BaselineReport{
+		WindowDays: d.config.WindowDays,
+		SampleSize: len(history),
+		ZThreshold: d.config.ZThreshold,
+		Deviations: []string{},
+		Metrics:    make(map[string]database.MetricBaseline),
+	}
+
+	for _, metric := range baselineMetrics {
+		value, ok := metric.value(routineLog)
+		if !ok {
+			continue
+		}
+
+		var samples []float64
+		for _, past := range history {
+			if sample, ok := metric.value(past); ok {
+				samples = append(samples, sample)
+			}
+		}
+		// A metric without samples has no mean to compare with
+		if len(samples) == 0 || len(samples) < d.config.MinSamples {
+			continue
+		}
+
+		mean, stdDev := meanStdDev(samples)
+		zScore := (value - mean) / math.Max(stdDev, metric.minStdDev)
+
+		report.Metrics[metric.name] = database.MetricBaseline{
+			Value:  value,
+			Mean:   round2(mean),
+			StdDev: round2(stdDev),
+			ZScore: round2(zScore),
+		}
+		if math.Abs(zScore) >= d.config.ZThreshold {
+			report.Deviations = append(report.Deviations, metric.name)
+		}
+	}
+
+	report.IsDeviation = len(report.Deviations) > 0
+	return report
+}
+
+// meanStdDev returns the mean and sample standard deviation of values, which must not be empty
+func meanStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	if len(values) < 2 {
+		return mean, 0
+	}
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+// round2 rounds to two decimals for storage
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
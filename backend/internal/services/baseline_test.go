@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"lifepattern-api/internal/config"
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
+)
+
+var testBaselineConfig = BaselineConfig{WindowDays: 28, MinSamples: 7, ZThreshold: 2.0}
+
+// nightOwlLog is a routine log of a user who sleeps from 03:00 to 11:00
+func nightOwlLog(logDate string, sleepHours float64) database.RoutineLog {
+	routineLog := database.RoutineLog{
+		UserID:           "user_1",
+		SleepHours:       sleepHours,
+		MealTimes:        []string{"12:00", "18:00", "23:30"},
+		ScreenTime:       5.0,
+		ExerciseDuration: 0.5,
+		WakeUpTime:       "11:00",
+		BedTime:          "03:00",
+		WaterIntake:      2.0,
+		StressLevel:      4,
+		LogDate:          logDate,
+	}
+	routineLog.Sleep = computeSleepMetrics(routineLog)
+	return routineLog
+}
+
+// saveNightOwlHistory stores a night owl log for each of the days days before 2024-02-01
+func saveNightOwlHistory(repo *MockRepository, days int) {
+	for i := 1; i <= days; i++ {
+		sleepHours := 7.5 + float64(i%2) // alternates between 7.5 and 8.5 hours
+		repo.SaveRoutineLog(context.Background(), nightOwlLog(fmt.Sprintf("2024-01-%02d", 32-i), sleepHours), true)
+	}
+}
+
+func TestBaselineDetectorNightOwl(t *testing.T) {
+	mockRepo := NewMockRepository()
+	saveNightOwlHistory(mockRepo, 14)
+	detector := NewBaselineDetector(mockRepo, testBaselineConfig)
+
+	// Another late night is the user's normal routine
+	report, err := detector.Evaluate(context.Background(), nightOwlLog("2024-02-01", 8.0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if report == nil || report.SampleSize != 14 {
+		t.Fatalf("Expected a baseline of 14 logs, got %+v", report)
+	}
+	if report.IsDeviation {
+		t.Fatalf("Expected no personal deviation for the usual routine, got %v", report.Deviations)
+	}
+
+	midpoint := report.Metrics["sleep_midpoint_minutes"]
+	if midpoint.Mean != 420 || midpoint.ZScore != 0 {
+		t.Fatalf("Expected the usual 07:00 midpoint with z-score 0, got %+v", midpoint)
+	}
+
+	// A short night deviates from the user's own sleep
+	report, err = detector.Evaluate(context.Background(), nightOwlLog("2024-02-01", 4.0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !report.IsDeviation || len(report.Deviations) != 1 || report.Deviations[0] != "sleep_hours" {
+		t.Fatalf("Expected a sleep_hours deviation, got %v", report.Deviations)
+	}
+	if z := report.Metrics["sleep_hours"].ZScore; z > -2 {
+		t.Fatalf("Expected a z-score below -2, got %v", z)
+	}
+}
+
+func TestBaselineDetectorMinStdDev(t *testing.T) {
+	mockRepo := NewMockRepository()
+	saveNightOwlHistory(mockRepo, 10)
+	detector := NewBaselineDetector(mockRepo, testBaselineConfig)
+
+	// Stress was 4 every day; one point more is 2 floored standard deviations, not infinity
+	routineLog := nightOwlLog("2024-02-01", 8.0)
+	routineLog.StressLevel = 5
+
+	report, err := detector.Evaluate(context.Background(), routineLog)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stress := report.Metrics["stress_level"]
+	if stress.StdDev != 0 || stress.ZScore != 2 {
+		t.Fatalf("Expected stddev 0 and z-score 2, got %+v", stress)
+	}
+}
+
+func TestBaselineDetectorInsufficientHistory(t *testing.T) {
+	mockRepo := NewMockRepository()
+	saveNightOwlHistory(mockRepo, 6)
+
+	// Logs outside the window do not count
+	mockRepo.SaveRoutineLog(context.Background(), nightOwlLog("2023-12-01", 8.0), true)
+
+	detector := NewBaselineDetector(mockRepo, testBaselineConfig)
+
+	report, err := detector.Evaluate(context.Background(), nightOwlLog("2024-02-01", 8.0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if report != nil {
+		t.Fatalf("Expected no baseline with 6 logs in the window, got %+v", report)
+	}
+}
+
+func TestBaselineDetectorDefaultsNonPositiveConfig(t *testing.T) {
+	detector := NewBaselineDetector(NewMockRepository(), BaselineConfig{WindowDays: -1, MinSamples: 0, ZThreshold: 0})
+
+	if detector.config != testBaselineConfig {
+		t.Fatalf("Expected the default baseline config, got %+v", detector.config)
+	}
+
+	// Without history there is nothing to compare, even if no samples were required
+	detector.config.MinSamples = 0
+	report, err := detector.Evaluate(context.Background(), nightOwlLog("2024-02-01", 8.0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report == nil || len(report.Metrics) != 0 || report.IsDeviation {
+		t.Fatalf("Expected an empty baseline without history, got %+v", report)
+	}
+	if _, err := json.Marshal(report); err != nil {
+		t.Fatalf("Expected the report to be stored as JSON, got %v", err)
+	}
+}
+
+func TestBaselineDetectorAnalyzeRoutine(t *testing.T) {
+	mockRepo := NewMockRepository()
+	detector := NewBaselineDetector(mockRepo, testBaselineConfig)
+
+	if _, err := detector.AnalyzeRoutine(context.Background(), nightOwlLog("2024-02-01", 4.0)); !errors.Is(err, errInsufficientHistory) {
+		t.Fatalf("Expected errInsufficientHistory, got %v", err)
+	}
+
+	saveNightOwlHistory(mockRepo, 14)
+
+	response, err := detector.AnalyzeRoutine(context.Background(), nightOwlLog("2024-02-01", 4.0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !response.IsAnomaly || response.AnomalyType != "personal_deviation" || response.Source != SourceBaseline {
+		t.Fatalf("Expected a personal deviation, got %+v", response)
+	}
+	want := "Your sleep duration differs from your usual routine of the last 28 days."
+	if len(response.Recommendations) != 1 || response.Recommendations[0] != want {
+		t.Fatalf("Expected %q, got %q", want, response.Recommendations)
+	}
+
+	response, err = detector.AnalyzeRoutine(context.Background(), nightOwlLog("2024-02-01", 8.0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.IsAnomaly || response.AnomalyType != "normal_routine" {
+		t.Fatalf("Expected the usual routine, got %+v", response)
+	}
+}
+
+func TestCreateRoutineLogStoresBaselineAndAnalyses(t *testing.T) {
+	mockRepo := NewMockRepository()
+	saveNightOwlHistory(mockRepo, 14)
+	analyzer := newTestAnalyzerChain(t, config.DefaultAnalyzers, NewMockAIService(false), mockRepo, nil)
+	service := NewRoutineService(mockRepo, analyzer, logging.Discard(), nil)
+
+	response, err := service.CreateRoutineLog(context.Background(), nightOwlLog("2024-02-01", 8.0), ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.AIResult.Baseline == nil || response.AIResult.Baseline.IsDeviation {
+		t.Fatalf("Expected a baseline without deviations in the response, got %+v", response.AIResult.Baseline)
+	}
+	if len(response.AIResult.Analyses) != 2 || response.AnalysisQueued {
+		t.Fatalf("Expected AI service and baseline analyses without a queued job, got %+v", response)
+	}
+
+	report := mockRepo.aiReports[response.LogID]
+	if report.Baseline == nil || report.Baseline.SampleSize != 14 {
+		t.Fatalf("Expected the baseline to be stored with the AI report, got %+v", report.Baseline)
+	}
+	if report.Source != SourceAIService || len(report.Analyses) != 2 || report.Analyses[1].Analyzer != SourceBaseline {
+		t.Fatalf("Expected the AI service verdict with both analyses, got %+v", report)
+	}
+}
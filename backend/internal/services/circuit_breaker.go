@@ -0,0 +1,135 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit breaker
+type CircuitState string
+
+const (
+	// CircuitClosed lets every call through
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen rejects calls until the open timeout has elapsed
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single probe call through to decide whether to close again
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitBreakerConfig tunes when a circuit breaker opens and how long it stays open
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before a probe call is allowed
+	OpenTimeout time.Duration
+}
+
+// CircuitSnapshot is a point-in-time view of a circuit breaker, reported by /health
+type CircuitSnapshot struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+}
+
+// CircuitBreaker stops calling a dependency after repeated failures
+// Closed: calls pass and consecutive failures are counted
+// Open: calls fail fast with ErrCircuitOpen until OpenTimeout has elapsed
+// Half-open: one probe call passes; success closes the circuit, failure opens it again
+type CircuitBreaker struct {
+	config CircuitBreakerConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 1
+	}
+
+	return &CircuitBreaker{
+		config: config,
+		now:    time.Now,
+		state:  CircuitClosed,
+	}
+}
+
+// Allow reports whether a call may proceed; it returns ErrCircuitOpen otherwise
+// Every allowed call must be followed by Success, Failure or Abandon
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.config.OpenTimeout {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Success records a call that reached a healthy dependency and closes the circuit
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records a failed call; it opens the circuit once the threshold is reached,
+// or immediately when a half-open probe fails
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if b.state == CircuitHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Abandon records a call that ended without telling whether the dependency is healthy,
+// e.g. because the caller cancelled it; a half-open breaker lets the next call probe instead
+func (b *CircuitBreaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// Snapshot returns the current state of the breaker
+func (b *CircuitBreaker) Snapshot() CircuitSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := CircuitSnapshot{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		snapshot.OpenedAt = &openedAt
+	}
+	return snapshot
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestBreaker returns a breaker whose clock is controlled by the returned pointer
+func newTestBreaker(threshold int, openTimeout time.Duration) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: threshold,
+		OpenTimeout:      openTimeout,
+	})
+	breaker.now = func() time.Time { return now }
+	return breaker, &now
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	breaker, _ := newTestBreaker(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if err := breaker.Allow(); err != nil {
+			t.Fatalf("Expected call %d to be allowed, got %v", i+1, err)
+		}
+		breaker.Failure()
+	}
+
+	if state := breaker.Snapshot().State; state != CircuitClosed {
+		t.Fatalf("Expected circuit to stay closed below the threshold, got %s", state)
+	}
+
+	breaker.Allow()
+	breaker.Failure()
+
+	snapshot := breaker.Snapshot()
+	if snapshot.State != CircuitOpen {
+		t.Fatalf("Expected circuit to open after 3 failures, got %s", snapshot.State)
+	}
+	if snapshot.ConsecutiveFailures != 3 {
+		t.Fatalf("Expected 3 consecutive failures, got %d", snapshot.ConsecutiveFailures)
+	}
+	if snapshot.OpenedAt == nil {
+		t.Fatal("Expected opened_at to be set")
+	}
+
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	breaker, _ := newTestBreaker(2, time.Minute)
+
+	breaker.Allow()
+	breaker.Failure()
+	breaker.Allow()
+	breaker.Success()
+	breaker.Allow()
+	breaker.Failure()
+
+	if state := breaker.Snapshot().State; state != CircuitClosed {
+		t.Fatalf("Expected non-consecutive failures to keep the circuit closed, got %s", state)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	breaker, now := newTestBreaker(1, time.Minute)
+
+	breaker.Allow()
+	breaker.Failure()
+
+	*now = now.Add(time.Minute)
+
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Expected a probe call after the open timeout, got %v", err)
+	}
+	if state := breaker.Snapshot().State; state != CircuitHalfOpen {
+		t.Fatalf("Expected half_open state, got %s", state)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected only one probe call while half-open, got %v", err)
+	}
+
+	// A failed probe opens the circuit again
+	breaker.Failure()
+	if state := breaker.Snapshot().State; state != CircuitOpen {
+		t.Fatalf("Expected failed probe to reopen the circuit, got %s", state)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen right after the probe failed, got %v", err)
+	}
+
+	// A successful probe closes it
+	*now = now.Add(time.Minute)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Expected a second probe call, got %v", err)
+	}
+	breaker.Success()
+
+	snapshot := breaker.Snapshot()
+	if snapshot.State != CircuitClosed || snapshot.ConsecutiveFailures != 0 || snapshot.OpenedAt != nil {
+		t.Fatalf("Expected closed circuit without failures, got %+v", snapshot)
+	}
+}
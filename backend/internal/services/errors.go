@@ -0,0 +1,30 @@
+package services
+
+import "lifepattern-api/internal/apperror"
+
+// Service errors carry the code and message reported to API clients
+var (
+	// ErrUserNotFound is returned when an operation references an unknown user
+	ErrUserNotFound = apperror.NotFound("user not found")
+
+	// ErrUserExists is returned when a user ID, username or device is already registered
+	ErrUserExists = apperror.Conflict("user already exists")
+
+	// ErrRoutineLogNotFound is returned when a routine log does not exist
+	ErrRoutineLogNotFound = apperror.NotFound("routine log not found")
+
+	// ErrRoutineLogExists is returned when the user already has a routine log for the day
+	ErrRoutineLogExists = apperror.Conflict("routine log already exists for this day")
+
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+	ErrInvalidCursor = apperror.Validation("cursor", "invalid cursor")
+
+	// ErrForbidden is returned when a user accesses data that belongs to another user
+	ErrForbidden = apperror.New(apperror.CodeForbidden, "access to another user's data is forbidden")
+
+	// ErrInvalidCredentials is returned when the user is unknown or the secret is not the one issued to it
+	ErrInvalidCredentials = apperror.New(apperror.CodeUnauthorized, "invalid credentials")
+
+	// ErrCircuitOpen is returned without calling the AI service while its circuit breaker is open
+	ErrCircuitOpen = apperror.Unavailable("AI service circuit breaker is open")
+)
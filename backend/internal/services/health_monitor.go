@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Dependencies checked by the health monitor
+const (
+	DependencyDatabase  = "database"
+	DependencyAIService = "ai_service"
+)
+
+// DependencyStatus is the result of the latest check of a dependency
+type DependencyStatus string
+
+const (
+	// DependencyUnknown means the dependency has not been checked yet
+	DependencyUnknown   DependencyStatus = "unknown"
+	DependencyHealthy   DependencyStatus = "healthy"
+	DependencyUnhealthy DependencyStatus = "unhealthy"
+)
+
+// Defaults for HealthCheckConfig fields that are not set
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 2 * time.Second
+)
+
+// HealthCheckConfig tunes the background dependency checks
+type HealthCheckConfig struct {
+	// Interval is the time between two rounds of checks
+	Interval time.Duration
+	// Timeout bounds a single check
+	Timeout time.Duration
+}
+
+// DependencyHealth is the cached result of a dependency check, reported by /health
+// Check errors are only logged: they can name internal hosts and driver details
+type DependencyHealth struct {
+	Status      DependencyStatus `json:"status"`
+	LatencyMS   int64            `json:"latency_ms"`
+	CheckedAt   *time.Time       `json:"checked_at,omitempty"`
+	LastSuccess *time.Time       `json:"last_success,omitempty"`
+}
+
+// dependencyCheck probes one dependency
+type dependencyCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// HealthMonitor checks the database and the AI service in the background and caches
+// the results, so health probes never wait on a dependency
+type HealthMonitor struct {
+	checks []dependencyCheck
+	config HealthCheckConfig
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu      sync.RWMutex
+	results map[string]DependencyHealth
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewHealthMonitor(repo RepositoryInterface, aiService AIServiceInterface, config HealthCheckConfig, logger *slog.Logger) *HealthMonitor {
+	if config.Interval <= 0 {
+		config.Interval = defaultHealthCheckInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultHealthCheckTimeout
+	}
+
+	checks := []dependencyCheck{
+		{name: DependencyDatabase, check: repo.Ping},
+		{name: DependencyAIService, check: aiService.CheckHealth},
+	}
+
+	results := make(map[string]DependencyHealth, len(checks))
+	for _, c := range checks {
+		results[c.name] = DependencyHealth{Status: DependencyUnknown}
+	}
+
+	return &HealthMonitor{
+		checks:  checks,
+		config:  config,
+		logger:  logger,
+		now:     time.Now,
+		results: results,
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start runs a first round of checks, so the server is ready as soon as it listens,
+// then keeps checking every Interval until Stop is called
+func (m *HealthMonitor) Start() {
+	m.CheckNow(context.Background())
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.CheckNow(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop stops the background checks, waiting for a running round until ctx is done
+func (m *HealthMonitor) Stop(ctx context.Context) error {
+	m.stopOnce.Do(func() { close(m.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CheckNow checks every dependency concurrently and caches the results
+// Each check is bounded by the configured Timeout
+func (m *HealthMonitor) CheckNow(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, c := range m.checks {
+		wg.Add(1)
+		go func(c dependencyCheck) {
+			defer wg.Done()
+			m.record(ctx, c)
+		}(c)
+	}
+	wg.Wait()
+}
+
+// record runs one check and stores its result
+func (m *HealthMonitor) record(ctx context.Context, c dependencyCheck) {
+	checkCtx, cancel := context.WithTimeout(ctx, m.config.Timeout)
+	defer cancel()
+
+	start := m.now()
+	err := c.check(checkCtx)
+	checkedAt := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.results[c.name]
+	result := DependencyHealth{
+		Status:      DependencyHealthy,
+		LatencyMS:   checkedAt.Sub(start).Milliseconds(),
+		CheckedAt:   &checkedAt,
+		LastSuccess: previous.LastSuccess,
+	}
+	if err != nil {
+		result.Status = DependencyUnhealthy
+		if previous.Status != DependencyUnhealthy {
+			m.logger.Warn("dependency became unhealthy", "dependency", c.name, "error", err)
+		}
+	} else {
+		result.LastSuccess = &checkedAt
+		if previous.Status == DependencyUnhealthy {
+			m.logger.Info("dependency recovered", "dependency", c.name)
+		}
+	}
+	m.results[c.name] = result
+}
+
+// Dependencies returns the cached health of every dependency by name
+func (m *HealthMonitor) Dependencies() map[string]DependencyHealth {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := make(map[string]DependencyHealth, len(m.results))
+	for name, result := range m.results {
+		results[name] = result
+	}
+	return results
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lifepattern-api/internal/logging"
+)
+
+// flakyAIService fails its health check while failing is set and counts the checks
+type flakyAIService struct {
+	MockAIService
+	failing atomic.Bool
+	checks  atomic.Int32
+}
+
+func (f *flakyAIService) CheckHealth(ctx context.Context) error {
+	f.checks.Add(1)
+	if f.failing.Load() {
+		return errors.New("AI service unhealthy")
+	}
+	return nil
+}
+
+func newTestHealthMonitor(aiService AIServiceInterface, interval time.Duration) *HealthMonitor {
+	return NewHealthMonitor(NewMockRepository(), aiService, HealthCheckConfig{
+		Interval: interval,
+		Timeout:  time.Second,
+	}, logging.Discard())
+}
+
+func TestHealthMonitorDefaultsUnsetInterval(t *testing.T) {
+	monitor := newTestHealthMonitor(NewMockAIService(false), 0)
+
+	if monitor.config.Interval != defaultHealthCheckInterval {
+		t.Fatalf("Expected the default interval, got %s", monitor.config.Interval)
+	}
+
+	// A zero interval would make the ticker panic
+	monitor.Start()
+	if err := monitor.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestHealthMonitorUnknownBeforeFirstCheck(t *testing.T) {
+	monitor := newTestHealthMonitor(NewMockAIService(false), time.Minute)
+
+	for name, result := range monitor.Dependencies() {
+		if result.Status != DependencyUnknown {
+			t.Fatalf("Expected %s to be unknown before the first check, got %s", name, result.Status)
+		}
+	}
+}
+
+func TestHealthMonitorKeepsLastSuccess(t *testing.T) {
+	aiService := &flakyAIService{}
+	monitor := newTestHealthMonitor(aiService, time.Minute)
+
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	monitor.now = func() time.Time { return now }
+
+	monitor.CheckNow(context.Background())
+
+	healthy := monitor.Dependencies()[DependencyAIService]
+	if healthy.Status != DependencyHealthy || healthy.LastSuccess == nil || !healthy.LastSuccess.Equal(now) {
+		t.Fatalf("Expected healthy AI service checked at %s, got %+v", now, healthy)
+	}
+
+	aiService.failing.Store(true)
+	later := now.Add(time.Minute)
+	monitor.now = func() time.Time { return later }
+
+	monitor.CheckNow(context.Background())
+
+	unhealthy := monitor.Dependencies()[DependencyAIService]
+	if unhealthy.Status != DependencyUnhealthy {
+		t.Fatalf("Expected unhealthy AI service, got %+v", unhealthy)
+	}
+	if !unhealthy.CheckedAt.Equal(later) || !unhealthy.LastSuccess.Equal(now) {
+		t.Fatalf("Expected checked_at %s and last_success %s, got %+v", later, now, unhealthy)
+	}
+
+	if status := monitor.Dependencies()[DependencyDatabase].Status; status != DependencyHealthy {
+		t.Fatalf("Expected healthy database, got %s", status)
+	}
+}
+
+func TestHealthMonitorCheckTimeout(t *testing.T) {
+	server, _ := newBlockingAIServer()
+	defer server.Close()
+
+	monitor := NewHealthMonitor(NewMockRepository(), NewAIService(server.URL, time.Minute, logging.Discard()), HealthCheckConfig{
+		Interval: time.Minute,
+		Timeout:  50 * time.Millisecond,
+	}, logging.Discard())
+
+	start := time.Now()
+	monitor.CheckNow(context.Background())
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the check to time out quickly, took %s", elapsed)
+	}
+	if status := monitor.Dependencies()[DependencyAIService].Status; status != DependencyUnhealthy {
+		t.Fatalf("Expected a hanging AI service to be unhealthy, got %s", status)
+	}
+}
+
+func TestHealthMonitorRunsInBackground(t *testing.T) {
+	aiService := &flakyAIService{}
+	monitor := newTestHealthMonitor(aiService, 10*time.Millisecond)
+
+	monitor.Start()
+
+	// Start checks synchronously before returning
+	if status := monitor.Dependencies()[DependencyAIService].Status; status != DependencyHealthy {
+		t.Fatalf("Expected a checked AI service after Start, got %s", status)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for aiService.checks.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if checks := aiService.checks.Load(); checks < 3 {
+		t.Fatalf("Expected periodic checks, got %d", checks)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := monitor.Stop(ctx); err != nil {
+		t.Fatalf("Expected monitor to stop, got %v", err)
+	}
+
+	stopped := aiService.checks.Load()
+	time.Sleep(50 * time.Millisecond)
+	if checks := aiService.checks.Load(); checks != stopped {
+		t.Fatalf("Expected no checks after Stop, got %d more", checks-stopped)
+	}
+}
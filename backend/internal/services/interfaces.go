@@ -1,27 +1,83 @@
 package services
 
-import "lifepattern-api/internal/database"
+import (
+	"context"
+	"time"
+
+	"lifepattern-api/internal/database"
+)
 
 // RepositoryInterface defines the interface for database operations
 type RepositoryInterface interface {
-	SaveRoutineLog(log database.RoutineLog) (int, error)
-	SaveAIReport(report database.AIReport) error
-	GetRoutineLogWithAIReport(logID int) (*database.InsightResponse, error)
-	GetRoutineLogsByUser(userID int, limit int) ([]database.RoutineLog, error)
-	Ping() error
+	SaveRoutineLog(ctx context.Context, log database.RoutineLog, overwrite bool) (int, bool, time.Time, error)
+	SaveAIReport(ctx context.Context, report database.AIReport) error
+	GetRoutineLogWithAIReport(ctx context.Context, userID string, logID int) (*database.InsightResponse, error)
+	GetRoutineLogsByUser(ctx context.Context, filter database.LogFilter) ([]database.RoutineLog, error)
+	GetInsightsByUser(ctx context.Context, filter database.LogFilter) ([]database.InsightResponse, error)
+	GetRoutineTrends(ctx context.Context, filter database.TrendFilter) ([]database.TrendBucket, error)
+	GetRoutineLog(ctx context.Context, logID int) (*database.RoutineLog, error)
+	MergeRoutineLog(ctx context.Context, userID string, logDate string, merge func(*database.RoutineLog) error) (*database.RoutineLog, bool, error)
+	PatchRoutineLog(ctx context.Context, userID string, logID int, patch func(*database.RoutineLog) error) (*database.RoutineLog, error)
+	UpdateRoutineLog(ctx context.Context, log database.RoutineLog) (*database.RoutineLog, error)
+	DeleteRoutineLog(ctx context.Context, userID string, logID int) error
+	ReplaceAIReport(ctx context.Context, report database.AIReport) error
+	ReplaceAIReportIfUnchanged(ctx context.Context, report database.AIReport, updatedAt time.Time) (bool, error)
+	EnqueueAnalysis(ctx context.Context, logID int) error
+	EnqueueUnanalyzedLogs(ctx context.Context, minAge time.Duration) (int, error)
+	ClaimAnalysisJobs(ctx context.Context, limit int, lease time.Duration) ([]database.AnalysisJob, error)
+	CompleteAnalysisJob(ctx context.Context, jobID int, version int) (bool, error)
+	RetryAnalysisJob(ctx context.Context, jobID int, delay time.Duration, lastError string) error
+	FailAnalysisJob(ctx context.Context, jobID int, version int, lastError string) error
+	GetAnalysisQueueStats(ctx context.Context) (*database.AnalysisQueueStats, error)
+	GetUserAnalysisQueueStats(ctx context.Context, userID string) (*database.AnalysisQueueStats, error)
+	CreateUser(ctx context.Context, user database.User) (*database.User, error)
+	GetUserByID(ctx context.Context, userID string) (*database.User, error)
+	UpdateUsername(ctx context.Context, userID string, username string) (*database.User, error)
+	ClaimUserSecret(ctx context.Context, userID string, deviceID string, secretHash string) (*database.User, error)
+	Ping(ctx context.Context) error
 	Close() error
 }
 
 // AIServiceInterface defines the interface for AI service operations
 type AIServiceInterface interface {
-	AnalyzeRoutine(routineLog database.RoutineLog) (*AIServiceResponse, error)
-	CheckHealth() error
+	AnalyzeRoutine(ctx context.Context, routineLog database.RoutineLog) (*AIServiceResponse, error)
+	CheckHealth(ctx context.Context) error
+}
+
+// CircuitReporter is implemented by AI services guarded by a circuit breaker
+type CircuitReporter interface {
+	CircuitState() CircuitSnapshot
+}
+
+// HealthReporter exposes the cached health of the API's dependencies
+type HealthReporter interface {
+	Dependencies() map[string]DependencyHealth
 }
 
 // RoutineServiceInterface defines the interface for routine service operations
 type RoutineServiceInterface interface {
-	CreateRoutineLog(routineLog database.RoutineLog) (*CreateRoutineLogResponse, error)
-	GetInsight(logID int) (*database.InsightResponse, error)
-	GetUserRoutineLogs(userID int, limit int) ([]database.RoutineLog, error)
-	GetUserInsights(userID int, limit int) ([]database.InsightResponse, error)
+	CreateRoutineLog(ctx context.Context, routineLog database.RoutineLog, mode ConflictMode) (*CreateRoutineLogResponse, error)
+	GetInsight(ctx context.Context, userID string, logID int) (*database.InsightResponse, error)
+	GetRoutineLog(ctx context.Context, userID string, logID int) (*database.RoutineLog, error)
+	MergeRoutineLog(ctx context.Context, userID string, logDate string, patch []byte) (*CreateRoutineLogResponse, error)
+	UpdateRoutineLog(ctx context.Context, userID string, routineLog database.RoutineLog) (*CreateRoutineLogResponse, error)
+	PatchRoutineLog(ctx context.Context, userID string, logID int, patch []byte) (*CreateRoutineLogResponse, error)
+	DeleteRoutineLog(ctx context.Context, userID string, logID int) error
+	GetUserRoutineLogs(ctx context.Context, query LogQuery) (*RoutineLogPage, error)
+	GetUserInsights(ctx context.Context, query LogQuery) (*InsightPage, error)
+	GetTrends(ctx context.Context, query TrendQuery) (*Trends, error)
+}
+
+// AnalysisQueueInterface exposes the pending analysis queue
+type AnalysisQueueInterface interface {
+	QueueStats(ctx context.Context) (*database.AnalysisQueueStats, error)
+	UserQueueStats(ctx context.Context, userID string) (*database.AnalysisQueueStats, error)
+}
+
+// UserServiceInterface defines the interface for user service operations
+type UserServiceInterface interface {
+	RegisterUser(ctx context.Context, user database.User) (*RegisteredUser, error)
+	GetUser(ctx context.Context, userID string) (*database.User, error)
+	RenameUser(ctx context.Context, userID string, username string) (*database.User, error)
+	Authenticate(ctx context.Context, userID string, secret string) (*database.User, error)
 }
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"lifepattern-api/internal/database"
+)
+
+// Analysis sources recorded on AI reports
+const (
+	// SourceAIService marks analyses by the remote AI service
+	SourceAIService = "ai_service"
+	// SourceLocalRules marks analyses by LocalRulesAnalyzer
+	SourceLocalRules = "local_rules"
+	// SourceBaseline marks analyses by BaselineDetector
+	SourceBaseline = "baseline"
+)
+
+// heuristicConfidence is reported for every rule-based or baseline analysis; it is fixed and low
+// because these analyzers only check thresholds and do not estimate a probability
+const heuristicConfidence = 0.5
+
+// LocalRulesAnalyzer analyzes routine logs with fixed thresholds, without the AI service
+// The thresholds mirror _determine_anomaly_type and the recommendations mirror
+// generate_recommendations of the AI service, so both read the same to users
+type LocalRulesAnalyzer struct{}
+
+func NewLocalRulesAnalyzer() *LocalRulesAnalyzer {
+	return &LocalRulesAnalyzer{}
+}
+
+// AnalyzeRoutine flags the first threshold the log crosses, in the order of the AI service
+// A log that crosses none is a normal routine
+func (a *LocalRulesAnalyzer) AnalyzeRoutine(ctx context.Context, routineLog database.RoutineLog) (*AIServiceResponse, error) {
+	anomalyType := localAnomalyType(routineLog)
+	isAnomaly := anomalyType != "normal_routine"
+
+	return &AIServiceResponse{
+		IsAnomaly:       isAnomaly,
+		ConfidenceScore: heuristicConfidence,
+		AnomalyType:     anomalyType,
+		Recommendations: localRecommendations(routineLog, isAnomaly),
+		Timestamp:       time.Now().Format(time.RFC3339),
+		Source:          SourceLocalRules,
+	}, nil
+}
+
+// CheckHealth always succeeds since the rules need no external service
+func (a *LocalRulesAnalyzer) CheckHealth(ctx context.Context) error {
+	return nil
+}
+
+// localAnomalyType applies the thresholds of the AI service's _determine_anomaly_type
+func localAnomalyType(routineLog database.RoutineLog) string {
+	switch {
+	case healthScore(routineLog) < 0.5:
+		return "general_unhealthy_routine"
+	case routineLog.SleepHours < 6:
+		return "insufficient_sleep"
+	case routineLog.SleepHours > 10:
+		return "excessive_sleep"
+	case routineLog.ScreenTime > 10:
+		return "excessive_screen_time"
+	case routineLog.ExerciseDuration < 0.3:
+		return "insufficient_exercise"
+	case routineLog.WaterIntake < 1.5:
+		return "low_water_intake"
+	case routineLog.StressLevel > 8:
+		return "high_stress_level"
+	case len(routineLog.MealTimes) < 2:
+		return "irregular_meals"
+	default:
+		return "normal_routine"
+	}
+}
+
+// healthScore is the weighted health score feature of the AI service's preprocess_data
+func healthScore(routineLog database.RoutineLog) float64 {
+	return routineLog.SleepHours/8.0*0.3 +
+		(1.0-routineLog.ScreenTime/12.0)*0.2 +
+		routineLog.ExerciseDuration/1.0*0.2 +
+		routineLog.WaterIntake/2.5*0.1 +
+		(1.0-float64(routineLog.StressLevel)/10.0)*0.1 +
+		float64(len(routineLog.MealTimes))/3.0*0.1
+}
+
+// localRecommendations mirrors the AI service's generate_recommendations
+func localRecommendations(routineLog database.RoutineLog, isAnomaly bool) []string {
+	if !isAnomaly {
+		return []string{"Your daily routine looks healthy! Keep up the good work."}
+	}
+
+	var recommendations []string
+	if routineLog.SleepHours < 7 {
+		recommendations = append(recommendations, "Consider increasing your sleep duration to 7-9 hours for better health.")
+	} else if routineLog.SleepHours > 9 {
+		recommendations = append(recommendations, "You might be oversleeping. Aim for 7-9 hours of sleep.")
+	}
+	if routineLog.ScreenTime > 8 {
+		recommendations = append(recommendations, "Try to reduce screen time and take regular breaks to protect your eyes.")
+	}
+	if routineLog.ExerciseDuration < 0.5 {
+		recommendations = append(recommendations, "Aim for at least 30 minutes of moderate exercise daily.")
+	}
+	if routineLog.WaterIntake < 2 {
+		recommendations = append(recommendations, "Increase your water intake to at least 2 liters per day.")
+	}
+	if routineLog.StressLevel > 7 {
+		recommendations = append(recommendations, "Consider stress management techniques like meditation or deep breathing.")
+	}
+	if len(routineLog.MealTimes) < 3 {
+		recommendations = append(recommendations, "Try to have 3 regular meals per day for better metabolism.")
+	}
+	return recommendations
+}
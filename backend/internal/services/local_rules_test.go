@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"lifepattern-api/internal/config"
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
+)
+
+// healthyLog crosses none of the local rule thresholds
+func healthyLog() database.RoutineLog {
+	return database.RoutineLog{
+		UserID:           "user_1",
+		SleepHours:       8.0,
+		MealTimes:        []string{"07:30", "12:00", "18:30"},
+		ScreenTime:       4.0,
+		ExerciseDuration: 1.0,
+		WakeUpTime:       "07:00",
+		BedTime:          "23:00",
+		WaterIntake:      2.5,
+		StressLevel:      4,
+		LogDate:          "2024-01-15",
+	}
+}
+
+func TestLocalRulesAnalyzer(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(*database.RoutineLog)
+		anomalyType string
+	}{
+		{"healthy", func(l *database.RoutineLog) {}, "normal_routine"},
+		{"low health score", func(l *database.RoutineLog) {
+			l.SleepHours, l.ScreenTime, l.ExerciseDuration, l.WaterIntake = 4.0, 11.0, 0.0, 0.5
+		}, "general_unhealthy_routine"},
+		{"short sleep", func(l *database.RoutineLog) { l.SleepHours = 5.5 }, "insufficient_sleep"},
+		{"long sleep", func(l *database.RoutineLog) { l.SleepHours = 10.5 }, "excessive_sleep"},
+		{"screen time", func(l *database.RoutineLog) { l.ScreenTime = 10.5 }, "excessive_screen_time"},
+		{"exercise", func(l *database.RoutineLog) { l.ExerciseDuration = 0.2 }, "insufficient_exercise"},
+		{"water", func(l *database.RoutineLog) { l.WaterIntake = 1.0 }, "low_water_intake"},
+		{"stress", func(l *database.RoutineLog) { l.StressLevel = 9 }, "high_stress_level"},
+		{"meals", func(l *database.RoutineLog) { l.MealTimes = []string{"12:00"} }, "irregular_meals"},
+		// Sleep is checked before screen time, as in the AI service
+		{"first rule wins", func(l *database.RoutineLog) { l.SleepHours, l.ScreenTime = 5.5, 10.5 }, "insufficient_sleep"},
+	}
+
+	analyzer := NewLocalRulesAnalyzer()
+	for _, tt := range tests {
+		routineLog := healthyLog()
+		tt.modify(&routineLog)
+
+		response, err := analyzer.AnalyzeRoutine(context.Background(), routineLog)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.name, err)
+		}
+
+		if response.AnomalyType != tt.anomalyType {
+			t.Fatalf("%s: expected anomaly type %s, got %s", tt.name, tt.anomalyType, response.AnomalyType)
+		}
+		if response.IsAnomaly != (tt.anomalyType != "normal_routine") {
+			t.Fatalf("%s: expected is_anomaly %v, got %v", tt.name, !response.IsAnomaly, response.IsAnomaly)
+		}
+		if response.Source != SourceLocalRules || len(response.Recommendations) == 0 {
+			t.Fatalf("%s: expected local rules with recommendations, got %+v", tt.name, response)
+		}
+	}
+}
+
+func TestLocalRecommendations(t *testing.T) {
+	routineLog := healthyLog()
+	routineLog.SleepHours = 5.5
+	routineLog.WaterIntake = 1.0
+
+	recommendations := localRecommendations(routineLog, true)
+
+	expected := []string{
+		"Consider increasing your sleep duration to 7-9 hours for better health.",
+		"Increase your water intake to at least 2 liters per day.",
+	}
+	if len(recommendations) != len(expected) {
+		t.Fatalf("Expected %d recommendations, got %v", len(expected), recommendations)
+	}
+	for i := range expected {
+		if recommendations[i] != expected[i] {
+			t.Fatalf("Expected recommendation %q, got %q", expected[i], recommendations[i])
+		}
+	}
+}
+
+func TestCreateRoutineLogFallsBackToLocalRules(t *testing.T) {
+	mockRepo := NewMockRepository()
+	analyzer := newTestAnalyzerChain(t, config.DefaultAnalyzers, NewMockAIService(true), mockRepo, nil)
+	service := NewRoutineService(mockRepo, analyzer, logging.Discard(), nil)
+
+	routineLog := healthyLog()
+	routineLog.StressLevel = 9
+
+	response, err := service.CreateRoutineLog(context.Background(), routineLog, ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !response.HasAI || response.AIResult.Source != SourceLocalRules || response.AIResult.AnomalyType != "high_stress_level" {
+		t.Fatalf("Expected a local rules analysis, got %+v", response.AIResult)
+	}
+
+	report := mockRepo.aiReports[response.LogID]
+	if report.Source != SourceLocalRules {
+		t.Fatalf("Expected the report to be marked %s, got %q", SourceLocalRules, report.Source)
+	}
+
+	// The log is still queued for the AI service
+	if !response.AnalysisQueued || len(mockRepo.jobs) != 1 {
+		t.Fatalf("Expected the log to be queued for re-analysis, got queued=%v with %d jobs",
+			response.AnalysisQueued, len(mockRepo.jobs))
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lifepattern-api/internal/metrics"
+)
+
+// AI call outcomes reported by lifepattern_ai_requests_total
+const (
+	aiOutcomeSuccess         = "success"
+	aiOutcomeClientError     = "client_error"
+	aiOutcomeServerError     = "server_error"
+	aiOutcomeTimeout         = "timeout"
+	aiOutcomeCanceled        = "canceled"
+	aiOutcomeCircuitOpen     = "circuit_open"
+	aiOutcomeConnectionError = "connection_error"
+)
+
+// Metrics records AI service calls and analysis results
+// A nil *Metrics records nothing, so services can be built without a registry in tests
+type Metrics struct {
+	aiRequests *metrics.Counter
+	aiDuration *metrics.Histogram
+	analyses   *metrics.Counter
+}
+
+// NewMetrics registers the service metrics with registry
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		aiRequests: registry.NewCounter("lifepattern_ai_requests_total",
+			"AI service calls by operation and outcome.", "operation", "outcome"),
+		aiDuration: registry.NewHistogram("lifepattern_ai_request_duration_seconds",
+			"AI service call latency by operation.", metrics.DefaultBuckets, "operation"),
+		analyses: registry.NewCounter("lifepattern_routine_analyses_total",
+			"Analyzed routine logs by anomaly type, analyzer and model version.",
+			"anomaly_type", "is_anomaly", "source", "model_version"),
+	}
+}
+
+// observeAICall records one call that reached the AI service
+func (m *Metrics) observeAICall(operation string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.aiRequests.Inc(operation, aiCallOutcome(err))
+	m.aiDuration.Observe(duration.Seconds(), operation)
+}
+
+// observeRejectedAICall records a call the circuit breaker did not let through
+func (m *Metrics) observeRejectedAICall(operation string) {
+	if m == nil {
+		return
+	}
+	m.aiRequests.Inc(operation, aiOutcomeCircuitOpen)
+}
+
+// observeAnalysis records the result of a routine analysis
+func (m *Metrics) observeAnalysis(aiResponse *AIServiceResponse) {
+	if m == nil {
+		return
+	}
+	m.analyses.Inc(aiResponse.AnomalyType, strconv.FormatBool(aiResponse.IsAnomaly), aiResponse.Source,
+		aiResponse.ModelVersion)
+}
+
+// aiCallOutcome classifies the error of an AI service call
+func aiCallOutcome(err error) string {
+	if err == nil {
+		return aiOutcomeSuccess
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return aiOutcomeCircuitOpen
+	}
+	if errors.Is(err, context.Canceled) {
+		return aiOutcomeCanceled
+	}
+
+	var statusErr *AIServiceStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode >= http.StatusInternalServerError {
+			return aiOutcomeServerError
+		}
+		return aiOutcomeClientError
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return aiOutcomeTimeout
+	}
+	return aiOutcomeConnectionError
+}
+
+// RegisterCircuitMetrics exposes the state of the AI service circuit breaker
+func RegisterCircuitMetrics(registry *metrics.Registry, reporter CircuitReporter) {
+	state := registry.NewGauge("lifepattern_ai_circuit_state",
+		"1 for the current state of the AI service circuit breaker, 0 for the others.", "state")
+	failures := registry.NewGauge("lifepattern_ai_circuit_consecutive_failures",
+		"Consecutive AI service failures counted by the circuit breaker.")
+
+	registry.OnScrape(func(context.Context) {
+		snapshot := reporter.CircuitState()
+		for _, s := range []CircuitState{CircuitClosed, CircuitHalfOpen, CircuitOpen} {
+			value := 0.0
+			if s == snapshot.State {
+				value = 1
+			}
+			state.Set(value, string(s))
+		}
+		failures.Set(float64(snapshot.ConsecutiveFailures))
+	})
+}
+
+// RegisterQueueMetrics exposes the pending analysis queue, read once per scrape
+// A failed read keeps the previous values and is logged
+func RegisterQueueMetrics(registry *metrics.Registry, queue AnalysisQueueInterface, logger *slog.Logger) {
+	jobs := registry.NewGauge("lifepattern_analysis_queue_jobs",
+		"Analysis jobs: all pending, ready to run, claimed by a worker, or failed after their last attempt.", "state")
+	maxAttempts := registry.NewGauge("lifepattern_analysis_queue_max_attempts",
+		"Highest attempt count among pending analysis jobs.")
+	oldestAge := registry.NewGauge("lifepattern_analysis_queue_oldest_job_age_seconds",
+		"Age of the oldest pending analysis job, 0 when the queue is empty.")
+
+	registry.OnScrape(func(ctx context.Context) {
+		stats, err := queue.QueueStats(ctx)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to read analysis queue metrics", "error", err)
+			return
+		}
+
+		jobs.Set(float64(stats.Depth), "all")
+		jobs.Set(float64(stats.Ready), "ready")
+		jobs.Set(float64(stats.InProgress), "in_progress")
+		jobs.Set(float64(stats.Failed), "failed")
+		maxAttempts.Set(float64(stats.MaxAttempts))
+
+		age := 0.0
+		if stats.OldestEnqueuedAt != nil {
+			age = time.Since(*stats.OldestEnqueuedAt).Seconds()
+		}
+		oldestAge.Set(age)
+	})
+}
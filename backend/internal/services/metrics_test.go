@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
+	"lifepattern-api/internal/metrics"
+)
+
+func scrapeMetrics(t *testing.T, registry *metrics.Registry) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := registry.Write(context.Background(), &buf); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return buf.String()
+}
+
+func TestMetricsAICalls(t *testing.T) {
+	var calls int32
+	server := newCountingAIServer(&calls, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	service := newTestResilientService(server.URL, 0, 2)
+	service.metrics = NewMetrics(registry)
+	RegisterCircuitMetrics(registry, service)
+
+	// Two failures open the circuit, the third call is rejected without reaching the AI service
+	for i := 0; i < 3; i++ {
+		service.AnalyzeRoutine(context.Background(), database.RoutineLog{UserID: "user_1"})
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("Expected 2 calls, got %d", got)
+	}
+
+	output := scrapeMetrics(t, registry)
+	for _, line := range []string{
+		`lifepattern_ai_requests_total{operation="analyze",outcome="server_error"} 2`,
+		`lifepattern_ai_requests_total{operation="analyze",outcome="circuit_open"} 1`,
+		`lifepattern_ai_request_duration_seconds_count{operation="analyze"} 2`,
+		`lifepattern_ai_circuit_state{state="open"} 1`,
+		`lifepattern_ai_circuit_state{state="closed"} 0`,
+		`lifepattern_ai_circuit_consecutive_failures 2`,
+	} {
+		if !strings.Contains(output, line) {
+			t.Fatalf("Expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
+
+func TestMetricsAnalyses(t *testing.T) {
+	registry := metrics.NewRegistry()
+	service := NewRoutineService(NewMockRepository(), NewMockAIService(false), logging.Discard(), NewMetrics(registry))
+
+	for _, logDate := range []string{"2024-01-15", "2024-01-16"} {
+		routineLog := database.RoutineLog{UserID: "user_1", LogDate: logDate}
+		if _, err := service.CreateRoutineLog(context.Background(), routineLog, ConflictReplace); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	output := scrapeMetrics(t, registry)
+	line := `lifepattern_routine_analyses_total{anomaly_type="test_anomaly",is_anomaly="true",source="ai_service",model_version="1.0.0"} 2`
+	if !strings.Contains(output, line) {
+		t.Fatalf("Expected output to contain %q, got:\n%s", line, output)
+	}
+}
+
+func TestMetricsAnalysesOfUpdatesAndRetries(t *testing.T) {
+	registry := metrics.NewRegistry()
+	serviceMetrics := NewMetrics(registry)
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), serviceMetrics)
+	line := `lifepattern_routine_analyses_total{anomaly_type="test_anomaly",is_anomaly="true",source="ai_service",model_version="1.0.0"} `
+
+	created, err := service.CreateRoutineLog(context.Background(), healthyLog(), ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A PUT re-analyzes the log and is counted like the first analysis
+	updated := mockRepo.routineLogs[created.LogID]
+	updated.StressLevel = 9
+	if _, err := service.UpdateRoutineLog(context.Background(), "user_1", updated); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if output := scrapeMetrics(t, registry); !strings.Contains(output, line+"2") {
+		t.Fatalf("Expected output to contain %q, got:\n%s", line+"2", output)
+	}
+
+	// So is an analysis retried by the worker
+	worker := NewAnalysisWorker(mockRepo, NewMockAIService(false), AnalysisWorkerConfig{}, logging.Discard(), serviceMetrics)
+	mockRepo.EnqueueAnalysis(context.Background(), created.LogID)
+	if !worker.processNext() {
+		t.Fatal("Expected a job to be processed")
+	}
+
+	if output := scrapeMetrics(t, registry); !strings.Contains(output, line+"3") {
+		t.Fatalf("Expected output to contain %q, got:\n%s", line+"3", output)
+	}
+}
+
+func TestMetricsQueue(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockRepo.EnqueueAnalysis(context.Background(), 1)
+	mockRepo.EnqueueAnalysis(context.Background(), 2)
+
+	registry := metrics.NewRegistry()
+	RegisterQueueMetrics(registry, newTestWorker(mockRepo, NewMockAIService(false)), logging.Discard())
+
+	output := scrapeMetrics(t, registry)
+	for _, line := range []string{
+		`lifepattern_analysis_queue_jobs{state="all"} 2`,
+		`lifepattern_analysis_queue_oldest_job_age_seconds 0`,
+	} {
+		if !strings.Contains(output, line) {
+			t.Fatalf("Expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
+
+func TestAICallOutcome(t *testing.T) {
+	cases := map[error]string{
+		nil:                                    aiOutcomeSuccess,
+		ErrCircuitOpen:                         aiOutcomeCircuitOpen,
+		context.Canceled:                       aiOutcomeCanceled,
+		context.DeadlineExceeded:               aiOutcomeTimeout,
+		&AIServiceStatusError{StatusCode: 502}: aiOutcomeServerError,
+		&AIServiceStatusError{StatusCode: 422}: aiOutcomeClientError,
+		errors.New("connection refused"):       aiOutcomeConnectionError,
+	}
+	for err, expected := range cases {
+		wrapped := err
+		if err != nil {
+			wrapped = fmt.Errorf("failed to call AI service: %w", err)
+		}
+		if outcome := aiCallOutcome(wrapped); outcome != expected {
+			t.Fatalf("Expected outcome %s for %v, got %s", expected, err, outcome)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"lifepattern-api/internal/database"
+)
+
+const (
+	// DefaultPageSize is used when a request does not specify a limit
+	DefaultPageSize = 10
+
+	// MaxPageSize is the largest page any list endpoint returns
+	MaxPageSize = 100
+)
+
+// LogQuery selects a page of a user's routine logs or insights, newest log_date first
+type LogQuery struct {
+	UserID string
+	From   string // inclusive lower log_date bound (YYYY-MM-DD), optional
+	To     string // inclusive upper log_date bound (YYYY-MM-DD), optional
+	Cursor string // next_cursor of the previous page, optional
+	// ModelVersion keeps only logs whose latest AI report came from this model version, optional
+	ModelVersion string
+	Limit        int
+}
+
+// RoutineLogPage is one page of routine logs
+type RoutineLogPage struct {
+	Logs       []database.RoutineLog
+	NextCursor string // empty on the last page
+}
+
+// InsightPage is one page of insights
+type InsightPage struct {
+	Insights   []database.InsightResponse
+	NextCursor string // empty on the last page
+}
+
+// pageSize clamps a requested limit to [1, MaxPageSize]
+func (q LogQuery) pageSize() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultPageSize
+	case q.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return q.Limit
+	}
+}
+
+// filter converts the query into a repository filter that fetches one extra
+// row, so the caller can tell whether another page follows
+func (q LogQuery) filter() (database.LogFilter, error) {
+	filter := database.LogFilter{
+		UserID:       q.UserID,
+		From:         q.From,
+		To:           q.To,
+		ModelVersion: q.ModelVersion,
+		Limit:        q.pageSize() + 1,
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = cursor
+	}
+
+	return filter, nil
+}
+
+// encodeCursor returns the opaque next_cursor for the position of routineLog
+func encodeCursor(routineLog database.RoutineLog) string {
+	raw := routineLog.LogDate + "|" + strconv.Itoa(routineLog.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor produced by encodeCursor
+func decodeCursor(cursor string) (*database.LogCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	logDate, idStr, found := strings.Cut(string(raw), "|")
+	if !found || !isISODate(logDate) {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &database.LogCursor{LogDate: logDate, ID: id}, nil
+}
+
+// isISODate reports whether value is a valid YYYY-MM-DD date
+func isISODate(value string) bool {
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
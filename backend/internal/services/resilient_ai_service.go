@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"lifepattern-api/internal/database"
+)
+
+// AIResilienceConfig tunes the retry policy and circuit breaker around the AI service
+type AIResilienceConfig struct {
+	// MaxRetries is the number of retries after the first failed attempt
+	MaxRetries int
+	// RetryBaseDelay is the upper bound of the first retry delay; it doubles with every retry
+	RetryBaseDelay time.Duration
+	// RetryMaxDelay caps the retry delay
+	RetryMaxDelay time.Duration
+	// Breaker configures when calls stop reaching the AI service
+	Breaker CircuitBreakerConfig
+}
+
+// ResilientAIService wraps an AI service with retries and a circuit breaker
+// Connection errors and 5xx responses are retried with jittered exponential backoff;
+// once the breaker opens, calls fail immediately with ErrCircuitOpen so a dead
+// AI service costs milliseconds per request instead of a full timeout
+type ResilientAIService struct {
+	aiService AIServiceInterface
+	breaker   *CircuitBreaker
+	config    AIResilienceConfig
+	logger    *slog.Logger
+	metrics   *Metrics
+	sleep     func(ctx context.Context, d time.Duration) error
+}
+
+func NewResilientAIService(aiService AIServiceInterface, config AIResilienceConfig, logger *slog.Logger, metrics *Metrics) *ResilientAIService {
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+
+	return &ResilientAIService{
+		aiService: aiService,
+		breaker:   NewCircuitBreaker(config.Breaker),
+		config:    config,
+		logger:    logger,
+		metrics:   metrics,
+		sleep:     sleepContext,
+	}
+}
+
+// AnalyzeRoutine calls the AI service, retrying transient failures while the circuit allows it
+// A cancelled ctx stops the retries and is not counted as an AI service failure
+func (s *ResilientAIService) AnalyzeRoutine(ctx context.Context, routineLog database.RoutineLog) (*AIServiceResponse, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= s.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := s.retryDelay(attempt)
+			s.logger.WarnContext(ctx, "retrying AI analysis",
+				"delay", delay,
+				"retry", attempt,
+				"max_retries", s.config.MaxRetries,
+				"error", lastErr)
+			if err := s.sleep(ctx, delay); err != nil {
+				return nil, fmt.Errorf("AI analysis aborted: %w", err)
+			}
+		}
+
+		if err := s.breaker.Allow(); err != nil {
+			s.metrics.observeRejectedAICall("analyze")
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w (last error: %v)", err, lastErr)
+			}
+			s.logger.WarnContext(ctx, "AI service circuit is open, skipping analysis")
+			return nil, err
+		}
+
+		start := time.Now()
+		aiResponse, err := s.aiService.AnalyzeRoutine(ctx, routineLog)
+		s.metrics.observeAICall("analyze", time.Since(start), err)
+		if err == nil {
+			s.breaker.Success()
+			return aiResponse, nil
+		}
+
+		if ctx.Err() != nil {
+			// The caller gave up; this says nothing about the AI service
+			s.breaker.Abandon()
+			return nil, err
+		}
+
+		if !isRetryableAIError(err) {
+			// The AI service answered, so it is up; the request itself was rejected
+			s.breaker.Success()
+			return nil, err
+		}
+
+		s.breaker.Failure()
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// CheckHealth probes the AI service directly, bypassing retries and the breaker
+func (s *ResilientAIService) CheckHealth(ctx context.Context) error {
+	start := time.Now()
+	err := s.aiService.CheckHealth(ctx)
+	s.metrics.observeAICall("health", time.Since(start), err)
+	return err
+}
+
+// CircuitState reports the state of the AI service circuit breaker
+func (s *ResilientAIService) CircuitState() CircuitSnapshot {
+	return s.breaker.Snapshot()
+}
+
+// retryDelay returns a random delay in [0, min(RetryMaxDelay, RetryBaseDelay*2^(retry-1))]
+// Full jitter keeps concurrent requests from retrying in lockstep
+func (s *ResilientAIService) retryDelay(retry int) time.Duration {
+	ceiling := s.config.RetryBaseDelay
+	for i := 1; i < retry && ceiling < s.config.RetryMaxDelay; i++ {
+		ceiling *= 2
+	}
+	if ceiling > s.config.RetryMaxDelay {
+		ceiling = s.config.RetryMaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// sleepContext waits for d or until ctx is done, returning ctx.Err() in the latter case
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isRetryableAIError reports whether err is a connection error, a timeout or a 5xx response
+func isRetryableAIError(err error) bool {
+	var statusErr *AIServiceStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
+)
+
+// newTestResilientService wraps an AI service client for url without sleeping between retries
+func newTestResilientService(url string, maxRetries int, threshold int) *ResilientAIService {
+	service := NewResilientAIService(NewAIService(url, time.Second, logging.Discard()), AIResilienceConfig{
+		MaxRetries:     maxRetries,
+		RetryBaseDelay: 10 * time.Millisecond,
+		RetryMaxDelay:  50 * time.Millisecond,
+		Breaker: CircuitBreakerConfig{
+			FailureThreshold: threshold,
+			OpenTimeout:      time.Minute,
+		},
+	}, logging.Discard(), nil)
+	service.sleep = func(context.Context, time.Duration) error { return nil }
+	return service
+}
+
+// newCountingAIServer returns a server that answers with the given statuses in order,
+// then 200, and counts the calls it receives
+func newCountingAIServer(calls *int32, statuses ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(atomic.AddInt32(calls, 1))
+		if call <= len(statuses) {
+			w.WriteHeader(statuses[call-1])
+			w.Write([]byte(`{"detail": "failure"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AIServiceResponse{
+			IsAnomaly:       false,
+			ConfidenceScore: 0.9,
+			AnomalyType:     "normal",
+		})
+	}))
+}
+
+func TestResilientAIServiceRetriesServerErrors(t *testing.T) {
+	var calls int32
+	server := newCountingAIServer(&calls, http.StatusServiceUnavailable, http.StatusInternalServerError)
+	defer server.Close()
+
+	service := newTestResilientService(server.URL, 2, 5)
+
+	response, err := service.AnalyzeRoutine(context.Background(), database.RoutineLog{UserID: "user_1"})
+	if err != nil {
+		t.Fatalf("Expected retries to succeed, got %v", err)
+	}
+	if response.AnomalyType != "normal" {
+		t.Fatalf("Expected anomaly type normal, got %s", response.AnomalyType)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("Expected 3 calls, got %d", got)
+	}
+	if state := service.CircuitState().State; state != CircuitClosed {
+		t.Fatalf("Expected closed circuit after a success, got %s", state)
+	}
+}
+
+func TestResilientAIServiceDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	server := newCountingAIServer(&calls, http.StatusUnprocessableEntity)
+	defer server.Close()
+
+	service := newTestResilientService(server.URL, 2, 1)
+
+	_, err := service.AnalyzeRoutine(context.Background(), database.RoutineLog{UserID: "user_1"})
+	var statusErr *AIServiceStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422 status error, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("Expected 1 call, got %d", got)
+	}
+	if state := service.CircuitState().State; state != CircuitClosed {
+		t.Fatalf("Expected a 4xx response to keep the circuit closed, got %s", state)
+	}
+}
+
+func TestResilientAIServiceFailsFastWhenOpen(t *testing.T) {
+	var calls int32
+	server := newCountingAIServer(&calls, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	defer server.Close()
+
+	service := newTestResilientService(server.URL, 5, 2)
+
+	// The breaker opens after the second failure and stops the retries
+	_, err := service.AnalyzeRoutine(context.Background(), database.RoutineLog{UserID: "user_1"})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("Expected 2 calls before the circuit opened, got %d", got)
+	}
+
+	_, err = service.AnalyzeRoutine(context.Background(), database.RoutineLog{UserID: "user_1"})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("Expected no call while the circuit is open, got %d calls", got)
+	}
+	if state := service.CircuitState().State; state != CircuitOpen {
+		t.Fatalf("Expected open circuit, got %s", state)
+	}
+}
+
+func TestResilientAIServiceRetriesConnectionErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	service := newTestResilientService(url, 2, 10)
+
+	_, err := service.AnalyzeRoutine(context.Background(), database.RoutineLog{UserID: "user_1"})
+	if err == nil {
+		t.Fatal("Expected error for connection failure")
+	}
+	if failures := service.CircuitState().ConsecutiveFailures; failures != 3 {
+		t.Fatalf("Expected 3 failed attempts, got %d", failures)
+	}
+}
+
+func TestResilientAIServiceRetryDelay(t *testing.T) {
+	service := NewResilientAIService(nil, AIResilienceConfig{
+		RetryBaseDelay: 100 * time.Millisecond,
+		RetryMaxDelay:  300 * time.Millisecond,
+	}, logging.Discard(), nil)
+
+	for retry, ceiling := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		5: 300 * time.Millisecond,
+	} {
+		for i := 0; i < 20; i++ {
+			if delay := service.retryDelay(retry); delay < 0 || delay > ceiling {
+				t.Fatalf("Expected retry %d delay within [0, %s], got %s", retry, ceiling, delay)
+			}
+		}
+	}
+}
+
+func TestResilientAIServiceCancelledContext(t *testing.T) {
+	server, aborted := newBlockingAIServer()
+	defer server.Close()
+
+	service := newTestResilientService(server.URL, 3, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := service.AnalyzeRoutine(ctx, database.RoutineLog{UserID: "user_1"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the AI service to see the request aborted")
+	}
+
+	// A caller giving up is neither retried nor held against the AI service
+	snapshot := service.CircuitState()
+	if snapshot.State != CircuitClosed || snapshot.ConsecutiveFailures != 0 {
+		t.Fatalf("Expected closed circuit without failures, got %+v", snapshot)
+	}
+}
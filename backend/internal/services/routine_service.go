@@ -1,172 +1,520 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"lifepattern-api/internal/apperror"
 	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/validation"
 )
 
 type RoutineService struct {
 	repo      RepositoryInterface
 	aiService AIServiceInterface
+	logger    *slog.Logger
+	metrics   *Metrics
 }
 
-func NewRoutineService(repo RepositoryInterface, aiService AIServiceInterface) *RoutineService {
+func NewRoutineService(repo RepositoryInterface, aiService AIServiceInterface, logger *slog.Logger, metrics *Metrics) *RoutineService {
 	return &RoutineService{
 		repo:      repo,
 		aiService: aiService,
+		logger:    logger,
+		metrics:   metrics,
 	}
 }
 
-// CreateRoutineLog creates a new routine log with AI analysis
+// CreateRoutineLog creates or replaces the user's routine log for the day with AI analysis
+// mode decides what happens when a log already exists for routineLog.LogDate
 // This is the main business logic that orchestrates:
 // 1. Frontend -> Backend: Receives routine data
 // 2. Backend -> Database: Saves routine log
 // 3. Backend -> AI Service: Sends data for analysis
 // 4. Backend -> Database: Saves AI analysis results
 // 5. Backend -> Frontend: Returns combined response
-func (s *RoutineService) CreateRoutineLog(routineLog database.RoutineLog) (*CreateRoutineLogResponse, error) {
+func (s *RoutineService) CreateRoutineLog(ctx context.Context, routineLog database.RoutineLog, mode ConflictMode) (*CreateRoutineLogResponse, error) {
 	// Set default values
 	if routineLog.LogDate == "" {
 		routineLog.LogDate = time.Now().Format("2006-01-02")
 	}
-	if routineLog.UserID == 0 {
-		routineLog.UserID = 1 // Default user for testing
+
+	// Logs can only be written for registered users
+	if _, err := s.repo.GetUserByID(ctx, routineLog.UserID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to look up user: %w", err)
 	}
 
-	log.Printf("📝 Creating routine log for user %d on %s", routineLog.UserID, routineLog.LogDate)
+	s.logger.InfoContext(ctx, "creating routine log", "user_id", routineLog.UserID, "log_date", routineLog.LogDate)
 
-	// Step 1: Save routine log to database
-	logID, err := s.repo.SaveRoutineLog(routineLog)
+	// Sleep metrics are always derived here, never taken from the client
+	routineLog.Sleep = computeSleepMetrics(routineLog)
+
+	// Step 1: Save routine log to database (one log per user per day)
+	logID, created, updatedAt, err := s.repo.SaveRoutineLog(ctx, routineLog, mode != ConflictReject)
 	if err != nil {
-		log.Printf("❌ Failed to save routine log: %v", err)
+		if errors.Is(err, database.ErrConflict) {
+			return nil, ErrRoutineLogExists
+		}
+		s.logger.ErrorContext(ctx, "failed to save routine log", "error", err)
 		return nil, fmt.Errorf("failed to save routine log: %w", err)
 	}
 
-	log.Printf("✅ Routine log saved with ID: %d", logID)
+	verb := "saved"
+	if !created {
+		verb = "replaced"
+	}
+	s.logger.InfoContext(ctx, "routine log "+verb, "log_id", logID)
+
+	routineLog.ID = logID
+	routineLog.UpdatedAt = updatedAt
+	return s.analyzeSavedLog(ctx, routineLog, created, verb), nil
+}
+
+// MergeRoutineLog overlays the fields present in patch, a routine log JSON object, onto the
+// user's stored log for logDate and re-runs AI analysis; without a stored log it creates one
+// The merged log is validated as a whole, and the read, overlay and write happen in one
+// transaction, so concurrent merges of the same day keep each other's fields
+func (s *RoutineService) MergeRoutineLog(ctx context.Context, userID string, logDate string, patch []byte) (*CreateRoutineLogResponse, error) {
+	if _, err := s.repo.GetUserByID(ctx, userID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "merging routine log", "user_id", userID, "log_date", logDate)
+
+	merged, created, err := s.repo.MergeRoutineLog(ctx, userID, logDate, func(routineLog *database.RoutineLog) error {
+		if err := json.Unmarshal(patch, routineLog); err != nil {
+			return apperror.ErrInvalidJSON
+		}
+		routineLog.UserID = userID
+		routineLog.LogDate = logDate
+
+		if err := validation.RoutineLog(*routineLog, time.Now()); err != nil {
+			return err
+		}
+		routineLog.Sleep = computeSleepMetrics(*routineLog)
+		return nil
+	})
+	if err != nil {
+		var appErr *apperror.Error
+		if errors.As(err, &appErr) {
+			return nil, err
+		}
+		s.logger.ErrorContext(ctx, "failed to merge routine log", "error", err)
+		return nil, fmt.Errorf("failed to merge routine log: %w", err)
+	}
+
+	verb := "saved"
+	if !created {
+		verb = "merged"
+	}
+	s.logger.InfoContext(ctx, "routine log "+verb, "log_id", merged.ID)
+
+	return s.analyzeSavedLog(ctx, *merged, created, verb), nil
+}
+
+// analyzeSavedLog runs AI analysis for a routine log that was just saved and stores its report
+// verb describes how the log was saved in the response message
+func (s *RoutineService) analyzeSavedLog(ctx context.Context, routineLog database.RoutineLog, created bool, verb string) *CreateRoutineLogResponse {
+	logID := routineLog.ID
 
 	// Step 2: Call AI service for analysis
-	log.Printf("🤖 Calling AI service for analysis...")
-	aiResponse, err := s.aiService.AnalyzeRoutine(routineLog)
+	aiResponse, err := s.aiService.AnalyzeRoutine(ctx, routineLog)
 	if err != nil {
-		log.Printf("⚠️  AI analysis failed: %v", err)
-		log.Printf("📊 Continuing without AI analysis - routine log saved successfully")
+		s.logger.WarnContext(ctx, "AI analysis failed, continuing without it", "log_id", logID, "error", err)
 
-		// Return success without AI analysis (graceful degradation)
+		// Return success without AI analysis (graceful degradation);
+		// the analysis worker retries it in the background
 		return &CreateRoutineLogResponse{
-			LogID:   logID,
-			Message: "Routine log saved (AI analysis temporarily unavailable)",
-			HasAI:   false,
-		}, nil
+			LogID:          logID,
+			Created:        created,
+			Message:        "Routine log " + verb + " (AI analysis temporarily unavailable)",
+			HasAI:          false,
+			AnalysisQueued: s.enqueueAnalysis(ctx, logID),
+		}
 	}
 
-	log.Printf("✅ AI analysis completed - Anomaly: %v, Confidence: %.2f",
-		aiResponse.IsAnomaly, aiResponse.ConfidenceScore)
+	s.logger.InfoContext(ctx, "AI analysis completed",
+		"log_id", logID,
+		"is_anomaly", aiResponse.IsAnomaly,
+		"confidence_score", aiResponse.ConfidenceScore)
+	s.metrics.observeAnalysis(aiResponse)
+
+	// Step 3: Save AI report to database, replacing the report of an overwritten day
+	// The AI call is slow; a day overwritten again meanwhile gets the report of its newer data
+	report := newAIReport(logID, aiResponse)
+	saved := true
+	if created {
+		err = s.repo.SaveAIReport(ctx, report)
+	} else {
+		saved, err = s.repo.ReplaceAIReportIfUnchanged(ctx, report, routineLog.UpdatedAt)
+	}
+	queued := false
+	switch {
+	case err != nil:
+		s.logger.WarnContext(ctx, "failed to save AI report", "log_id", logID, "error", err)
+		// Continue even if AI report save fails - the routine log is still saved
+		queued = s.enqueueAnalysis(ctx, logID)
+	case !saved:
+		s.logger.InfoContext(ctx, "routine log changed during analysis, dropping report", "log_id", logID)
+	default:
+		s.logger.InfoContext(ctx, "AI report saved", "log_id", logID, "source", aiResponse.Source)
+	}
+
+	// A fallback analysis is replaced once the primary analyzer is back
+	message := "Routine log " + verb + " and analyzed successfully"
+	if aiResponse.Fallback {
+		message = "Routine log " + verb + " and analyzed by a fallback analyzer (primary analysis temporarily unavailable)"
+		queued = s.enqueueAnalysis(ctx, logID) || queued
+	}
 
-	// Step 3: Save AI report to database
+	// Step 4: Return combined response to frontend
+	return &CreateRoutineLogResponse{
+		LogID:          logID,
+		Created:        created,
+		Message:        message,
+		HasAI:          true,
+		AIResult:       newAIResult(aiResponse),
+		AnalysisQueued: queued,
+	}
+}
+
+// GetRoutineLog retrieves a single routine log owned by userID
+// Another user's log is ErrRoutineLogNotFound, so log IDs cannot be probed
+func (s *RoutineService) GetRoutineLog(ctx context.Context, userID string, logID int) (*database.RoutineLog, error) {
+	routineLog, err := s.repo.GetRoutineLog(ctx, logID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrRoutineLogNotFound
+		}
+		return nil, fmt.Errorf("failed to get routine log: %w", err)
+	}
+
+	if routineLog.UserID != userID {
+		return nil, ErrRoutineLogNotFound
+	}
+
+	return routineLog, nil
+}
+
+// UpdateRoutineLog overwrites a routine log owned by userID and re-runs AI analysis
+// The previous AI report is dropped together with the old data, so insights never show
+// a report of data that has since been corrected, even while re-analysis is pending.
+// Ownership is checked by the update itself, so another user's log is ErrRoutineLogNotFound
+func (s *RoutineService) UpdateRoutineLog(ctx context.Context, userID string, routineLog database.RoutineLog) (*CreateRoutineLogResponse, error) {
+	routineLog.UserID = userID
+
+	s.logger.InfoContext(ctx, "updating routine log", "log_id", routineLog.ID, "user_id", userID)
+
+	routineLog.Sleep = computeSleepMetrics(routineLog)
+
+	updated, err := s.repo.UpdateRoutineLog(ctx, routineLog)
+	if err != nil {
+		return nil, s.updateError(ctx, routineLog.ID, err)
+	}
+
+	return s.reanalyzeUpdatedLog(ctx, updated), nil
+}
+
+// PatchRoutineLog overlays the fields present in patch, a routine log JSON object, onto a
+// routine log owned by userID and re-runs AI analysis like UpdateRoutineLog
+// The patched log is validated as a whole, and the read, overlay and write happen in one
+// transaction, so concurrent patches of the same log keep each other's fields
+func (s *RoutineService) PatchRoutineLog(ctx context.Context, userID string, logID int, patch []byte) (*CreateRoutineLogResponse, error) {
+	s.logger.InfoContext(ctx, "patching routine log", "log_id", logID, "user_id", userID)
+
+	updated, err := s.repo.PatchRoutineLog(ctx, userID, logID, func(routineLog *database.RoutineLog) error {
+		if err := json.Unmarshal(patch, routineLog); err != nil {
+			return apperror.ErrInvalidJSON
+		}
+
+		// A log cannot be moved to another user
+		if routineLog.UserID != userID {
+			return ErrForbidden
+		}
+		routineLog.ID = logID
+
+		if err := validation.RoutineLog(*routineLog, time.Now()); err != nil {
+			return err
+		}
+		routineLog.Sleep = computeSleepMetrics(*routineLog)
+		return nil
+	})
+	if err != nil {
+		var appErr *apperror.Error
+		if errors.As(err, &appErr) {
+			return nil, err
+		}
+		return nil, s.updateError(ctx, logID, err)
+	}
+
+	return s.reanalyzeUpdatedLog(ctx, updated), nil
+}
+
+// updateError maps a repository error from updating a routine log to the error reported for it
+func (s *RoutineService) updateError(ctx context.Context, logID int, err error) error {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return ErrRoutineLogNotFound
+	case errors.Is(err, database.ErrConflict):
+		return ErrRoutineLogExists
+	}
+	s.logger.ErrorContext(ctx, "failed to update routine log", "log_id", logID, "error", err)
+	return fmt.Errorf("failed to update routine log: %w", err)
+}
+
+// reanalyzeUpdatedLog re-runs AI analysis for a routine log that was just updated and stores its report
+func (s *RoutineService) reanalyzeUpdatedLog(ctx context.Context, updated *database.RoutineLog) *CreateRoutineLogResponse {
+	// Re-run AI analysis on the corrected data
+	aiResponse, err := s.aiService.AnalyzeRoutine(ctx, *updated)
+	if err != nil {
+		s.logger.WarnContext(ctx, "AI re-analysis failed", "log_id", updated.ID, "error", err)
+		return &CreateRoutineLogResponse{
+			LogID:          updated.ID,
+			Message:        "Routine log updated (AI analysis temporarily unavailable)",
+			HasAI:          false,
+			AnalysisQueued: s.enqueueAnalysis(ctx, updated.ID),
+		}
+	}
+	s.metrics.observeAnalysis(aiResponse)
+
+	// The AI call is slow; a log updated again meanwhile gets the report of its newer data
+	queued := false
+	saved, err := s.repo.ReplaceAIReportIfUnchanged(ctx, newAIReport(updated.ID, aiResponse), updated.UpdatedAt)
+	switch {
+	case err != nil:
+		s.logger.WarnContext(ctx, "failed to replace AI report", "log_id", updated.ID, "error", err)
+		queued = s.enqueueAnalysis(ctx, updated.ID)
+	case !saved:
+		s.logger.InfoContext(ctx, "routine log changed during analysis, dropping report", "log_id", updated.ID)
+	default:
+		s.logger.InfoContext(ctx, "routine log updated and re-analyzed", "log_id", updated.ID, "source", aiResponse.Source)
+	}
+
+	message := "Routine log updated and re-analyzed successfully"
+	if aiResponse.Fallback {
+		message = "Routine log updated and analyzed by a fallback analyzer (primary analysis temporarily unavailable)"
+		queued = s.enqueueAnalysis(ctx, updated.ID) || queued
+	}
+
+	return &CreateRoutineLogResponse{
+		LogID:          updated.ID,
+		Message:        message,
+		HasAI:          true,
+		AIResult:       newAIResult(aiResponse),
+		AnalysisQueued: queued,
+	}
+}
+
+// DeleteRoutineLog deletes a routine log owned by userID together with its AI report
+// Another user's log is ErrRoutineLogNotFound, like a missing one
+func (s *RoutineService) DeleteRoutineLog(ctx context.Context, userID string, logID int) error {
+	s.logger.InfoContext(ctx, "deleting routine log", "log_id", logID, "user_id", userID)
+
+	if err := s.repo.DeleteRoutineLog(ctx, userID, logID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrRoutineLogNotFound
+		}
+		return fmt.Errorf("failed to delete routine log: %w", err)
+	}
+
+	return nil
+}
+
+// enqueueAnalysis queues a log for background analysis and reports whether it was queued
+// A failure is only logged: the worker's periodic sweep picks up logs without a report
+// The job is queued even when ctx is cancelled, since analysis is often aborted by a client
+// that disconnected after its log was saved
+func (s *RoutineService) enqueueAnalysis(ctx context.Context, logID int) bool {
+	if err := s.repo.EnqueueAnalysis(context.WithoutCancel(ctx), logID); err != nil {
+		s.logger.WarnContext(ctx, "failed to queue analysis", "log_id", logID, "error", err)
+		return false
+	}
+
+	s.logger.InfoContext(ctx, "log queued for background analysis", "log_id", logID)
+	return true
+}
+
+// newAIReport converts an AI service response into an AI report for the routine log
+func newAIReport(logID int, aiResponse *AIServiceResponse) database.AIReport {
 	aiResponseJSON, _ := json.Marshal(aiResponse)
-	aiReport := database.AIReport{
+	return database.AIReport{
 		RoutineLogID:      logID,
 		IsAnomaly:         aiResponse.IsAnomaly,
 		ConfidenceScore:   aiResponse.ConfidenceScore,
 		AnomalyType:       aiResponse.AnomalyType,
 		Recommendations:   aiResponse.Recommendations,
 		AIServiceResponse: string(aiResponseJSON),
+		Source:            aiResponse.Source,
+		Baseline:          aiResponse.Baseline,
+		Analyses:          aiResponse.Analyses,
+		ModelName:         aiResponse.ModelName,
+		ModelVersion:      aiResponse.ModelVersion,
+		ModelAccuracy:     aiResponse.ModelAccuracy,
+		FeatureNames:      aiResponse.FeatureNames,
 	}
+}
 
-	if err := s.repo.SaveAIReport(aiReport); err != nil {
-		log.Printf("⚠️  Failed to save AI report: %v", err)
-		// Continue even if AI report save fails - the routine log is still saved
+// newAIResult summarizes an AI service response for the client
+func newAIResult(aiResponse *AIServiceResponse) *AIResult {
+	return &AIResult{
+		IsAnomaly:       aiResponse.IsAnomaly,
+		ConfidenceScore: aiResponse.ConfidenceScore,
+		AnomalyType:     aiResponse.AnomalyType,
+		Source:          aiResponse.Source,
+		Baseline:        aiResponse.Baseline,
+		Analyses:        aiResponse.Analyses,
+		ModelName:       aiResponse.ModelName,
+		ModelVersion:    aiResponse.ModelVersion,
 	}
+}
 
-	log.Printf("✅ AI report saved for log ID: %d", logID)
-
-	// Step 4: Return combined response to frontend
-	return &CreateRoutineLogResponse{
-		LogID:   logID,
-		Message: "Routine log saved and analyzed successfully",
-		HasAI:   true,
-		AIResult: &AIResult{
-			IsAnomaly:       aiResponse.IsAnomaly,
-			ConfidenceScore: aiResponse.ConfidenceScore,
-			AnomalyType:     aiResponse.AnomalyType,
-		},
-	}, nil
-}
-
-// GetInsight retrieves a routine log with its AI analysis
+// GetInsight retrieves a routine log owned by userID with its AI analysis
+// Another user's log is reported as not found, like a missing one
 // Frontend -> Backend: Requests insight for a specific log
 // Backend -> Database: Retrieves routine log and AI report
 // Backend -> Frontend: Returns combined insight data
-func (s *RoutineService) GetInsight(logID int) (*database.InsightResponse, error) {
-	log.Printf("🔍 Retrieving insight for log ID: %d", logID)
+func (s *RoutineService) GetInsight(ctx context.Context, userID string, logID int) (*database.InsightResponse, error) {
+	s.logger.DebugContext(ctx, "retrieving insight", "log_id", logID, "user_id", userID)
 
-	insight, err := s.repo.GetRoutineLogWithAIReport(logID)
+	insight, err := s.repo.GetRoutineLogWithAIReport(ctx, userID, logID)
 	if err != nil {
-		log.Printf("❌ Failed to get insight for log ID %d: %v", logID, err)
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrRoutineLogNotFound
+		}
+		s.logger.ErrorContext(ctx, "failed to get insight", "log_id", logID, "error", err)
 		return nil, fmt.Errorf("failed to get insight: %w", err)
 	}
 
-	log.Printf("✅ Insight retrieved successfully for log ID: %d", logID)
 	return insight, nil
 }
 
-// GetUserRoutineLogs retrieves routine logs for a specific user
+// GetUserRoutineLogs retrieves a page of routine logs for a specific user
 // Frontend -> Backend: Requests user's routine logs
 // Backend -> Database: Retrieves user's routine logs
 // Backend -> Frontend: Returns list of routine logs
-func (s *RoutineService) GetUserRoutineLogs(userID int, limit int) ([]database.RoutineLog, error) {
-	log.Printf("📋 Retrieving routine logs for user %d (limit: %d)", userID, limit)
+func (s *RoutineService) GetUserRoutineLogs(ctx context.Context, query LogQuery) (*RoutineLogPage, error) {
+	s.logger.DebugContext(ctx, "retrieving routine logs", "user_id", query.UserID, "limit", query.pageSize())
 
-	logs, err := s.repo.GetRoutineLogsByUser(userID, limit)
+	logs, nextCursor, err := s.getRoutineLogPage(ctx, query)
 	if err != nil {
-		log.Printf("❌ Failed to get routine logs for user %d: %v", userID, err)
-		return nil, fmt.Errorf("failed to get user routine logs: %w", err)
+		s.logger.ErrorContext(ctx, "failed to get routine logs", "user_id", query.UserID, "error", err)
+		return nil, err
 	}
 
-	log.Printf("✅ Retrieved %d routine logs for user %d", len(logs), userID)
-	return logs, nil
+	s.logger.DebugContext(ctx, "retrieved routine logs", "user_id", query.UserID, "count", len(logs))
+	return &RoutineLogPage{Logs: logs, NextCursor: nextCursor}, nil
 }
 
-// GetUserInsights retrieves insights for all routine logs of a user
+// GetUserInsights retrieves a page of a user's routine logs with their AI analysis
 // This is a convenience method for the frontend to get all insights at once
-func (s *RoutineService) GetUserInsights(userID int, limit int) ([]database.InsightResponse, error) {
-	log.Printf("🔍 Retrieving insights for user %d (limit: %d)", userID, limit)
+// Logs without an AI report are included with a null ai_report
+func (s *RoutineService) GetUserInsights(ctx context.Context, query LogQuery) (*InsightPage, error) {
+	s.logger.DebugContext(ctx, "retrieving insights", "user_id", query.UserID, "limit", query.pageSize())
 
-	// Get user's routine logs
-	routineLogs, err := s.repo.GetRoutineLogsByUser(userID, limit)
+	filter, err := query.filter()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user routine logs: %w", err)
+		return nil, err
 	}
 
-	// Get insights for each log
-	var insights []database.InsightResponse
-	for _, routineLog := range routineLogs {
-		insight, err := s.repo.GetRoutineLogWithAIReport(routineLog.ID)
-		if err != nil {
-			log.Printf("⚠️  Failed to get insight for log %d: %v", routineLog.ID, err)
-			continue // Skip this log but continue with others
-		}
-		insights = append(insights, *insight)
+	// Logs and AI reports are loaded in a single joined query
+	insights, err := s.repo.GetInsightsByUser(ctx, filter)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get insights", "user_id", query.UserID, "error", err)
+		return nil, fmt.Errorf("failed to get user insights: %w", err)
+	}
+
+	// The filter asks for one extra row; its presence means another page follows
+	page := &InsightPage{Insights: insights}
+	if pageSize := query.pageSize(); len(insights) > pageSize {
+		page.Insights = insights[:pageSize]
+		page.NextCursor = encodeCursor(page.Insights[pageSize-1].RoutineLog)
 	}
 
-	log.Printf("✅ Retrieved %d insights for user %d", len(insights), userID)
-	return insights, nil
+	s.logger.DebugContext(ctx, "retrieved insights", "user_id", query.UserID, "count", len(page.Insights))
+	return page, nil
+}
+
+// getRoutineLogPage loads one page of logs and the cursor of the following page
+func (s *RoutineService) getRoutineLogPage(ctx context.Context, query LogQuery) ([]database.RoutineLog, string, error) {
+	filter, err := query.filter()
+	if err != nil {
+		return nil, "", err
+	}
+
+	logs, err := s.repo.GetRoutineLogsByUser(ctx, filter)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get user routine logs: %w", err)
+	}
+
+	// The filter asks for one extra row; its presence means another page follows
+	pageSize := query.pageSize()
+	if len(logs) <= pageSize {
+		return logs, "", nil
+	}
+
+	logs = logs[:pageSize]
+	return logs, encodeCursor(logs[pageSize-1]), nil
+}
+
+// ConflictMode selects what POST /log does when the user already logged the day
+type ConflictMode string
+
+const (
+	// ConflictReject refuses to overwrite an existing log
+	ConflictReject ConflictMode = "reject"
+	// ConflictReplace overwrites the existing log with the submitted one
+	ConflictReplace ConflictMode = "replace"
+	// ConflictMerge overwrites only the fields present in the submission, see MergeRoutineLog
+	ConflictMerge ConflictMode = "merge"
+)
+
+// ParseConflictMode parses a conflict mode, defaulting to ConflictReplace when empty
+func ParseConflictMode(value string) (ConflictMode, error) {
+	switch mode := ConflictMode(value); mode {
+	case "":
+		return ConflictReplace, nil
+	case ConflictReject, ConflictReplace, ConflictMerge:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown conflict mode %q (expected reject, replace or merge)", value)
+	}
 }
 
 // Response types
+
+// CreateRoutineLogResponse is returned when a routine log is created, replaced or updated
 type CreateRoutineLogResponse struct {
 	LogID    int       `json:"log_id"`
+	Created  bool      `json:"created"`
 	Message  string    `json:"message"`
 	HasAI    bool      `json:"has_ai"`
 	AIResult *AIResult `json:"ai_result,omitempty"`
+	// AnalysisQueued is set when analysis failed or fell back to local rules and will be
+	// retried with the AI service in the background
+	AnalysisQueued bool `json:"analysis_queued,omitempty"`
 }
 
 type AIResult struct {
 	IsAnomaly       bool    `json:"is_anomaly"`
 	ConfidenceScore float64 `json:"confidence_score"`
 	AnomalyType     string  `json:"anomaly_type"`
+	// Source names the analyzer whose verdict this is, e.g. "ai_service" or "local_rules"
+	Source string `json:"source"`
+	// Baseline compares the log with the user's own history; omitted when there is too little of it
+	Baseline *database.BaselineReport `json:"baseline,omitempty"`
+	// Analyses lists the answer of every analyzer of the chain
+	Analyses []database.AnalyzerResult `json:"analyses,omitempty"`
+	// ModelName and ModelVersion identify the model behind the verdict; omitted without a trained model
+	ModelName    string `json:"model_name,omitempty"`
+	ModelVersion string `json:"model_version,omitempty"`
 }
@@ -1,70 +1,416 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
 )
 
 // Mock repository for testing
 type MockRepository struct {
 	routineLogs map[int]database.RoutineLog
 	aiReports   map[int]database.AIReport
+	users       map[string]database.User
 	nextID      int
+
+	insightQueries int
+
+	// trendFilter records the last GetRoutineTrends call, which returns trendBuckets
+	trendFilter  database.TrendFilter
+	trendBuckets []database.TrendBucket
+
+	jobs      map[int]*mockJob
+	nextJobID int
+
+	// mu guards the methods the analysis worker calls from its goroutines
+	mu sync.Mutex
 }
 
+// mockJob is an analysis job with its scheduling state
+type mockJob struct {
+	job    database.AnalysisJob
+	runAt  time.Time
+	locked bool
+	failed bool
+}
+
+// NewMockRepository creates a mock repository with user_1 already registered
 func NewMockRepository() *MockRepository {
 	return &MockRepository{
 		routineLogs: make(map[int]database.RoutineLog),
 		aiReports:   make(map[int]database.AIReport),
-		nextID:      1,
+		jobs:        make(map[int]*mockJob),
+		nextJobID:   1,
+		users: map[string]database.User{
+			"user_1": {ID: "user_1", Username: "Swift-Runner-1", DeviceID: "device_1", SecretHash: hashSecret(testUserSecret)},
+		},
+		nextID: 1,
 	}
 }
 
-func (m *MockRepository) SaveRoutineLog(log database.RoutineLog) (int, error) {
+func (m *MockRepository) SaveRoutineLog(ctx context.Context, log database.RoutineLog, overwrite bool) (int, bool, time.Time, error) {
+	if existing, err := m.GetRoutineLogByDate(ctx, log.UserID, log.LogDate); err == nil {
+		if !overwrite {
+			return 0, false, time.Time{}, database.ErrConflict
+		}
+		log.ID = existing.ID
+		log.UpdatedAt = time.Now()
+		m.routineLogs[log.ID] = log
+		delete(m.aiReports, log.ID)
+		return log.ID, false, log.UpdatedAt, nil
+	}
+
 	log.ID = m.nextID
+	log.UpdatedAt = time.Now()
 	m.routineLogs[m.nextID] = log
 	m.nextID++
-	return log.ID, nil
+	return log.ID, true, log.UpdatedAt, nil
 }
 
-func (m *MockRepository) SaveAIReport(report database.AIReport) error {
+func (m *MockRepository) SaveAIReport(ctx context.Context, report database.AIReport) error {
 	m.aiReports[report.RoutineLogID] = report
 	return nil
 }
 
-func (m *MockRepository) GetRoutineLogWithAIReport(logID int) (*database.InsightResponse, error) {
+func (m *MockRepository) GetRoutineLogWithAIReport(ctx context.Context, userID string, logID int) (*database.InsightResponse, error) {
 	log, exists := m.routineLogs[logID]
-	if !exists {
-		return nil, errors.New("routine log not found")
+	if !exists || log.UserID != userID {
+		return nil, fmt.Errorf("failed to get routine log: %w", database.ErrNotFound)
 	}
 
-	aiReport, exists := m.aiReports[logID]
-	if !exists {
-		return nil, errors.New("AI report not found")
+	insight := &database.InsightResponse{RoutineLog: log}
+	if aiReport, exists := m.aiReports[logID]; exists {
+		insight.AIReport = &aiReport
+	}
+	return insight, nil
+}
+
+func (m *MockRepository) GetInsightsByUser(ctx context.Context, filter database.LogFilter) ([]database.InsightResponse, error) {
+	m.insightQueries++
+
+	logs, err := m.GetRoutineLogsByUser(ctx, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	return &database.InsightResponse{
-		RoutineLog: log,
-		AIReport:   aiReport,
-	}, nil
+	var insights []database.InsightResponse
+	for _, log := range logs {
+		insight, _ := m.GetRoutineLogWithAIReport(ctx, log.UserID, log.ID)
+		insights = append(insights, *insight)
+	}
+	return insights, nil
+}
+
+func (m *MockRepository) GetRoutineTrends(ctx context.Context, filter database.TrendFilter) ([]database.TrendBucket, error) {
+	m.trendFilter = filter
+	return m.trendBuckets, nil
 }
 
-func (m *MockRepository) GetRoutineLogsByUser(userID int, limit int) ([]database.RoutineLog, error) {
+func (m *MockRepository) GetRoutineLogsByUser(ctx context.Context, filter database.LogFilter) ([]database.RoutineLog, error) {
 	var logs []database.RoutineLog
-	count := 0
 	for _, log := range m.routineLogs {
-		if log.UserID == userID && count < limit {
-			logs = append(logs, log)
-			count++
+		if log.UserID != filter.UserID {
+			continue
 		}
+		if (filter.From != "" && log.LogDate < filter.From) || (filter.To != "" && log.LogDate > filter.To) {
+			continue
+		}
+		if filter.After != nil && !logBefore(log, filter.After.LogDate, filter.After.ID) {
+			continue
+		}
+		if filter.ModelVersion != "" && m.aiReports[log.ID].ModelVersion != filter.ModelVersion {
+			continue
+		}
+		logs = append(logs, log)
+	}
+
+	sort.Slice(logs, func(i, j int) bool { return logBefore(logs[j], logs[i].LogDate, logs[i].ID) })
+	if len(logs) > filter.Limit {
+		logs = logs[:filter.Limit]
 	}
 	return logs, nil
 }
 
-func (m *MockRepository) Ping() error {
+// logBefore reports whether log sorts before (logDate, id) in (log_date, id) order
+func logBefore(log database.RoutineLog, logDate string, id int) bool {
+	return log.LogDate < logDate || (log.LogDate == logDate && log.ID < id)
+}
+
+func (m *MockRepository) GetRoutineLog(ctx context.Context, logID int) (*database.RoutineLog, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	log, exists := m.routineLogs[logID]
+	if !exists {
+		return nil, database.ErrNotFound
+	}
+	return &log, nil
+}
+
+func (m *MockRepository) GetRoutineLogByDate(ctx context.Context, userID string, logDate string) (*database.RoutineLog, error) {
+	for _, log := range m.routineLogs {
+		if log.UserID == userID && log.LogDate == logDate {
+			return &log, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (m *MockRepository) MergeRoutineLog(ctx context.Context, userID string, logDate string, merge func(*database.RoutineLog) error) (*database.RoutineLog, bool, error) {
+	routineLog := database.RoutineLog{UserID: userID, LogDate: logDate}
+	existing, err := m.GetRoutineLogByDate(ctx, userID, logDate)
+	if err == nil {
+		routineLog = *existing
+	}
+	if err := merge(&routineLog); err != nil {
+		return nil, false, err
+	}
+
+	if existing != nil {
+		routineLog.ID = existing.ID
+		updated, err := m.UpdateRoutineLog(ctx, routineLog)
+		return updated, false, err
+	}
+
+	routineLog.ID, _, _, _ = m.SaveRoutineLog(ctx, routineLog, false)
+	return &routineLog, true, nil
+}
+
+func (m *MockRepository) PatchRoutineLog(ctx context.Context, userID string, logID int, patch func(*database.RoutineLog) error) (*database.RoutineLog, error) {
+	existing, exists := m.routineLogs[logID]
+	if !exists || existing.UserID != userID {
+		return nil, database.ErrNotFound
+	}
+	if err := patch(&existing); err != nil {
+		return nil, err
+	}
+	existing.ID = logID
+	existing.UserID = userID
+	return m.UpdateRoutineLog(ctx, existing)
+}
+
+func (m *MockRepository) UpdateRoutineLog(ctx context.Context, log database.RoutineLog) (*database.RoutineLog, error) {
+	existing, exists := m.routineLogs[log.ID]
+	if !exists || existing.UserID != log.UserID {
+		return nil, database.ErrNotFound
+	}
+	log.UpdatedAt = time.Now()
+	m.routineLogs[log.ID] = log
+	delete(m.aiReports, log.ID)
+	return &log, nil
+}
+
+func (m *MockRepository) DeleteRoutineLog(ctx context.Context, userID string, logID int) error {
+	if existing, exists := m.routineLogs[logID]; !exists || existing.UserID != userID {
+		return database.ErrNotFound
+	}
+	delete(m.routineLogs, logID)
+	delete(m.aiReports, logID)
+	return nil
+}
+
+func (m *MockRepository) ReplaceAIReport(ctx context.Context, report database.AIReport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aiReports[report.RoutineLogID] = report
+	return nil
+}
+
+func (m *MockRepository) ReplaceAIReportIfUnchanged(ctx context.Context, report database.AIReport, updatedAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	log, exists := m.routineLogs[report.RoutineLogID]
+	if !exists || !log.UpdatedAt.Equal(updatedAt) {
+		return false, nil
+	}
+	m.aiReports[report.RoutineLogID] = report
+	return true, nil
+}
+
+func (m *MockRepository) EnqueueAnalysis(ctx context.Context, logID int) error {
+	// Like lib/pq, refuse to run a statement for a cancelled context
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enqueue(logID)
+	return nil
+}
+
+func (m *MockRepository) enqueue(logID int) {
+	for _, queued := range m.jobs {
+		if queued.job.RoutineLogID == logID {
+			queued.job.Version++
+			queued.job.Attempts = 0
+			queued.job.LastError = ""
+			queued.runAt = time.Now()
+			queued.failed = false
+			return
+		}
+	}
+	m.jobs[m.nextJobID] = &mockJob{
+		job:   database.AnalysisJob{ID: m.nextJobID, RoutineLogID: logID, Version: 1, CreatedAt: time.Now()},
+		runAt: time.Now(),
+	}
+	m.nextJobID++
+}
+
+func (m *MockRepository) EnqueueUnanalyzedLogs(ctx context.Context, minAge time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.jobs)
+	for id := range m.routineLogs {
+		if _, analyzed := m.aiReports[id]; !analyzed && !m.hasJob(id) {
+			m.enqueue(id)
+		}
+	}
+	return len(m.jobs) - before, nil
+}
+
+func (m *MockRepository) ClaimAnalysisJobs(ctx context.Context, limit int, lease time.Duration) ([]database.AnalysisJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []database.AnalysisJob
+	for id := 1; id < m.nextJobID && len(jobs) < limit; id++ {
+		queued, exists := m.jobs[id]
+		if !exists || queued.locked || queued.failed || queued.runAt.After(time.Now()) {
+			continue
+		}
+		queued.locked = true
+		queued.job.Attempts++
+		jobs = append(jobs, queued.job)
+	}
+	return jobs, nil
+}
+
+func (m *MockRepository) CompleteAnalysisJob(ctx context.Context, jobID int, version int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	queued, exists := m.jobs[jobID]
+	if !exists {
+		return false, nil
+	}
+	if queued.job.Version != version {
+		queued.locked = false
+		return false, nil
+	}
+	delete(m.jobs, jobID)
+	return true, nil
+}
+
+func (m *MockRepository) RetryAnalysisJob(ctx context.Context, jobID int, delay time.Duration, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if queued, exists := m.jobs[jobID]; exists {
+		queued.locked = false
+		queued.runAt = time.Now().Add(delay)
+		queued.job.LastError = lastError
+	}
+	return nil
+}
+
+func (m *MockRepository) FailAnalysisJob(ctx context.Context, jobID int, version int, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if queued, exists := m.jobs[jobID]; exists {
+		queued.locked = false
+		queued.failed = queued.job.Version == version
+		queued.job.LastError = lastError
+	}
+	return nil
+}
+
+func (m *MockRepository) GetAnalysisQueueStats(ctx context.Context) (*database.AnalysisQueueStats, error) {
+	return m.GetUserAnalysisQueueStats(ctx, "")
+}
+
+// GetUserAnalysisQueueStats counts the jobs of userID's logs, or all jobs for an empty userID
+func (m *MockRepository) GetUserAnalysisQueueStats(ctx context.Context, userID string) (*database.AnalysisQueueStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := &database.AnalysisQueueStats{}
+	for _, queued := range m.jobs {
+		if userID != "" && m.routineLogs[queued.job.RoutineLogID].UserID != userID {
+			continue
+		}
+		if queued.failed {
+			stats.Failed++
+		} else {
+			stats.Depth++
+		}
+	}
+	return stats, nil
+}
+
+// hasJob reports whether logID has a job, pending or failed
+func (m *MockRepository) hasJob(logID int) bool {
+	for _, queued := range m.jobs {
+		if queued.job.RoutineLogID == logID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MockRepository) CreateUser(ctx context.Context, user database.User) (*database.User, error) {
+	if _, exists := m.users[user.ID]; exists {
+		return nil, database.ErrConflict
+	}
+	for _, existing := range m.users {
+		if existing.Username == user.Username || existing.DeviceID == user.DeviceID {
+			return nil, database.ErrConflict
+		}
+	}
+	m.users[user.ID] = user
+	return &user, nil
+}
+
+func (m *MockRepository) GetUserByID(ctx context.Context, userID string) (*database.User, error) {
+	user, exists := m.users[userID]
+	if !exists {
+		return nil, database.ErrNotFound
+	}
+	return &user, nil
+}
+
+func (m *MockRepository) UpdateUsername(ctx context.Context, userID string, username string) (*database.User, error) {
+	user, exists := m.users[userID]
+	if !exists {
+		return nil, database.ErrNotFound
+	}
+	for id, existing := range m.users {
+		if id != userID && existing.Username == username {
+			return nil, database.ErrConflict
+		}
+	}
+	user.Username = username
+	m.users[userID] = user
+	return &user, nil
+}
+
+func (m *MockRepository) ClaimUserSecret(ctx context.Context, userID string, deviceID string, secretHash string) (*database.User, error) {
+	user, exists := m.users[userID]
+	if !exists || user.DeviceID != deviceID || user.SecretHash != "" {
+		return nil, database.ErrNotFound
+	}
+	user.SecretHash = secretHash
+	m.users[userID] = user
+	return &user, nil
+}
+
+func (m *MockRepository) Ping(ctx context.Context) error {
 	return nil
 }
 
@@ -87,18 +433,21 @@ func NewMockAIService(shouldFail bool) *MockAIService {
 			AnomalyType:     "test_anomaly",
 			Recommendations: []string{"Test recommendation"},
 			Timestamp:       time.Now().Format(time.RFC3339),
+			ModelName:       "routine_random_forest",
+			ModelVersion:    "1.0.0",
+			Source:          SourceAIService,
 		},
 	}
 }
 
-func (m *MockAIService) AnalyzeRoutine(routineLog database.RoutineLog) (*AIServiceResponse, error) {
+func (m *MockAIService) AnalyzeRoutine(ctx context.Context, routineLog database.RoutineLog) (*AIServiceResponse, error) {
 	if m.shouldFail {
 		return nil, errors.New("AI service error")
 	}
 	return m.response, nil
 }
 
-func (m *MockAIService) CheckHealth() error {
+func (m *MockAIService) CheckHealth(ctx context.Context) error {
 	if m.shouldFail {
 		return errors.New("AI service unhealthy")
 	}
@@ -109,7 +458,7 @@ func TestNewRoutineService(t *testing.T) {
 	mockRepo := NewMockRepository()
 	mockAI := NewMockAIService(false)
 
-	service := NewRoutineService(mockRepo, mockAI)
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
 	if service == nil {
 		t.Fatal("Expected service to be created")
 	}
@@ -126,10 +475,10 @@ func TestNewRoutineService(t *testing.T) {
 func TestCreateRoutineLog(t *testing.T) {
 	mockRepo := NewMockRepository()
 	mockAI := NewMockAIService(false)
-	service := NewRoutineService(mockRepo, mockAI)
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
 
 	routineLog := database.RoutineLog{
-		UserID:           1,
+		UserID:           "user_1",
 		SleepHours:       8.0,
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -141,7 +490,7 @@ func TestCreateRoutineLog(t *testing.T) {
 		LogDate:          "2024-01-15",
 	}
 
-	response, err := service.CreateRoutineLog(routineLog)
+	response, err := service.CreateRoutineLog(context.Background(), routineLog, ConflictReplace)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -170,9 +519,10 @@ func TestCreateRoutineLog(t *testing.T) {
 func TestCreateRoutineLogWithDefaultValues(t *testing.T) {
 	mockRepo := NewMockRepository()
 	mockAI := NewMockAIService(false)
-	service := NewRoutineService(mockRepo, mockAI)
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
 
 	routineLog := database.RoutineLog{
+		UserID:           "user_1",
 		SleepHours:       8.0,
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -181,10 +531,10 @@ func TestCreateRoutineLogWithDefaultValues(t *testing.T) {
 		BedTime:          "23:00",
 		WaterIntake:      2.5,
 		StressLevel:      4,
-		// LogDate and UserID not set
+		// LogDate not set
 	}
 
-	response, err := service.CreateRoutineLog(routineLog)
+	response, err := service.CreateRoutineLog(context.Background(), routineLog, ConflictReplace)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -195,22 +545,46 @@ func TestCreateRoutineLogWithDefaultValues(t *testing.T) {
 
 	// Check that default values were set
 	savedLog := mockRepo.routineLogs[response.LogID]
-	if savedLog.UserID != 1 {
-		t.Fatalf("Expected default user ID 1, got %d", savedLog.UserID)
-	}
-
 	if savedLog.LogDate == "" {
 		t.Fatal("Expected log date to be set")
 	}
 }
 
+func TestCreateRoutineLogUnknownUser(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockAI := NewMockAIService(false)
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
+
+	routineLog := database.RoutineLog{
+		UserID:           "user_unknown",
+		SleepHours:       8.0,
+		MealTimes:        []string{"07:30", "12:00", "18:30"},
+		ScreenTime:       4.5,
+		ExerciseDuration: 1.0,
+		WakeUpTime:       "07:00",
+		BedTime:          "23:00",
+		WaterIntake:      2.5,
+		StressLevel:      4,
+		LogDate:          "2024-01-15",
+	}
+
+	_, err := service.CreateRoutineLog(context.Background(), routineLog, ConflictReplace)
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	if len(mockRepo.routineLogs) != 0 {
+		t.Fatalf("Expected no routine log to be saved, got %d", len(mockRepo.routineLogs))
+	}
+}
+
 func TestCreateRoutineLogWithAIFailure(t *testing.T) {
 	mockRepo := NewMockRepository()
 	mockAI := NewMockAIService(true) // AI service will fail
-	service := NewRoutineService(mockRepo, mockAI)
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
 
 	routineLog := database.RoutineLog{
-		UserID:           1,
+		UserID:           "user_1",
 		SleepHours:       8.0,
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -222,7 +596,7 @@ func TestCreateRoutineLogWithAIFailure(t *testing.T) {
 		LogDate:          "2024-01-15",
 	}
 
-	response, err := service.CreateRoutineLog(routineLog)
+	response, err := service.CreateRoutineLog(context.Background(), routineLog, ConflictReplace)
 	if err != nil {
 		t.Fatalf("Expected no error (AI failure should be handled gracefully), got %v", err)
 	}
@@ -238,17 +612,22 @@ func TestCreateRoutineLogWithAIFailure(t *testing.T) {
 	if response.AIResult != nil {
 		t.Fatal("Expected no AI result when AI service fails")
 	}
+
+	if !response.AnalysisQueued || len(mockRepo.jobs) != 1 {
+		t.Fatalf("Expected the log to be queued for background analysis, got queued=%v jobs=%d",
+			response.AnalysisQueued, len(mockRepo.jobs))
+	}
 }
 
 func TestGetInsight(t *testing.T) {
 	mockRepo := NewMockRepository()
 	mockAI := NewMockAIService(false)
-	service := NewRoutineService(mockRepo, mockAI)
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
 
 	// Create a routine log and AI report in the mock repository
 	routineLog := database.RoutineLog{
 		ID:               1,
-		UserID:           1,
+		UserID:           "user_1",
 		SleepHours:       8.0,
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -273,7 +652,7 @@ func TestGetInsight(t *testing.T) {
 	mockRepo.routineLogs[1] = routineLog
 	mockRepo.aiReports[1] = aiReport
 
-	insight, err := service.GetInsight(1)
+	insight, err := service.GetInsight(context.Background(), "user_1", 1)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -294,24 +673,37 @@ func TestGetInsight(t *testing.T) {
 func TestGetInsightNotFound(t *testing.T) {
 	mockRepo := NewMockRepository()
 	mockAI := NewMockAIService(false)
-	service := NewRoutineService(mockRepo, mockAI)
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
 
-	_, err := service.GetInsight(999)
-	if err == nil {
-		t.Fatal("Expected error for non-existent insight")
+	_, err := service.GetInsight(context.Background(), "user_1", 999)
+	if !errors.Is(err, ErrRoutineLogNotFound) {
+		t.Fatalf("Expected ErrRoutineLogNotFound, got %v", err)
+	}
+}
+
+func TestGetInsightOtherUser(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-15"}, false)
+
+	// Another user's log is indistinguishable from a missing one
+	_, err := service.GetInsight(context.Background(), "user_2", logID)
+	if !errors.Is(err, ErrRoutineLogNotFound) {
+		t.Fatalf("Expected ErrRoutineLogNotFound, got %v", err)
 	}
 }
 
 func TestGetUserRoutineLogs(t *testing.T) {
 	mockRepo := NewMockRepository()
 	mockAI := NewMockAIService(false)
-	service := NewRoutineService(mockRepo, mockAI)
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
 
 	// Create multiple logs for user 1
 	for i := 0; i < 3; i++ {
 		routineLog := database.RoutineLog{
 			ID:               i + 1,
-			UserID:           1,
+			UserID:           "user_1",
 			SleepHours:       8.0 + float64(i),
 			MealTimes:        []string{"07:30", "12:00", "18:30"},
 			ScreenTime:       4.5,
@@ -328,7 +720,7 @@ func TestGetUserRoutineLogs(t *testing.T) {
 	// Create one log for user 2
 	routineLog := database.RoutineLog{
 		ID:               4,
-		UserID:           2,
+		UserID:           "user_2",
 		SleepHours:       7.0,
 		MealTimes:        []string{"08:00", "13:00", "19:00"},
 		ScreenTime:       5.0,
@@ -341,10 +733,11 @@ func TestGetUserRoutineLogs(t *testing.T) {
 	}
 	mockRepo.routineLogs[4] = routineLog
 
-	logs, err := service.GetUserRoutineLogs(1, 10)
+	page, err := service.GetUserRoutineLogs(context.Background(), LogQuery{UserID: "user_1", Limit: 10})
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
+	logs := page.Logs
 
 	if len(logs) != 3 {
 		t.Fatalf("Expected 3 logs for user 1, got %d", len(logs))
@@ -352,8 +745,8 @@ func TestGetUserRoutineLogs(t *testing.T) {
 
 	// Verify all logs belong to user 1
 	for _, log := range logs {
-		if log.UserID != 1 {
-			t.Fatalf("Expected user ID 1, got %d", log.UserID)
+		if log.UserID != "user_1" {
+			t.Fatalf("Expected user ID user_1, got %s", log.UserID)
 		}
 	}
 }
@@ -361,13 +754,13 @@ func TestGetUserRoutineLogs(t *testing.T) {
 func TestGetUserRoutineLogsWithLimit(t *testing.T) {
 	mockRepo := NewMockRepository()
 	mockAI := NewMockAIService(false)
-	service := NewRoutineService(mockRepo, mockAI)
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
 
 	// Create multiple logs for user 1
 	for i := 0; i < 5; i++ {
 		routineLog := database.RoutineLog{
 			ID:               i + 1,
-			UserID:           1,
+			UserID:           "user_1",
 			SleepHours:       8.0,
 			MealTimes:        []string{"07:30", "12:00", "18:30"},
 			ScreenTime:       4.5,
@@ -381,12 +774,566 @@ func TestGetUserRoutineLogsWithLimit(t *testing.T) {
 		mockRepo.routineLogs[i+1] = routineLog
 	}
 
-	logs, err := service.GetUserRoutineLogs(1, 3)
+	page, err := service.GetUserRoutineLogs(context.Background(), LogQuery{UserID: "user_1", Limit: 3})
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 
-	if len(logs) != 3 {
-		t.Fatalf("Expected 3 logs with limit, got %d", len(logs))
+	if len(page.Logs) != 3 {
+		t.Fatalf("Expected 3 logs with limit, got %d", len(page.Logs))
+	}
+
+	if page.NextCursor == "" {
+		t.Fatal("Expected a next cursor when more logs remain")
+	}
+}
+
+func TestGetUserRoutineLogsPagination(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	// One log per day from 2024-01-01 to 2024-01-10
+	for day := 1; day <= 10; day++ {
+		mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{
+			UserID:  "user_1",
+			LogDate: fmt.Sprintf("2024-01-%02d", day),
+		}, false)
+	}
+
+	// Walk the 2024-01-03..2024-01-09 range four logs at a time
+	query := LogQuery{UserID: "user_1", From: "2024-01-03", To: "2024-01-09", Limit: 4}
+	var dates []string
+	for pages := 0; ; pages++ {
+		if pages > 2 {
+			t.Fatal("Expected pagination to finish within 2 pages")
+		}
+
+		page, err := service.GetUserRoutineLogs(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, log := range page.Logs {
+			dates = append(dates, log.LogDate)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	expected := []string{"2024-01-09", "2024-01-08", "2024-01-07", "2024-01-06", "2024-01-05", "2024-01-04", "2024-01-03"}
+	if strings.Join(dates, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected dates %v, got %v", expected, dates)
+	}
+}
+
+func TestGetUserRoutineLogsInvalidCursor(t *testing.T) {
+	service := NewRoutineService(NewMockRepository(), NewMockAIService(false), logging.Discard(), nil)
+
+	_, err := service.GetUserRoutineLogs(context.Background(), LogQuery{UserID: "user_1", Cursor: "not-a-cursor"})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestLogQueryPageSize(t *testing.T) {
+	if size := (LogQuery{Limit: 0}).pageSize(); size != DefaultPageSize {
+		t.Fatalf("Expected default page size %d, got %d", DefaultPageSize, size)
+	}
+
+	if size := (LogQuery{Limit: 10000}).pageSize(); size != MaxPageSize {
+		t.Fatalf("Expected page size capped at %d, got %d", MaxPageSize, size)
+	}
+}
+
+func TestUpdateRoutineLogReanalyzes(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockAI := NewMockAIService(false)
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{
+		UserID:      "user_1",
+		SleepHours:  8.0,
+		MealTimes:   []string{"07:30"},
+		WakeUpTime:  "07:00",
+		BedTime:     "23:00",
+		StressLevel: 4,
+		LogDate:     "2024-01-15",
+	}, false)
+	mockRepo.SaveAIReport(context.Background(), database.AIReport{RoutineLogID: logID, AnomalyType: "stale"})
+
+	updated := mockRepo.routineLogs[logID]
+	updated.SleepHours = 4.0
+
+	response, err := service.UpdateRoutineLog(context.Background(), "user_1", updated)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !response.HasAI {
+		t.Fatal("Expected AI analysis to be performed")
+	}
+
+	if mockRepo.routineLogs[logID].SleepHours != 4.0 {
+		t.Fatalf("Expected sleep hours 4.0, got %f", mockRepo.routineLogs[logID].SleepHours)
+	}
+
+	if mockRepo.aiReports[logID].AnomalyType != "test_anomaly" {
+		t.Fatalf("Expected AI report to be replaced, got %s", mockRepo.aiReports[logID].AnomalyType)
+	}
+}
+
+func TestUpdateRoutineLogAIFailureDropsStaleReport(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(true), logging.Discard(), nil)
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{
+		UserID:      "user_1",
+		SleepHours:  8.0,
+		WakeUpTime:  "07:00",
+		BedTime:     "23:00",
+		StressLevel: 4,
+		LogDate:     "2024-01-15",
+	}, false)
+	mockRepo.SaveAIReport(context.Background(), database.AIReport{RoutineLogID: logID, AnomalyType: "stale"})
+
+	updated := mockRepo.routineLogs[logID]
+	updated.SleepHours = 4.0
+
+	response, err := service.UpdateRoutineLog(context.Background(), "user_1", updated)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.HasAI || !response.AnalysisQueued {
+		t.Fatalf("Expected the update to be queued for analysis, got %+v", response)
+	}
+
+	// The report of the old data must not be served for the corrected log
+	insight, err := service.GetInsight(context.Background(), "user_1", logID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if insight.AIReport != nil {
+		t.Fatalf("Expected no AI report until re-analysis, got %+v", insight.AIReport)
+	}
+}
+
+func TestPatchRoutineLog(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), healthyLog(), false)
+	mockRepo.SaveAIReport(context.Background(), database.AIReport{RoutineLogID: logID, AnomalyType: "stale"})
+
+	// Only the stress level is submitted; everything else comes from the stored log
+	response, err := service.PatchRoutineLog(context.Background(), "user_1", logID, []byte(`{"stress_level": 9}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.LogID != logID || !response.HasAI {
+		t.Fatalf("Expected the log to be patched and re-analyzed, got %+v", response)
+	}
+
+	stored := mockRepo.routineLogs[logID]
+	if stored.StressLevel != 9 || stored.SleepHours != 8.0 || stored.Sleep == nil {
+		t.Fatalf("Expected only the stress level to change, got %+v", stored)
+	}
+
+	if mockRepo.aiReports[logID].AnomalyType != "test_anomaly" {
+		t.Fatalf("Expected AI report to be replaced, got %s", mockRepo.aiReports[logID].AnomalyType)
+	}
+
+	// The patched log is validated as a whole and cannot move to another user
+	if _, err := service.PatchRoutineLog(context.Background(), "user_1", logID, []byte(`{"sleep_hours": 20}`)); err == nil {
+		t.Fatal("Expected a validation error for sleep longer than the time in bed")
+	}
+	if _, err := service.PatchRoutineLog(context.Background(), "user_1", logID, []byte(`{"user_id": "user_2"}`)); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("Expected ErrForbidden, got %v", err)
+	}
+	if mockRepo.routineLogs[logID].SleepHours != 8.0 || mockRepo.routineLogs[logID].UserID != "user_1" {
+		t.Fatal("Expected a rejected patch to leave the stored log alone")
+	}
+
+	if _, err := service.PatchRoutineLog(context.Background(), "user_2", logID, []byte(`{"stress_level": 1}`)); !errors.Is(err, ErrRoutineLogNotFound) {
+		t.Fatalf("Expected ErrRoutineLogNotFound for another user's log, got %v", err)
+	}
+}
+
+func TestUpdateRoutineLogDropsReportOfChangedLog(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, &updatingAIService{MockAIService: NewMockAIService(false), repo: mockRepo}, logging.Discard(), nil)
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), healthyLog(), false)
+
+	updated := mockRepo.routineLogs[logID]
+	updated.StressLevel = 9
+
+	if _, err := service.UpdateRoutineLog(context.Background(), "user_1", updated); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The report of this update must not overwrite the report of the update made during its analysis
+	if report := mockRepo.aiReports[logID]; report.AnomalyType != "fresh" {
+		t.Fatalf("Expected the newer report to be kept, got %+v", report)
+	}
+}
+
+func TestUpdateRoutineLogOtherUser(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-15"}, false)
+
+	_, err := service.UpdateRoutineLog(context.Background(), "user_2", database.RoutineLog{ID: logID, UserID: "user_2"})
+	if !errors.Is(err, ErrRoutineLogNotFound) {
+		t.Fatalf("Expected ErrRoutineLogNotFound, got %v", err)
+	}
+}
+
+func TestUpdateRoutineLogNotFound(t *testing.T) {
+	service := NewRoutineService(NewMockRepository(), NewMockAIService(false), logging.Discard(), nil)
+
+	_, err := service.UpdateRoutineLog(context.Background(), "user_1", database.RoutineLog{ID: 999, UserID: "user_1"})
+	if !errors.Is(err, ErrRoutineLogNotFound) {
+		t.Fatalf("Expected ErrRoutineLogNotFound, got %v", err)
+	}
+}
+
+func TestDeleteRoutineLog(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-15"}, false)
+	mockRepo.SaveAIReport(context.Background(), database.AIReport{RoutineLogID: logID})
+
+	// Another user's log is reported as missing
+	if err := service.DeleteRoutineLog(context.Background(), "user_2", logID); !errors.Is(err, ErrRoutineLogNotFound) {
+		t.Fatalf("Expected ErrRoutineLogNotFound, got %v", err)
+	}
+
+	if err := service.DeleteRoutineLog(context.Background(), "user_1", logID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, exists := mockRepo.routineLogs[logID]; exists {
+		t.Fatal("Expected routine log to be deleted")
+	}
+
+	if _, exists := mockRepo.aiReports[logID]; exists {
+		t.Fatal("Expected AI report to be deleted")
+	}
+}
+
+func TestCreateRoutineLogSameDay(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	routineLog := database.RoutineLog{
+		UserID:      "user_1",
+		SleepHours:  8.0,
+		MealTimes:   []string{"07:30"},
+		WakeUpTime:  "07:00",
+		BedTime:     "23:00",
+		StressLevel: 4,
+		LogDate:     "2024-01-15",
+	}
+
+	first, err := service.CreateRoutineLog(context.Background(), routineLog, ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !first.Created {
+		t.Fatal("Expected first log of the day to be created")
+	}
+
+	routineLog.SleepHours = 5.0
+	second, err := service.CreateRoutineLog(context.Background(), routineLog, ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if second.Created || second.LogID != first.LogID {
+		t.Fatalf("Expected log %d to be replaced, got created=%v id=%d", first.LogID, second.Created, second.LogID)
+	}
+
+	if len(mockRepo.routineLogs) != 1 {
+		t.Fatalf("Expected 1 routine log, got %d", len(mockRepo.routineLogs))
+	}
+
+	if mockRepo.routineLogs[first.LogID].SleepHours != 5.0 {
+		t.Fatalf("Expected sleep hours 5.0, got %f", mockRepo.routineLogs[first.LogID].SleepHours)
+	}
+
+	if _, err := service.CreateRoutineLog(context.Background(), routineLog, ConflictReject); !errors.Is(err, ErrRoutineLogExists) {
+		t.Fatalf("Expected ErrRoutineLogExists, got %v", err)
+	}
+}
+
+func TestCreateRoutineLogReplaceAIFailureDropsStaleReport(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockAI := NewMockAIService(false)
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
+
+	first, err := service.CreateRoutineLog(context.Background(), healthyLog(), ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, exists := mockRepo.aiReports[first.LogID]; !exists {
+		t.Fatal("Expected the first log of the day to be analyzed")
+	}
+
+	mockAI.shouldFail = true
+	replacement := healthyLog()
+	replacement.SleepHours = 4.0
+	second, err := service.CreateRoutineLog(context.Background(), replacement, ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if second.Created || second.HasAI || !second.AnalysisQueued {
+		t.Fatalf("Expected the replaced day to be queued for analysis, got %+v", second)
+	}
+
+	// The report of the replaced data must not be served for the new log
+	insight, err := service.GetInsight(context.Background(), "user_1", second.LogID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if insight.AIReport != nil {
+		t.Fatalf("Expected no AI report until re-analysis, got %+v", insight.AIReport)
+	}
+}
+
+func TestReplaceRoutineLogDropsReportOfChangedLog(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, &updatingAIService{MockAIService: NewMockAIService(false), repo: mockRepo}, logging.Discard(), nil)
+
+	mockRepo.SaveRoutineLog(context.Background(), healthyLog(), false)
+
+	replacement := healthyLog()
+	replacement.StressLevel = 9
+	response, err := service.CreateRoutineLog(context.Background(), replacement, ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The day was overwritten again during the analysis; its newer report is kept
+	if report := mockRepo.aiReports[response.LogID]; report.AnomalyType != "fresh" {
+		t.Fatalf("Expected the newer report to be kept, got %+v", report)
+	}
+}
+
+func TestMergeRoutineLog(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	first, err := service.CreateRoutineLog(context.Background(), healthyLog(), ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Only the stress level is submitted; everything else comes from the stored day
+	merged, err := service.MergeRoutineLog(context.Background(), "user_1", "2024-01-15", []byte(`{"stress_level": 9, "user_id": "user_2"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if merged.Created || merged.LogID != first.LogID || !merged.HasAI {
+		t.Fatalf("Expected the stored log to be merged and re-analyzed, got %+v", merged)
+	}
+
+	stored := mockRepo.routineLogs[first.LogID]
+	if stored.StressLevel != 9 || stored.SleepHours != 8.0 || stored.UserID != "user_1" || stored.Sleep == nil {
+		t.Fatalf("Expected only the stress level to change, got %+v", stored)
+	}
+
+	// The merged log is validated as a whole
+	_, err = service.MergeRoutineLog(context.Background(), "user_1", "2024-01-15", []byte(`{"sleep_hours": 20}`))
+	if err == nil {
+		t.Fatal("Expected a validation error for sleep longer than the time in bed")
+	}
+	if mockRepo.routineLogs[first.LogID].SleepHours != 8.0 {
+		t.Fatal("Expected an invalid merge to leave the stored log alone")
+	}
+}
+
+func TestMergeRoutineLogCreatesDay(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	patch, _ := json.Marshal(healthyLog())
+	response, err := service.MergeRoutineLog(context.Background(), "user_1", "2024-01-16", patch)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !response.Created || mockRepo.routineLogs[response.LogID].LogDate != "2024-01-16" {
+		t.Fatalf("Expected a new log for 2024-01-16, got %+v", mockRepo.routineLogs[response.LogID])
+	}
+}
+
+func TestParseConflictMode(t *testing.T) {
+	mode, err := ParseConflictMode("")
+	if err != nil || mode != ConflictReplace {
+		t.Fatalf("Expected default replace, got %s (%v)", mode, err)
+	}
+
+	mode, err = ParseConflictMode("merge")
+	if err != nil || mode != ConflictMerge {
+		t.Fatalf("Expected merge, got %s (%v)", mode, err)
+	}
+
+	if _, err := ParseConflictMode("append"); err == nil {
+		t.Fatal("Expected error for unknown conflict mode")
+	}
+}
+
+func TestGetUserInsightsIncludesUnanalyzedLogs(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	analyzedID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-15"}, false)
+	mockRepo.SaveAIReport(context.Background(), database.AIReport{RoutineLogID: analyzedID, AnomalyType: "normal_routine"})
+	mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-16"}, false)
+
+	page, err := service.GetUserInsights(context.Background(), LogQuery{UserID: "user_1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(page.Insights) != 2 {
+		t.Fatalf("Expected 2 insights, got %d", len(page.Insights))
+	}
+
+	// Newest first: the unanalyzed 2024-01-16 log has no report
+	if page.Insights[0].AIReport != nil {
+		t.Fatalf("Expected nil AI report for unanalyzed log, got %+v", page.Insights[0].AIReport)
+	}
+
+	if page.Insights[1].AIReport == nil || page.Insights[1].AIReport.AnomalyType != "normal_routine" {
+		t.Fatalf("Expected AI report for analyzed log, got %+v", page.Insights[1].AIReport)
+	}
+
+	if mockRepo.insightQueries != 1 {
+		t.Fatalf("Expected a single insight query, got %d", mockRepo.insightQueries)
+	}
+}
+
+func TestGetUserInsightsPagination(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	for day := 1; day <= 3; day++ {
+		mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: fmt.Sprintf("2024-01-%02d", day)}, false)
+	}
+
+	page, err := service.GetUserInsights(context.Background(), LogQuery{UserID: "user_1", Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(page.Insights) != 2 || page.NextCursor == "" {
+		t.Fatalf("Expected 2 insights and a next cursor, got %d and %q", len(page.Insights), page.NextCursor)
+	}
+
+	page, err = service.GetUserInsights(context.Background(), LogQuery{UserID: "user_1", Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(page.Insights) != 1 || page.Insights[0].RoutineLog.LogDate != "2024-01-01" || page.NextCursor != "" {
+		t.Fatalf("Expected only 2024-01-01 on the last page, got %+v", page)
+	}
+}
+
+func TestGetUserInsightsByModelVersion(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	for day, modelVersion := range []string{"1.0.0", "1.1.0", "1.1.0"} {
+		logID, _, _, _ := mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: fmt.Sprintf("2024-01-%02d", day+1)}, false)
+		mockRepo.SaveAIReport(context.Background(), database.AIReport{RoutineLogID: logID, ModelVersion: modelVersion})
+	}
+	mockRepo.SaveRoutineLog(context.Background(), database.RoutineLog{UserID: "user_1", LogDate: "2024-01-04"}, false)
+
+	page, err := service.GetUserInsights(context.Background(), LogQuery{UserID: "user_1", ModelVersion: "1.1.0"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(page.Insights) != 2 {
+		t.Fatalf("Expected the 2 logs analyzed by 1.1.0, got %d", len(page.Insights))
+	}
+	for _, insight := range page.Insights {
+		if insight.AIReport.ModelVersion != "1.1.0" {
+			t.Fatalf("Expected only model version 1.1.0, got %q", insight.AIReport.ModelVersion)
+		}
+	}
+}
+
+func TestCreateRoutineLogStoresModelProvenance(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockAI := NewMockAIService(false)
+	accuracy := 0.923
+	mockAI.response.ModelAccuracy = &accuracy
+	mockAI.response.FeatureNames = []string{"sleep_hours", "screen_time"}
+	service := NewRoutineService(mockRepo, mockAI, logging.Discard(), nil)
+
+	response, err := service.CreateRoutineLog(context.Background(), healthyLog(), ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.AIResult.ModelName != "routine_random_forest" || response.AIResult.ModelVersion != "1.0.0" {
+		t.Fatalf("Expected the model in the response, got %+v", response.AIResult)
+	}
+
+	report := mockRepo.aiReports[response.LogID]
+	if report.ModelVersion != "1.0.0" || report.ModelAccuracy == nil || *report.ModelAccuracy != 0.923 || len(report.FeatureNames) != 2 {
+		t.Fatalf("Expected the model provenance to be stored, got %+v", report)
+	}
+}
+
+func TestCreateRoutineLogCancelledRequestAbortsAnalysis(t *testing.T) {
+	server, aborted := newBlockingAIServer()
+	defer server.Close()
+
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewAIService(server.URL, DefaultAIServiceTimeout, logging.Discard()), logging.Discard(), nil)
+
+	// The client disconnects while the AI service is still analyzing
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	response, err := service.CreateRoutineLog(ctx, database.RoutineLog{
+		UserID:     "user_1",
+		SleepHours: 8.0,
+		MealTimes:  []string{"07:30"},
+		LogDate:    "2024-01-15",
+	}, ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected the log to be saved, got %v", err)
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the AI call to be aborted")
+	}
+
+	if response.HasAI {
+		t.Fatal("Expected no AI analysis for an aborted request")
+	}
+
+	// The analysis is still queued although the request context is gone
+	if !response.AnalysisQueued || len(mockRepo.jobs) != 1 {
+		t.Fatalf("Expected the log to be queued for background analysis, got queued=%v jobs=%d",
+			response.AnalysisQueued, len(mockRepo.jobs))
 	}
 }
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"time"
+
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/validation"
+)
+
+// computeSleepMetrics derives the sleep window of a log from its bed and wake-up times
+// A wake-up time earlier than the bed time is on the next day; nil is returned when
+// either time is not HH:MM or both are equal, since the window is then unknown
+func computeSleepMetrics(routineLog database.RoutineLog) *database.SleepMetrics {
+	bed, err := validation.ParseClock(routineLog.BedTime)
+	if err != nil {
+		return nil
+	}
+	wakeUp, err := validation.ParseClock(routineLog.WakeUpTime)
+	if err != nil {
+		return nil
+	}
+
+	window := validation.SleepWindow(bed, wakeUp)
+	if window == 0 {
+		return nil
+	}
+
+	return database.NewSleepMetrics(int(bed/time.Minute), int(window/time.Minute), routineLog.SleepHours)
+}
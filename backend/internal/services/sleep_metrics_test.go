@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
+)
+
+func TestComputeSleepMetrics(t *testing.T) {
+	tests := []struct {
+		bedTime, wakeUpTime string
+		sleepHours          float64
+		expected            *database.SleepMetrics
+	}{
+		// Crossing midnight: 23:00 to 07:00 is 8 hours centred on 03:00
+		{"23:00", "07:00", 7.5, &database.SleepMetrics{DurationHours: 8, MidpointMinutes: 180, Midpoint: "03:00", DiscrepancyHours: -0.5}},
+		// Centred before midnight: the midpoint offset is negative instead of wrapping to 23:30
+		{"21:00", "02:00", 5, &database.SleepMetrics{DurationHours: 5, MidpointMinutes: -30, Midpoint: "23:30", DiscrepancyHours: 0}},
+		// Same day
+		{"01:15", "08:35", 8, &database.SleepMetrics{DurationHours: 7.33, MidpointMinutes: 295, Midpoint: "04:55", DiscrepancyHours: 0.67}},
+		// Day sleep after a night shift
+		{"09:00", "16:00", 7, &database.SleepMetrics{DurationHours: 7, MidpointMinutes: 750 - 1440, Midpoint: "12:30", DiscrepancyHours: 0}},
+		{"7:00", "7:00", 8, nil},
+		{"late", "07:00", 8, nil},
+		{"23:00", "", 8, nil},
+	}
+
+	for _, tt := range tests {
+		metrics := computeSleepMetrics(database.RoutineLog{BedTime: tt.bedTime, WakeUpTime: tt.wakeUpTime, SleepHours: tt.sleepHours})
+
+		switch {
+		case tt.expected == nil && metrics != nil:
+			t.Fatalf("%s-%s: expected no metrics, got %+v", tt.bedTime, tt.wakeUpTime, metrics)
+		case tt.expected != nil && (metrics == nil || *metrics != *tt.expected):
+			t.Fatalf("%s-%s: expected %+v, got %+v", tt.bedTime, tt.wakeUpTime, tt.expected, metrics)
+		}
+	}
+}
+
+func TestRoutineLogSleepMetricsAreStored(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	routineLog := database.RoutineLog{
+		UserID:      "user_1",
+		SleepHours:  7.0,
+		MealTimes:   []string{"08:00"},
+		WakeUpTime:  "06:30",
+		BedTime:     "23:00",
+		StressLevel: 3,
+		LogDate:     "2024-01-15",
+		// Client-supplied metrics are ignored
+		Sleep: &database.SleepMetrics{DurationHours: 12},
+	}
+
+	response, err := service.CreateRoutineLog(context.Background(), routineLog, ConflictReplace)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored := mockRepo.routineLogs[response.LogID].Sleep
+	if stored == nil || stored.DurationHours != 7.5 || stored.Midpoint != "02:45" || stored.DiscrepancyHours != -0.5 {
+		t.Fatalf("Expected 7.5 hours centred on 02:45, got %+v", stored)
+	}
+
+	// Updates recompute the window
+	routineLog.ID = response.LogID
+	routineLog.BedTime = "22:30"
+	if _, err := service.UpdateRoutineLog(context.Background(), "user_1", routineLog); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored = mockRepo.routineLogs[response.LogID].Sleep
+	if stored == nil || stored.DurationHours != 8 || stored.Midpoint != "02:30" {
+		t.Fatalf("Expected 8 hours centred on 02:30 after the update, got %+v", stored)
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"lifepattern-api/internal/apperror"
+	"lifepattern-api/internal/database"
+)
+
+// TrendPeriod is the length of one trend bucket
+type TrendPeriod string
+
+const (
+	// TrendWeek buckets logs by ISO week, starting on Monday
+	TrendWeek TrendPeriod = "week"
+	// TrendMonth buckets logs by calendar month
+	TrendMonth TrendPeriod = "month"
+)
+
+// ParseTrendPeriod parses a trend period, defaulting to TrendWeek when empty
+func ParseTrendPeriod(value string) (TrendPeriod, error) {
+	switch period := TrendPeriod(value); period {
+	case "":
+		return TrendWeek, nil
+	case TrendWeek, TrendMonth:
+		return period, nil
+	default:
+		return "", fmt.Errorf("unknown trend period %q (expected week or month)", value)
+	}
+}
+
+// TrendQuery selects the logs of a user aggregated into trend buckets
+type TrendQuery struct {
+	UserID string
+	Period TrendPeriod
+	From   string // inclusive lower log_date bound (YYYY-MM-DD), optional
+	To     string // inclusive upper log_date bound (YYYY-MM-DD), optional
+}
+
+// Trends are a user's routine logs aggregated per week or month, oldest bucket first
+type Trends struct {
+	UserID  string                 `json:"user_id"`
+	Period  TrendPeriod            `json:"period"`
+	Buckets []database.TrendBucket `json:"buckets"`
+}
+
+// GetTrends aggregates a user's routine logs into weekly or monthly buckets
+// The aggregation runs in the database, so the dashboard no longer averages raw logs itself
+func (s *RoutineService) GetTrends(ctx context.Context, query TrendQuery) (*Trends, error) {
+	s.logger.DebugContext(ctx, "retrieving trends", "user_id", query.UserID, "period", query.Period)
+
+	period, err := ParseTrendPeriod(string(query.Period))
+	if err != nil {
+		return nil, apperror.Validation("period", err.Error())
+	}
+
+	buckets, err := s.repo.GetRoutineTrends(ctx, database.TrendFilter{
+		UserID: query.UserID,
+		Period: string(period),
+		From:   query.From,
+		To:     query.To,
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get trends", "user_id", query.UserID, "error", err)
+		return nil, fmt.Errorf("failed to get user trends: %w", err)
+	}
+
+	// An empty range is reported as an empty list rather than null
+	if buckets == nil {
+		buckets = []database.TrendBucket{}
+	}
+
+	s.logger.DebugContext(ctx, "retrieved trends", "user_id", query.UserID, "buckets", len(buckets))
+	return &Trends{UserID: query.UserID, Period: period, Buckets: buckets}, nil
+}
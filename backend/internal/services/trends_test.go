@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
+)
+
+func TestParseTrendPeriod(t *testing.T) {
+	period, err := ParseTrendPeriod("")
+	if err != nil || period != TrendWeek {
+		t.Fatalf("Expected default week, got %s (%v)", period, err)
+	}
+
+	period, err = ParseTrendPeriod("month")
+	if err != nil || period != TrendMonth {
+		t.Fatalf("Expected month, got %s (%v)", period, err)
+	}
+
+	if _, err := ParseTrendPeriod("year"); err == nil {
+		t.Fatal("Expected error for unknown trend period")
+	}
+}
+
+func TestGetTrends(t *testing.T) {
+	mockRepo := NewMockRepository()
+	rate := 0.5
+	mockRepo.trendBuckets = []database.TrendBucket{
+		{PeriodStart: "2024-05-01", LogCount: 3, AnalyzedCount: 2, AnomalyCount: 1, AnomalyRate: &rate},
+	}
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	trends, err := service.GetTrends(context.Background(), TrendQuery{
+		UserID: "user_1", Period: TrendMonth, From: "2024-01-01", To: "2024-06-30",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expectedFilter := database.TrendFilter{UserID: "user_1", Period: "month", From: "2024-01-01", To: "2024-06-30"}
+	if mockRepo.trendFilter != expectedFilter {
+		t.Fatalf("Expected filter %+v, got %+v", expectedFilter, mockRepo.trendFilter)
+	}
+	if trends.UserID != "user_1" || trends.Period != TrendMonth || len(trends.Buckets) != 1 {
+		t.Fatalf("Expected one monthly bucket for user_1, got %+v", trends)
+	}
+}
+
+func TestGetTrendsDefaultsAndEmpty(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewRoutineService(mockRepo, NewMockAIService(false), logging.Discard(), nil)
+
+	trends, err := service.GetTrends(context.Background(), TrendQuery{UserID: "user_1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if mockRepo.trendFilter.Period != "week" {
+		t.Fatalf("Expected weekly buckets by default, got %s", mockRepo.trendFilter.Period)
+	}
+	if trends.Buckets == nil || len(trends.Buckets) != 0 {
+		t.Fatalf("Expected an empty bucket list, got %v", trends.Buckets)
+	}
+
+	if _, err := service.GetTrends(context.Background(), TrendQuery{UserID: "user_1", Period: "day"}); err == nil {
+		t.Fatal("Expected error for unknown trend period")
+	}
+}
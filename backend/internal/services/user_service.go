@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strconv"
+	"time"
+
+	"lifepattern-api/internal/database"
+)
+
+// UserServiceConfig tunes user registration
+type UserServiceConfig struct {
+	// AllowLegacyClaims lets users registered before secrets existed obtain one by registering
+	// again with their existing ID and device ID; enable it only while those users migrate
+	AllowLegacyClaims bool
+}
+
+type UserService struct {
+	repo   RepositoryInterface
+	config UserServiceConfig
+	logger *slog.Logger
+}
+
+func NewUserService(repo RepositoryInterface, config UserServiceConfig, logger *slog.Logger) *UserService {
+	return &UserService{
+		repo:   repo,
+		config: config,
+		logger: logger,
+	}
+}
+
+// RegisteredUser is a newly registered user together with the secret it authenticates with
+// The secret is only available here; the database keeps its hash
+type RegisteredUser struct {
+	database.User
+	Secret string `json:"secret"`
+}
+
+// RegisterUser persists a device-bound user and issues its secret
+// If the client did not supply an ID, one is generated in the same
+// "user_<timestamp>_<random>" format the mobile app uses. While legacy claims are allowed,
+// a user registered before secrets existed gets its secret by registering its ID and device ID again, once
+func (s *UserService) RegisterUser(ctx context.Context, user database.User) (*RegisteredUser, error) {
+	if user.ID == "" {
+		id, err := generateUserID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate user ID: %w", err)
+		}
+		user.ID = id
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate user secret: %w", err)
+	}
+	user.SecretHash = hashSecret(secret)
+
+	s.logger.InfoContext(ctx, "registering user", "user_id", user.ID)
+
+	created, err := s.repo.CreateUser(ctx, user)
+	if err != nil {
+		if errors.Is(err, database.ErrConflict) {
+			if s.config.AllowLegacyClaims {
+				return s.claimLegacyUser(ctx, user)
+			}
+			return nil, ErrUserExists
+		}
+		s.logger.ErrorContext(ctx, "failed to register user", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to register user: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "user registered", "user_id", created.ID)
+	return &RegisteredUser{User: *created, Secret: secret}, nil
+}
+
+// claimLegacyUser issues a secret to the existing user with user's ID and device ID if that user has none
+// The device ID alone is not enough: it is not secret and old app versions shared it
+func (s *UserService) claimLegacyUser(ctx context.Context, user database.User) (*RegisteredUser, error) {
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate user secret: %w", err)
+	}
+
+	claimed, err := s.repo.ClaimUserSecret(ctx, user.ID, user.DeviceID, hashSecret(secret))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrUserExists
+		}
+		return nil, fmt.Errorf("failed to register user: %w", err)
+	}
+
+	s.logger.WarnContext(ctx, "secret issued to legacy user", "user_id", claimed.ID)
+	return &RegisteredUser{User: *claimed, Secret: secret}, nil
+}
+
+// GetUser retrieves a registered user
+func (s *UserService) GetUser(ctx context.Context, userID string) (*database.User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// RenameUser changes a user's display name
+func (s *UserService) RenameUser(ctx context.Context, userID string, username string) (*database.User, error) {
+	s.logger.InfoContext(ctx, "renaming user", "user_id", userID)
+
+	user, err := s.repo.UpdateUsername(ctx, userID, username)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			return nil, ErrUserNotFound
+		case errors.Is(err, database.ErrConflict):
+			return nil, ErrUserExists
+		}
+		return nil, fmt.Errorf("failed to rename user: %w", err)
+	}
+
+	return user, nil
+}
+
+// Authenticate verifies that secret is the one issued to the user at registration
+func (s *UserService) Authenticate(ctx context.Context, userID string, secret string) (*database.User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to authenticate user: %w", err)
+	}
+
+	if user.SecretHash == "" || subtle.ConstantTimeCompare([]byte(user.SecretHash), []byte(hashSecret(secret))) != 1 {
+		s.logger.WarnContext(ctx, "invalid user secret", "user_id", userID)
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+// generateUserID builds a user ID of the form user_<timestamp>_<random>
+func generateUserID() (string, error) {
+	const alphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+	suffix := make([]byte, 9)
+	for i := range suffix {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		suffix[i] = alphabet[n.Int64()]
+	}
+
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	return "user_" + timestamp + "_" + string(suffix), nil
+}
+
+// generateSecret returns 256 random bits, base64url encoded
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret returns the hex SHA-256 hash stored for secret
+// Secrets are random, so a fast hash is enough to make a leaked hash useless for logging in
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
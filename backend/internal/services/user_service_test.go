@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"lifepattern-api/internal/database"
+	"lifepattern-api/internal/logging"
+)
+
+// testUserSecret is the secret of user_1 in the mock repository
+const testUserSecret = "test-secret-user-1"
+
+func TestRegisterUser(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewUserService(mockRepo, UserServiceConfig{}, logging.Discard())
+
+	user, err := service.RegisterUser(context.Background(), database.User{
+		ID:       "user_1705312200000_k3j9x2m1q",
+		Username: "Calm-Walker-7",
+		DeviceID: "abc123",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if user.ID != "user_1705312200000_k3j9x2m1q" {
+		t.Fatalf("Expected client-supplied ID to be kept, got %s", user.ID)
+	}
+
+	saved, exists := mockRepo.users[user.ID]
+	if !exists {
+		t.Fatal("Expected user to be saved")
+	}
+
+	// Only the hash of the secret is stored
+	if user.Secret == "" || saved.SecretHash == "" || saved.SecretHash == user.Secret {
+		t.Fatalf("Expected a secret with only its hash stored, got secret %q and hash %q", user.Secret, saved.SecretHash)
+	}
+
+	if _, err := service.Authenticate(context.Background(), user.ID, user.Secret); err != nil {
+		t.Fatalf("Expected the issued secret to authenticate, got %v", err)
+	}
+}
+
+func TestRegisterUserGeneratesID(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewUserService(mockRepo, UserServiceConfig{}, logging.Discard())
+
+	user, err := service.RegisterUser(context.Background(), database.User{
+		Username: "Calm-Walker-7",
+		DeviceID: "abc123",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The ID is readable by anyone the user shares data with, so it must not reveal the device
+	if strings.Contains(user.ID, "abc123") {
+		t.Fatalf("Expected generated ID without the device ID, got %s", user.ID)
+	}
+
+	if parts := strings.Split(user.ID, "_"); len(parts) != 3 || parts[0] != "user" {
+		t.Fatalf("Expected ID of the form user_<timestamp>_<random>, got %s", user.ID)
+	}
+}
+
+func TestRegisterUserDuplicate(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewUserService(mockRepo, UserServiceConfig{}, logging.Discard())
+
+	_, err := service.RegisterUser(context.Background(), database.User{
+		Username: "Another-Name-1",
+		DeviceID: "device_1", // Already registered to user_1
+	})
+	if !errors.Is(err, ErrUserExists) {
+		t.Fatalf("Expected ErrUserExists, got %v", err)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	service := NewUserService(NewMockRepository(), UserServiceConfig{}, logging.Discard())
+
+	user, err := service.GetUser(context.Background(), "user_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if user.Username != "Swift-Runner-1" {
+		t.Fatalf("Expected username Swift-Runner-1, got %s", user.Username)
+	}
+
+	if _, err := service.GetUser(context.Background(), "user_missing"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestRenameUser(t *testing.T) {
+	mockRepo := NewMockRepository()
+	service := NewUserService(mockRepo, UserServiceConfig{}, logging.Discard())
+
+	user, err := service.RenameUser(context.Background(), "user_1", "Bold-Dreamer-9")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if user.Username != "Bold-Dreamer-9" {
+		t.Fatalf("Expected username Bold-Dreamer-9, got %s", user.Username)
+	}
+
+	if _, err := service.RenameUser(context.Background(), "user_missing", "Bold-Dreamer-9"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	service := NewUserService(NewMockRepository(), UserServiceConfig{}, logging.Discard())
+
+	user, err := service.Authenticate(context.Background(), "user_1", testUserSecret)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if user.ID != "user_1" {
+		t.Fatalf("Expected user_1, got %s", user.ID)
+	}
+
+	// The device ID is not a credential
+	if _, err := service.Authenticate(context.Background(), "user_1", "device_1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials for wrong secret, got %v", err)
+	}
+
+	if _, err := service.Authenticate(context.Background(), "user_missing", testUserSecret); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials for unknown user, got %v", err)
+	}
+}
+
+func TestAuthenticateWithoutSecret(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockRepo.users["user_legacy"] = database.User{ID: "user_legacy", Username: "Old-Timer-1", DeviceID: "device_legacy"}
+	service := NewUserService(mockRepo, UserServiceConfig{}, logging.Discard())
+
+	// Users registered before secrets were issued cannot log in with an empty secret
+	if _, err := service.Authenticate(context.Background(), "user_legacy", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestRegisterUserLegacyClaim(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockRepo.users["user_legacy"] = database.User{ID: "user_legacy", Username: "Old-Timer-1", DeviceID: "device_legacy"}
+	service := NewUserService(mockRepo, UserServiceConfig{AllowLegacyClaims: true}, logging.Discard())
+
+	// The device ID alone does not claim the existing user
+	_, err := service.RegisterUser(context.Background(), database.User{
+		Username: "Calm-Walker-7",
+		DeviceID: "device_legacy",
+	})
+	if !errors.Is(err, ErrUserExists) {
+		t.Fatalf("Expected ErrUserExists, got %v", err)
+	}
+
+	// Registering the existing ID and device again issues a secret for the existing user
+	user, err := service.RegisterUser(context.Background(), database.User{
+		ID:       "user_legacy",
+		Username: "Calm-Walker-7",
+		DeviceID: "device_legacy",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if user.ID != "user_legacy" || user.Username != "Old-Timer-1" {
+		t.Fatalf("Expected the existing user, got %+v", user.User)
+	}
+
+	if _, err := service.Authenticate(context.Background(), "user_legacy", user.Secret); err != nil {
+		t.Fatalf("Expected the issued secret to authenticate, got %v", err)
+	}
+
+	// The secret is issued only once
+	_, err = service.RegisterUser(context.Background(), database.User{
+		ID:       "user_legacy",
+		Username: "Calm-Walker-8",
+		DeviceID: "device_legacy",
+	})
+	if !errors.Is(err, ErrUserExists) {
+		t.Fatalf("Expected ErrUserExists, got %v", err)
+	}
+	if _, err := service.Authenticate(context.Background(), "user_legacy", user.Secret); err != nil {
+		t.Fatalf("Expected the first secret to remain valid, got %v", err)
+	}
+}
+
+func TestRegisterUserLegacyClaimDisabled(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockRepo.users["user_legacy"] = database.User{ID: "user_legacy", Username: "Old-Timer-1", DeviceID: "device_legacy"}
+	service := NewUserService(mockRepo, UserServiceConfig{}, logging.Discard())
+
+	_, err := service.RegisterUser(context.Background(), database.User{
+		ID:       "user_legacy",
+		Username: "Calm-Walker-7",
+		DeviceID: "device_legacy",
+	})
+	if !errors.Is(err, ErrUserExists) {
+		t.Fatalf("Expected ErrUserExists, got %v", err)
+	}
+	if mockRepo.users["user_legacy"].SecretHash != "" {
+		t.Fatal("Expected no secret to be issued")
+	}
+}
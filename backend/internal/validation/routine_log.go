@@ -0,0 +1,104 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	"lifepattern-api/internal/database"
+)
+
+const (
+	// MaxWaterIntake is the most water, in liters, a day's log may report
+	MaxWaterIntake = 10
+
+	// MaxMealsPerDay is the most meal times a day's log may list
+	MaxMealsPerDay = 10
+
+	// SleepTolerance is how far sleep_hours may exceed the time between bed_time and
+	// wake_up_time, to allow for naps and rounding
+	SleepTolerance = time.Hour
+)
+
+// RoutineLog checks every field of a routine log and reports all invalid ones together
+// Log dates may be at most one day after now, as clients east of the server are already
+// on the next day
+func RoutineLog(log database.RoutineLog, now time.Time) error {
+	var errs Errors
+
+	if log.SleepHours < 0 || log.SleepHours > 24 {
+		errs.Add("sleep_hours", "sleep_hours must be between 0 and 24")
+	}
+	if log.ScreenTime < 0 || log.ScreenTime > 24 {
+		errs.Add("screen_time", "screen_time must be between 0 and 24")
+	}
+	if log.ExerciseDuration < 0 || log.ExerciseDuration > 24 {
+		errs.Add("exercise_duration", "exercise_duration must be between 0 and 24")
+	}
+	if log.WaterIntake < 0 || log.WaterIntake > MaxWaterIntake {
+		errs.Add("water_intake", "water_intake must be between 0 and %d liters", MaxWaterIntake)
+	}
+	if log.StressLevel < 1 || log.StressLevel > 10 {
+		errs.Add("stress_level", "stress_level must be between 1 and 10")
+	}
+
+	switch {
+	case len(log.MealTimes) == 0:
+		errs.Add("meal_times", "meal_times cannot be empty")
+	case len(log.MealTimes) > MaxMealsPerDay:
+		errs.Add("meal_times", "meal_times cannot list more than %d meals", MaxMealsPerDay)
+	}
+	for i, mealTime := range log.MealTimes {
+		if _, err := ParseClock(mealTime); err != nil {
+			errs.Add(fmt.Sprintf("meal_times[%d]", i), "meal time %q must be HH:MM", mealTime)
+		}
+	}
+
+	wakeUp, wakeUpOK := clockField(&errs, "wake_up_time", log.WakeUpTime)
+	bed, bedOK := clockField(&errs, "bed_time", log.BedTime)
+
+	// Nobody sleeps longer than they were in bed
+	if wakeUpOK && bedOK && !errs.Has("sleep_hours") {
+		if inBed := SleepWindow(bed, wakeUp); inBed > 0 {
+			slept := time.Duration(log.SleepHours * float64(time.Hour))
+			if slept > inBed+SleepTolerance {
+				errs.Add("sleep_hours", "sleep_hours %.1f exceeds the %.1f hours between bed_time %s and wake_up_time %s",
+					log.SleepHours, inBed.Hours(), log.BedTime, log.WakeUpTime)
+			}
+		}
+	}
+
+	if log.LogDate == "" {
+		errs.Add("log_date", "log_date is required")
+	} else if date, err := time.Parse(DateLayout, log.LogDate); err != nil {
+		errs.Add("log_date", "log_date must be a YYYY-MM-DD date")
+	} else if latest := now.AddDate(0, 0, 1).Format(DateLayout); date.Format(DateLayout) > latest {
+		errs.Add("log_date", "log_date cannot be in the future")
+	}
+
+	return errs.Err()
+}
+
+// clockField checks a required HH:MM field and returns its offset from midnight
+func clockField(errs *Errors, field, value string) (time.Duration, bool) {
+	if value == "" {
+		errs.Add(field, "%s is required", field)
+		return 0, false
+	}
+
+	clock, err := ParseClock(value)
+	if err != nil {
+		errs.Add(field, "%s must be HH:MM", field)
+		return 0, false
+	}
+	return clock, true
+}
+
+// SleepWindow returns the time from bed to wakeUp, both offsets from midnight,
+// crossing midnight when wakeUp is earlier than bed; equal times give 0
+func SleepWindow(bed, wakeUp time.Duration) time.Duration {
+	window := wakeUp - bed
+	if window < 0 {
+		window += 24 * time.Hour
+	}
+	return window
+}
@@ -0,0 +1,182 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"lifepattern-api/internal/apperror"
+	"lifepattern-api/internal/database"
+)
+
+// testNow is the server time routine logs are validated against
+var testNow = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+func newValidRoutineLog() database.RoutineLog {
+	return database.RoutineLog{
+		UserID:           "user_1",
+		SleepHours:       8.0,
+		MealTimes:        []string{"07:30", "12:00", "18:30"},
+		ScreenTime:       4.5,
+		ExerciseDuration: 1.0,
+		WakeUpTime:       "07:00",
+		BedTime:          "23:00",
+		WaterIntake:      2.5,
+		StressLevel:      4,
+		LogDate:          "2024-01-15",
+	}
+}
+
+// invalidFields returns the fields reported by a RoutineLog error
+func invalidFields(t *testing.T, err error) []string {
+	t.Helper()
+
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		t.Fatalf("Expected an apperror.Error, got %v", err)
+	}
+	if appErr.Code != apperror.CodeValidation {
+		t.Fatalf("Expected code validation, got %s", appErr.Code)
+	}
+
+	var fields []string
+	for _, fieldErr := range appErr.Fields {
+		fields = append(fields, fieldErr.Field)
+	}
+	return fields
+}
+
+func TestRoutineLogValid(t *testing.T) {
+	if err := RoutineLog(newValidRoutineLog(), testNow); err != nil {
+		t.Fatalf("Expected no validation error, got %v", err)
+	}
+
+	// Single-digit hours are accepted, like the AI service does
+	log := newValidRoutineLog()
+	log.WakeUpTime = "7:00"
+	log.MealTimes = []string{"8:15"}
+	if err := RoutineLog(log, testNow); err != nil {
+		t.Fatalf("Expected single-digit hours to be valid, got %v", err)
+	}
+}
+
+func TestRoutineLogInvalidFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*database.RoutineLog)
+		field  string
+	}{
+		{"negative sleep hours", func(l *database.RoutineLog) { l.SleepHours = -1 }, "sleep_hours"},
+		{"excessive screen time", func(l *database.RoutineLog) { l.ScreenTime = 25 }, "screen_time"},
+		{"negative exercise", func(l *database.RoutineLog) { l.ExerciseDuration = -0.5 }, "exercise_duration"},
+		{"negative water intake", func(l *database.RoutineLog) { l.WaterIntake = -1 }, "water_intake"},
+		{"excessive water intake", func(l *database.RoutineLog) { l.WaterIntake = MaxWaterIntake + 1 }, "water_intake"},
+		{"stress level too high", func(l *database.RoutineLog) { l.StressLevel = 11 }, "stress_level"},
+		{"stress level missing", func(l *database.RoutineLog) { l.StressLevel = 0 }, "stress_level"},
+		{"empty meal times", func(l *database.RoutineLog) { l.MealTimes = []string{} }, "meal_times"},
+		{"too many meals", func(l *database.RoutineLog) { l.MealTimes = make([]string, MaxMealsPerDay+1); fillMeals(l.MealTimes) }, "meal_times"},
+		{"malformed meal time", func(l *database.RoutineLog) { l.MealTimes = []string{"07:30", "lunch"} }, "meal_times[1]"},
+		{"out of range meal time", func(l *database.RoutineLog) { l.MealTimes = []string{"25:00"} }, "meal_times[0]"},
+		{"missing wake up time", func(l *database.RoutineLog) { l.WakeUpTime = "" }, "wake_up_time"},
+		{"malformed wake up time", func(l *database.RoutineLog) { l.WakeUpTime = "7am" }, "wake_up_time"},
+		{"missing bed time", func(l *database.RoutineLog) { l.BedTime = "" }, "bed_time"},
+		{"malformed bed time", func(l *database.RoutineLog) { l.BedTime = "23:60" }, "bed_time"},
+		{"missing log date", func(l *database.RoutineLog) { l.LogDate = "" }, "log_date"},
+		{"malformed log date", func(l *database.RoutineLog) { l.LogDate = "15/01/2024" }, "log_date"},
+		{"impossible log date", func(l *database.RoutineLog) { l.LogDate = "2024-02-30" }, "log_date"},
+		{"future log date", func(l *database.RoutineLog) { l.LogDate = "2024-01-17" }, "log_date"},
+	}
+
+	for _, tt := range tests {
+		log := newValidRoutineLog()
+		tt.modify(&log)
+
+		fields := invalidFields(t, RoutineLog(log, testNow))
+		if len(fields) != 1 || fields[0] != tt.field {
+			t.Fatalf("%s: expected an error on %s, got %v", tt.name, tt.field, fields)
+		}
+	}
+}
+
+func fillMeals(meals []string) {
+	for i := range meals {
+		meals[i] = "12:00"
+	}
+}
+
+func TestRoutineLogAllowsTomorrow(t *testing.T) {
+	// Clients ahead of the server's time zone may already log the next day
+	log := newValidRoutineLog()
+	log.LogDate = "2024-01-16"
+
+	if err := RoutineLog(log, testNow); err != nil {
+		t.Fatalf("Expected tomorrow's log to be valid, got %v", err)
+	}
+}
+
+func TestRoutineLogReportsAllErrors(t *testing.T) {
+	log := newValidRoutineLog()
+	log.SleepHours = 30
+	log.MealTimes = []string{"noon", "18:30", "dinner"}
+	log.BedTime = "late"
+	log.LogDate = "tomorrow"
+
+	err := RoutineLog(log, testNow)
+
+	fields := strings.Join(invalidFields(t, err), ",")
+	if fields != "sleep_hours,meal_times[0],meal_times[2],bed_time,log_date" {
+		t.Fatalf("Expected every invalid field in order, got %s", fields)
+	}
+	if err.Error() != "5 fields are invalid" {
+		t.Fatalf("Expected a summary message, got %q", err.Error())
+	}
+}
+
+func TestRoutineLogSleepConsistency(t *testing.T) {
+	tests := []struct {
+		bedTime, wakeUpTime string
+		sleepHours          float64
+		valid               bool
+	}{
+		// 23:00 to 07:00 crosses midnight: 8 hours in bed
+		{"23:00", "07:00", 8, true},
+		{"23:00", "07:00", 9, true}, // within the tolerance for naps
+		{"23:00", "07:00", 9.5, false},
+		{"23:00", "07:00", 4, true}, // lying awake is not an input error
+		{"01:30", "06:00", 6, false},
+		{"13:00", "21:00", 8, true},  // night shift
+		{"07:00", "07:00", 12, true}, // equal times cannot be checked
+	}
+
+	for _, tt := range tests {
+		log := newValidRoutineLog()
+		log.BedTime, log.WakeUpTime, log.SleepHours = tt.bedTime, tt.wakeUpTime, tt.sleepHours
+
+		err := RoutineLog(log, testNow)
+		if tt.valid && err != nil {
+			t.Fatalf("%s-%s with %.1fh: expected valid, got %v", tt.bedTime, tt.wakeUpTime, tt.sleepHours, err)
+		}
+		if !tt.valid {
+			fields := invalidFields(t, err)
+			if len(fields) != 1 || fields[0] != "sleep_hours" {
+				t.Fatalf("%s-%s with %.1fh: expected an error on sleep_hours, got %v", tt.bedTime, tt.wakeUpTime, tt.sleepHours, fields)
+			}
+		}
+	}
+}
+
+func TestSingleFieldError(t *testing.T) {
+	log := newValidRoutineLog()
+	log.StressLevel = 0
+
+	var appErr *apperror.Error
+	if !errors.As(RoutineLog(log, testNow), &appErr) {
+		t.Fatal("Expected an apperror.Error")
+	}
+
+	// One invalid field is also reported as the error's field and message
+	if appErr.Field != "stress_level" || appErr.Message != "stress_level must be between 1 and 10" {
+		t.Fatalf("Expected the stress_level error, got %+v", appErr)
+	}
+}
@@ -0,0 +1,54 @@
+// Package validation checks request payloads and reports every invalid field at once
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	"lifepattern-api/internal/apperror"
+)
+
+const (
+	// ClockLayout is the HH:MM format of wake, bed and meal times
+	ClockLayout = "15:04"
+
+	// DateLayout is the YYYY-MM-DD format of log dates
+	DateLayout = "2006-01-02"
+)
+
+// Errors collects the invalid fields of one payload
+type Errors struct {
+	fields []apperror.FieldError
+}
+
+// Add records that field is invalid
+func (e *Errors) Add(field, format string, args ...any) {
+	e.fields = append(e.fields, apperror.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Has reports whether field already has an error
+func (e *Errors) Has(field string) bool {
+	for _, fieldErr := range e.fields {
+		if fieldErr.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns a validation error listing every recorded field, or nil if there are none
+func (e *Errors) Err() error {
+	if len(e.fields) == 0 {
+		return nil
+	}
+	return apperror.Invalid(e.fields)
+}
+
+// ParseClock parses an HH:MM time of day into the offset from midnight
+func ParseClock(value string) (time.Duration, error) {
+	t, err := time.Parse(ClockLayout, value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
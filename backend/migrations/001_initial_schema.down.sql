@@ -0,0 +1,8 @@
+-- Migration: 001_initial_schema.down.sql
+-- Description: Drop the initial LifePattern schema
+
+DROP TABLE IF EXISTS ai_reports;
+DROP TABLE IF EXISTS routine_logs;
+DROP TABLE IF EXISTS users;
+
+DROP FUNCTION IF EXISTS update_updated_at_column();
@@ -1,4 +1,4 @@
--- Migration: 001_initial_schema.sql
+-- Migration: 001_initial_schema.up.sql
 -- Description: Initial database schema for LifePattern application
 -- Date: 2024-01-15
 
@@ -56,15 +56,18 @@ END;
 $$ language 'plpgsql';
 
 -- Triggers to automatically update updated_at
+-- (dropped first so databases migrated by hand before the migration runner can replay this file)
+DROP TRIGGER IF EXISTS update_routine_logs_updated_at ON routine_logs;
 CREATE TRIGGER update_routine_logs_updated_at 
     BEFORE UPDATE ON routine_logs 
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+DROP TRIGGER IF EXISTS update_users_updated_at ON users;
 CREATE TRIGGER update_users_updated_at 
     BEFORE UPDATE ON users 
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
 -- Sample data insertion (for testing)
-INSERT INTO users (username, email) VALUES 
-    ('testuser', 'test@example.com')
+INSERT INTO users (id, username, email) VALUES 
+    (1, 'testuser', 'test@example.com')
 ON CONFLICT (username) DO NOTHING; 
\ No newline at end of file
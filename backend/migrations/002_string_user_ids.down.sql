@@ -0,0 +1,16 @@
+-- Migration: 002_string_user_ids.down.sql
+-- Description: Revert to integer user IDs
+-- Only possible while every user ID is numeric
+
+ALTER TABLE routine_logs DROP CONSTRAINT IF EXISTS routine_logs_user_id_fkey;
+
+ALTER TABLE routine_logs ALTER COLUMN user_id TYPE INTEGER USING user_id::integer;
+
+ALTER TABLE users ALTER COLUMN id TYPE INTEGER USING id::integer;
+CREATE SEQUENCE IF NOT EXISTS users_id_seq OWNED BY users.id;
+SELECT setval('users_id_seq', COALESCE((SELECT MAX(id) FROM users), 0) + 1, false);
+ALTER TABLE users ALTER COLUMN id SET DEFAULT nextval('users_id_seq');
+
+ALTER TABLE routine_logs
+    ADD CONSTRAINT routine_logs_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
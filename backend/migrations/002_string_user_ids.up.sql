@@ -0,0 +1,17 @@
+-- Migration: 002_string_user_ids.up.sql
+-- Description: Switch user identifiers to opaque device-based strings
+-- Date: 2026-10-16
+
+-- The mobile app generates ids like "user_<device>_<timestamp>_<random>",
+-- so users.id and routine_logs.user_id become strings end-to-end.
+ALTER TABLE routine_logs DROP CONSTRAINT IF EXISTS routine_logs_user_id_fkey;
+
+ALTER TABLE users ALTER COLUMN id DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN id TYPE VARCHAR(128) USING id::text;
+DROP SEQUENCE IF EXISTS users_id_seq;
+
+ALTER TABLE routine_logs ALTER COLUMN user_id TYPE VARCHAR(128) USING user_id::text;
+
+ALTER TABLE routine_logs
+    ADD CONSTRAINT routine_logs_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
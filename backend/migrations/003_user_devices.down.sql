@@ -0,0 +1,6 @@
+-- Migration: 003_user_devices.down.sql
+-- Description: Remove device-bound user registration
+-- users.email stays nullable: device-registered users have no email to restore
+
+DROP INDEX IF EXISTS idx_users_device_id;
+ALTER TABLE users DROP COLUMN IF EXISTS device_id;
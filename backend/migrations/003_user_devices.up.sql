@@ -0,0 +1,9 @@
+-- Migration: 003_user_devices.up.sql
+-- Description: Device-bound user registration
+-- Date: 2026-10-16
+
+-- Users are registered by the mobile app with a device ID instead of an email
+ALTER TABLE users ADD COLUMN IF NOT EXISTS device_id VARCHAR(128);
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_device_id ON users(device_id);
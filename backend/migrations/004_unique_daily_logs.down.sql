@@ -0,0 +1,5 @@
+-- Migration: 004_unique_daily_logs.down.sql
+-- Description: Allow several routine logs per user per day again
+
+ALTER TABLE routine_logs DROP CONSTRAINT IF EXISTS routine_logs_user_date_key;
+CREATE INDEX IF NOT EXISTS idx_routine_logs_user_date ON routine_logs(user_id, log_date);
@@ -0,0 +1,14 @@
+-- Migration: 004_unique_daily_logs.up.sql
+-- Description: One routine log per user per day
+-- Date: 2026-10-16
+
+-- Keep the most recent submission for each day; older duplicates and their AI reports are removed
+DELETE FROM routine_logs older
+USING routine_logs newer
+WHERE older.user_id = newer.user_id
+  AND older.log_date = newer.log_date
+  AND older.id < newer.id;
+
+DROP INDEX IF EXISTS idx_routine_logs_user_date;
+ALTER TABLE routine_logs
+    ADD CONSTRAINT routine_logs_user_date_key UNIQUE (user_id, log_date);
@@ -0,0 +1,4 @@
+-- Migration: 005_analysis_jobs.down.sql
+-- Description: Remove the pending analysis queue
+
+DROP TABLE IF EXISTS analysis_jobs;
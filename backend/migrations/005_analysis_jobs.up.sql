@@ -0,0 +1,22 @@
+-- Migration: 005_analysis_jobs.up.sql
+-- Description: Durable queue of routine logs waiting for AI analysis
+-- Date: 2026-10-16
+
+CREATE TABLE IF NOT EXISTS analysis_jobs (
+    id SERIAL PRIMARY KEY,
+    routine_log_id INTEGER NOT NULL UNIQUE REFERENCES routine_logs(id) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    run_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- not retried before this time
+    locked_until TIMESTAMP, -- set while a worker is processing the job
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_analysis_jobs_run_at ON analysis_jobs(run_at);
+
+-- Queue every log that was saved while the AI service was unavailable
+INSERT INTO analysis_jobs (routine_log_id)
+SELECT rl.id
+FROM routine_logs rl
+WHERE NOT EXISTS (SELECT 1 FROM ai_reports ar WHERE ar.routine_log_id = rl.id)
+ON CONFLICT (routine_log_id) DO NOTHING;
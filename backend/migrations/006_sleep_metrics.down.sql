@@ -0,0 +1,7 @@
+-- Migration: 006_sleep_metrics.down.sql
+-- Description: Remove the derived sleep metrics
+
+ALTER TABLE routine_logs
+    DROP COLUMN IF EXISTS sleep_duration_hours,
+    DROP COLUMN IF EXISTS sleep_midpoint_minutes,
+    DROP COLUMN IF EXISTS sleep_discrepancy_hours;
@@ -0,0 +1,32 @@
+-- Migration: 006_sleep_metrics.up.sql
+-- Description: Sleep window metrics derived from bed and wake times
+-- Date: 2026-10-16
+
+-- NULL when bed_time and wake_up_time cannot be parsed or are equal
+ALTER TABLE routine_logs
+    ADD COLUMN IF NOT EXISTS sleep_duration_hours DECIMAL(4,2),    -- bed_time to wake_up_time, across midnight
+    ADD COLUMN IF NOT EXISTS sleep_midpoint_minutes INTEGER,       -- minutes from midnight, in [-720, 720)
+    ADD COLUMN IF NOT EXISTS sleep_discrepancy_hours DECIMAL(4,2); -- sleep_hours minus sleep_duration_hours
+
+-- Backfill existing logs without touching updated_at
+ALTER TABLE routine_logs DISABLE TRIGGER update_routine_logs_updated_at;
+
+WITH clocks AS (
+    SELECT id, sleep_hours,
+           split_part(bed_time, ':', 1)::int * 60 + split_part(bed_time, ':', 2)::int AS bed,
+           split_part(wake_up_time, ':', 1)::int * 60 + split_part(wake_up_time, ':', 2)::int AS wake
+    FROM routine_logs
+    WHERE bed_time ~ '^([01]?[0-9]|2[0-3]):[0-5][0-9]$'
+      AND wake_up_time ~ '^([01]?[0-9]|2[0-3]):[0-5][0-9]$'
+), windows AS (
+    SELECT id, sleep_hours, bed, (wake - bed + 1440) % 1440 AS minutes
+    FROM clocks
+)
+UPDATE routine_logs rl
+SET sleep_duration_hours = round(w.minutes / 60.0, 2),
+    sleep_midpoint_minutes = (w.bed + w.minutes / 2 + 720) % 1440 - 720,
+    sleep_discrepancy_hours = round((rl.sleep_hours - w.minutes / 60.0)::numeric, 2)
+FROM windows w
+WHERE rl.id = w.id AND w.minutes > 0;
+
+ALTER TABLE routine_logs ENABLE TRIGGER update_routine_logs_updated_at;
@@ -0,0 +1,5 @@
+-- Migration: 007_personal_baseline.down.sql
+-- Description: Remove the personal baseline comparison
+
+ALTER TABLE ai_reports
+    DROP COLUMN IF EXISTS baseline;
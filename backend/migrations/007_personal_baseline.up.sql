@@ -0,0 +1,7 @@
+-- Migration: 007_personal_baseline.up.sql
+-- Description: Personal baseline comparison stored with each AI report
+-- Date: 2026-10-16
+
+-- NULL when the user had too little history for a baseline
+ALTER TABLE ai_reports
+    ADD COLUMN IF NOT EXISTS baseline JSONB; -- z-scores against the user's rolling mean and stddev
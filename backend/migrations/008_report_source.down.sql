@@ -0,0 +1,5 @@
+-- Migration: 008_report_source.down.sql
+-- Description: Remove the analyzer source of AI reports
+
+ALTER TABLE ai_reports
+    DROP COLUMN IF EXISTS source;
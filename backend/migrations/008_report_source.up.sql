@@ -0,0 +1,7 @@
+-- Migration: 008_report_source.up.sql
+-- Description: Record which analyzer produced each AI report
+-- Date: 2026-10-16
+
+-- Reports written before this migration all came from the AI service
+ALTER TABLE ai_reports
+    ADD COLUMN IF NOT EXISTS source VARCHAR(32) NOT NULL DEFAULT 'ai_service'; -- ai_service or local_rules
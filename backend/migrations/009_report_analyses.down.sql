@@ -0,0 +1,5 @@
+-- Migration: 009_report_analyses.down.sql
+-- Description: Remove the per-analyzer answers of AI reports
+
+ALTER TABLE ai_reports
+    DROP COLUMN IF EXISTS analyses;
@@ -0,0 +1,7 @@
+-- Migration: 009_report_analyses.up.sql
+-- Description: Store the answer of every analyzer of the analyzer chain with each AI report
+-- Date: 2026-10-16
+
+-- NULL for reports written by a single analyzer
+ALTER TABLE ai_reports
+    ADD COLUMN IF NOT EXISTS analyses JSONB;
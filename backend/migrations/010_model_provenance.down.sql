@@ -0,0 +1,10 @@
+-- Migration: 010_model_provenance.down.sql
+-- Description: Remove the model provenance of AI reports
+
+DROP INDEX IF EXISTS idx_ai_reports_model_version;
+
+ALTER TABLE ai_reports
+    DROP COLUMN IF EXISTS feature_names,
+    DROP COLUMN IF EXISTS model_accuracy,
+    DROP COLUMN IF EXISTS model_version,
+    DROP COLUMN IF EXISTS model_name;
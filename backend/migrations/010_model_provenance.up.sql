@@ -0,0 +1,13 @@
+-- Migration: 010_model_provenance.up.sql
+-- Description: Record the model name, version, accuracy and feature set behind each AI report
+-- Date: 2026-10-16
+
+-- NULL for reports of analyzers without a trained model and for reports written before this migration
+ALTER TABLE ai_reports
+    ADD COLUMN IF NOT EXISTS model_name VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS model_version VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS model_accuracy DECIMAL(4,3) CHECK (model_accuracy >= 0 AND model_accuracy <= 1),
+    ADD COLUMN IF NOT EXISTS feature_names JSONB;
+
+-- Insights are filtered by model version to compare anomaly rates across deployments
+CREATE INDEX IF NOT EXISTS idx_ai_reports_model_version ON ai_reports(model_version);
@@ -0,0 +1,4 @@
+-- Migration: 011_user_secrets.down.sql
+-- Description: Remove per-user secrets
+
+ALTER TABLE users DROP COLUMN IF EXISTS secret_hash;
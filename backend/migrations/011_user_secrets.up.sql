@@ -0,0 +1,8 @@
+-- Migration: 011_user_secrets.up.sql
+-- Description: Per-user secrets for issuing bearer tokens
+-- Date: 2026-10-16
+
+-- SHA-256 hash (hex) of the random secret returned once by POST /users
+-- Users registered before this migration have no secret; while AUTH_ALLOW_LEGACY_CLAIMS is set,
+-- registering their existing ID and device ID again issues one, once
+ALTER TABLE users ADD COLUMN IF NOT EXISTS secret_hash CHAR(64);
@@ -0,0 +1,6 @@
+-- Migration: 012_analysis_job_limits.down.sql
+-- Description: Remove analysis job statuses and versions
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS version,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,10 @@
+-- Migration: 012_analysis_job_limits.up.sql
+-- Description: Stop retrying analysis jobs after too many attempts and version re-queued jobs
+-- Date: 2026-10-16
+
+-- Jobs that used up their attempts stay in the table as 'failed' instead of being retried forever;
+-- version is incremented whenever a queued log is queued again, so a worker only completes the
+-- version it claimed
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'failed')),
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,9 @@
+// Package migrations embeds the versioned SQL schema migrations into the server binary.
+//
+// Files are named NNN_description.up.sql with an optional NNN_description.down.sql.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
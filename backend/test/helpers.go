@@ -1,11 +1,21 @@
 package test
 
 import (
+	"net/http"
 	"time"
 
+	"lifepattern-api/internal/auth"
 	"lifepattern-api/internal/database"
 )
 
+// TestUserID is the user seeded by migrations/001_initial_schema.sql
+const TestUserID = "1"
+
+// WithTestUser returns a copy of req authenticated as TestUserID
+func WithTestUser(req *http.Request) *http.Request {
+	return req.WithContext(auth.WithUserID(req.Context(), TestUserID))
+}
+
 // TestData contains common test data
 type TestData struct {
 	ValidRoutineLog   database.RoutineLog
@@ -17,7 +27,7 @@ type TestData struct {
 func NewTestData() *TestData {
 	return &TestData{
 		ValidRoutineLog: database.RoutineLog{
-			UserID:           1,
+			UserID:           TestUserID,
 			SleepHours:       8.0,
 			MealTimes:        []string{"07:30", "12:00", "18:30"},
 			ScreenTime:       4.5,
@@ -29,7 +39,7 @@ func NewTestData() *TestData {
 			LogDate:          time.Now().Format("2006-01-02"),
 		},
 		InvalidRoutineLog: database.RoutineLog{
-			UserID:           1,
+			UserID:           TestUserID,
 			SleepHours:       -1.0, // Invalid
 			MealTimes:        []string{},
 			ScreenTime:       25.0, // Invalid
@@ -52,7 +62,7 @@ func NewTestData() *TestData {
 }
 
 // CreateSampleRoutineLogs creates multiple sample routine logs for testing
-func CreateSampleRoutineLogs(userID int, count int) []database.RoutineLog {
+func CreateSampleRoutineLogs(userID string, count int) []database.RoutineLog {
 	logs := make([]database.RoutineLog, count)
 
 	for i := 0; i < count; i++ {
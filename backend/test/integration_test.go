@@ -2,15 +2,18 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"lifepattern-api/internal/database"
 	"lifepattern-api/internal/handlers"
+	"lifepattern-api/internal/logging"
 	"lifepattern-api/internal/services"
 )
 
@@ -32,21 +35,26 @@ func setupIntegrationTest(t *testing.T) *IntegrationTestSetup {
 	}
 
 	// Initialize repository
-	repo, err := database.NewRepository(testDBURL)
+	repo, err := database.NewRepository(testDBURL, logging.Discard())
 	if err != nil {
 		t.Fatalf("Failed to initialize test database: %v", err)
 	}
 
 	// Initialize AI service (use mock URL for testing)
-	aiService := services.NewAIService("http://localhost:9999") // Invalid URL for testing
+	aiService := services.NewAIService("http://localhost:9999", services.DefaultAIServiceTimeout, logging.Discard()) // Invalid URL for testing
 
 	// Initialize routine service
-	routineService := services.NewRoutineService(repo, aiService)
+	routineService := services.NewRoutineService(repo, aiService, logging.Discard(), nil)
 
 	// Initialize handlers
 	logHandler := handlers.NewLogHandler(routineService)
 	insightHandler := handlers.NewInsightHandler(routineService)
-	healthHandler := handlers.NewHealthHandler(repo, aiService)
+	healthMonitor := services.NewHealthMonitor(repo, aiService, services.HealthCheckConfig{
+		Interval: time.Minute,
+		Timeout:  time.Second,
+	}, logging.Discard())
+	healthMonitor.CheckNow(context.Background())
+	healthHandler := handlers.NewHealthHandler(healthMonitor, aiService)
 
 	return &IntegrationTestSetup{
 		repo:           repo,
@@ -70,7 +78,7 @@ func TestIntegrationCreateAndRetrieveRoutineLog(t *testing.T) {
 
 	// Create routine log
 	routineLog := database.RoutineLog{
-		UserID:           1,
+		UserID:           TestUserID,
 		SleepHours:       8.0,
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -83,7 +91,7 @@ func TestIntegrationCreateAndRetrieveRoutineLog(t *testing.T) {
 	}
 
 	// Test creating routine log via service
-	response, err := setup.routineService.CreateRoutineLog(routineLog)
+	response, err := setup.routineService.CreateRoutineLog(context.Background(), routineLog, services.ConflictReplace)
 	if err != nil {
 		t.Fatalf("Failed to create routine log: %v", err)
 	}
@@ -93,10 +101,11 @@ func TestIntegrationCreateAndRetrieveRoutineLog(t *testing.T) {
 	}
 
 	// Test retrieving routine log
-	logs, err := setup.routineService.GetUserRoutineLogs(1, 10)
+	page, err := setup.routineService.GetUserRoutineLogs(context.Background(), services.LogQuery{UserID: TestUserID, From: "2024-01-15", To: "2024-01-15"})
 	if err != nil {
 		t.Fatalf("Failed to retrieve routine logs: %v", err)
 	}
+	logs := page.Logs
 
 	if len(logs) == 0 {
 		t.Fatal("Expected at least one routine log")
@@ -105,7 +114,7 @@ func TestIntegrationCreateAndRetrieveRoutineLog(t *testing.T) {
 	// Verify the log was saved correctly
 	found := false
 	for _, log := range logs {
-		if log.UserID == 1 && log.SleepHours == 8.0 {
+		if log.UserID == TestUserID && log.SleepHours == 8.0 {
 			found = true
 			break
 		}
@@ -126,13 +135,14 @@ func TestIntegrationHTTPEndpoints(t *testing.T) {
 
 	setup.healthHandler.HealthCheck(w, req)
 
-	if w.Code != http.StatusServiceUnavailable {
-		t.Fatalf("Expected status 503 (AI service down), got %d", w.Code)
+	// The AI service is down, which degrades the API without making it unavailable
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 (degraded, AI service down), got %d", w.Code)
 	}
 
 	// Test creating routine log via HTTP
 	routineLog := database.RoutineLog{
-		UserID:           1,
+		UserID:           TestUserID,
 		SleepHours:       7.5,
 		MealTimes:        []string{"08:00", "13:00", "19:00"},
 		ScreenTime:       5.0,
@@ -147,12 +157,14 @@ func TestIntegrationHTTPEndpoints(t *testing.T) {
 	jsonBody, _ := json.Marshal(routineLog)
 	req = httptest.NewRequest("POST", "/log", bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
+	req = WithTestUser(req)
 	w = httptest.NewRecorder()
 
 	setup.logHandler.CreateRoutineLog(w, req)
 
-	if w.Code != http.StatusCreated {
-		t.Fatalf("Expected status 201, got %d", w.Code)
+	// The day may already be logged by a previous run, in which case it is replaced
+	if w.Code != http.StatusCreated && w.Code != http.StatusOK {
+		t.Fatalf("Expected status 201 or 200, got %d", w.Code)
 	}
 
 	var response services.CreateRoutineLogResponse
@@ -165,7 +177,8 @@ func TestIntegrationHTTPEndpoints(t *testing.T) {
 	}
 
 	// Test retrieving logs via HTTP
-	req = httptest.NewRequest("GET", fmt.Sprintf("/logs?user_id=1&limit=10"), nil)
+	req = httptest.NewRequest("GET", fmt.Sprintf("/logs?user_id=%s&limit=10", TestUserID), nil)
+	req = WithTestUser(req)
 	w = httptest.NewRecorder()
 
 	setup.logHandler.GetUserRoutineLogs(w, req)
@@ -179,8 +192,8 @@ func TestIntegrationHTTPEndpoints(t *testing.T) {
 		t.Fatalf("Failed to unmarshal logs response: %v", err)
 	}
 
-	if logsResponse["user_id"] != float64(1) {
-		t.Fatalf("Expected user ID 1, got %v", logsResponse["user_id"])
+	if logsResponse["user_id"] != TestUserID {
+		t.Fatalf("Expected user ID %s, got %v", TestUserID, logsResponse["user_id"])
 	}
 }
 
@@ -190,7 +203,7 @@ func TestIntegrationValidation(t *testing.T) {
 
 	// Test invalid routine log
 	invalidLog := database.RoutineLog{
-		UserID:           1,
+		UserID:           TestUserID,
 		SleepHours:       -1.0, // Invalid
 		MealTimes:        []string{"07:30", "12:00", "18:30"},
 		ScreenTime:       4.5,
@@ -205,6 +218,7 @@ func TestIntegrationValidation(t *testing.T) {
 	jsonBody, _ := json.Marshal(invalidLog)
 	req := httptest.NewRequest("POST", "/log", bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
+	req = WithTestUser(req)
 	w := httptest.NewRecorder()
 
 	setup.logHandler.CreateRoutineLog(w, req)
@@ -218,24 +232,25 @@ func TestIntegrationErrorHandling(t *testing.T) {
 	setup := setupIntegrationTest(t)
 	defer setup.cleanup()
 
-	// Test missing user_id parameter
+	// Test unauthenticated request
 	req := httptest.NewRequest("GET", "/logs", nil)
 	w := httptest.NewRecorder()
 
 	setup.logHandler.GetUserRoutineLogs(w, req)
 
-	if w.Code != http.StatusBadRequest {
-		t.Fatalf("Expected status 400 for missing user_id, got %d", w.Code)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 for unauthenticated request, got %d", w.Code)
 	}
 
-	// Test invalid user_id parameter
-	req = httptest.NewRequest("GET", "/logs?user_id=invalid", nil)
+	// Test reading another user's logs
+	req = httptest.NewRequest("GET", "/logs?user_id=someone_else", nil)
+	req = WithTestUser(req)
 	w = httptest.NewRecorder()
 
 	setup.logHandler.GetUserRoutineLogs(w, req)
 
-	if w.Code != http.StatusBadRequest {
-		t.Fatalf("Expected status 400 for invalid user_id, got %d", w.Code)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 for another user's logs, got %d", w.Code)
 	}
 
 	// Test missing log_id parameter
@@ -158,10 +158,11 @@ const hashString = async (str: string): Promise<string> => {
 };
 
 // Generate unique user ID
-const generateUserId = (deviceId: string): string => {
+// The ID is shared with the backend and must not reveal the device ID
+const generateUserId = (): string => {
   const timestamp = Date.now();
   const random = Math.random().toString(36).substr(2, 9);
-  return `user_${deviceId}_${timestamp}_${random}`;
+  return `user_${timestamp}_${random}`;
 };
 
 // Hash passphrase for secure storage
@@ -208,7 +209,7 @@ export class UserManager {
 
       // Create new user session
       const deviceId = await generateDeviceId();
-      const userId = generateUserId(deviceId);
+      const userId = generateUserId();
       const username = generateUsername();
       const passphrase = generatePassphrase();
       